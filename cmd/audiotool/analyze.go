@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/iburimskiy/audio-visualization/internal/analysis"
+	"github.com/iburimskiy/audio-visualization/internal/audio"
+	"github.com/iburimskiy/audio-visualization/internal/batch"
+	"github.com/iburimskiy/audio-visualization/internal/midi"
+)
+
+const analyzeUsage = `Usage: audiotool analyze [flags] file
+
+Decodes the file without opening a window or an audio device and writes its
+features as JSON: duration, format, peak and RMS level, integrated loudness,
+tempo, beat times and band levels every hop. With -midi it also writes the
+beats and band onsets as a Standard MIDI File.
+
+Flags:
+`
+
+// analyzeResult is the JSON document written by the analyze command.
+type analyzeResult struct {
+	File string `json:"file"`
+	*analysis.Report
+}
+
+func runAnalyze(args []string, stdout, stderr io.Writer) int {
+	opts := analysis.DefaultOptions()
+	var output, midiPath string
+	var pretty bool
+
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, analyzeUsage)
+		fs.PrintDefaults()
+	}
+	fs.IntVar(&opts.Hop, "hop", opts.Hop, "distance between band frames in `samples`")
+	fs.IntVar(&opts.WindowSize, "window", opts.WindowSize, "FFT size of each band frame in `samples` (power of two)")
+	fs.IntVar(&opts.Bands, "bands", opts.Bands, "number of frequency bands per frame")
+	fs.StringVar(&output, "o", "", "write to `file` instead of standard output")
+	fs.BoolVar(&pretty, "pretty", false, "indent the JSON output")
+	fs.StringVar(&midiPath, "midi", "", "also write beats and band onsets as a MIDI `file`")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "analyze: expected exactly one file")
+		fs.Usage()
+		return exitUsage
+	}
+	if err := opts.Validate(); err != nil {
+		fmt.Fprintln(stderr, "analyze:", err)
+		fs.Usage()
+		return exitUsage
+	}
+
+	path := fs.Arg(0)
+	result, err := analyzeFile(path, opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if midiPath != "" {
+		err := batch.WriteFile(midiPath, midi.FromReport(result.Report).Write)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+	if err := writeJSON(output, stdout, result, pretty); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
+
+func analyzeFile(path string, opts analysis.Options) (*analyzeResult, error) {
+	streamer, format, err := audio.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer streamer.Close()
+
+	report, err := analysis.Analyze(streamer, format, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &analyzeResult{File: path, Report: report}, nil
+}
+
+// writeJSON encodes v to the file at path, or to stdout when path is empty
+// or "-".
+func writeJSON(path string, stdout io.Writer, v any, pretty bool) error {
+	if path == "" || path == "-" {
+		return encodeJSON(stdout, v, pretty)
+	}
+	return batch.WriteFile(path, func(w io.Writer) error {
+		return encodeJSON(w, v, pretty)
+	})
+}
+
+func encodeJSON(w io.Writer, v any, pretty bool) error {
+	enc := json.NewEncoder(w)
+	if pretty {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(v)
+}
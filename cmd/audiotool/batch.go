@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+
+	"github.com/iburimskiy/audio-visualization/internal/analysis"
+	"github.com/iburimskiy/audio-visualization/internal/batch"
+	"github.com/iburimskiy/audio-visualization/internal/thumbnail"
+)
+
+const batchUsage = `Usage: audiotool batch [flags] -out dir [file|folder|playlist ...]
+
+Processes many files in parallel and writes one output per input under the
+output folder. Files found in a folder keep their relative path. Inputs whose
+output already exists are skipped, so an interrupted run can be resumed by
+running the same command again.
+
+Modes:
+  analyze     features as JSON, like the analyze command (<input>.json)
+  thumbnail   waveform or spectrum image, like the thumbnail command
+              (<input>.png or <input>.svg)
+
+Flags:
+`
+
+func runBatch(args []string, stderr io.Writer) int {
+	opts := analysis.DefaultOptions()
+	var thumb thumbnailFlags
+	var (
+		mode    string
+		outDir  string
+		list    string
+		workers int
+		force   bool
+		pretty  bool
+	)
+
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, batchUsage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&mode, "mode", "analyze", "what to produce for each input")
+	fs.StringVar(&outDir, "out", "", "write outputs under `dir` (required)")
+	fs.StringVar(&list, "list", "", "read more inputs from `file`, one per line (- for standard input)")
+	fs.IntVar(&workers, "jobs", runtime.NumCPU(), "number of files processed at once")
+	fs.BoolVar(&force, "force", false, "process inputs even if their output exists")
+	fs.IntVar(&opts.Hop, "hop", opts.Hop, "analyze: distance between band frames in `samples`")
+	fs.IntVar(&opts.WindowSize, "window", opts.WindowSize, "analyze: FFT size of each band frame in `samples` (power of two)")
+	fs.IntVar(&opts.Bands, "bands", 0, "number of frequency bands (default 32 for analyze, 64 for spectrum thumbnails)")
+	fs.BoolVar(&pretty, "pretty", false, "analyze: indent the JSON output")
+	thumb.register(fs)
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	usageError := func(format string, a ...any) int {
+		fmt.Fprintf(stderr, "batch: "+format+"\n", a...)
+		fs.Usage()
+		return exitUsage
+	}
+
+	var ext string
+	var process func(ctx context.Context, job batch.Job) error
+	switch mode {
+	case "analyze":
+		if opts.Bands == 0 {
+			opts.Bands = analysis.DefaultOptions().Bands
+		}
+		if err := opts.Validate(); err != nil {
+			return usageError("%v", err)
+		}
+		ext = ".json"
+		process = func(_ context.Context, job batch.Job) error {
+			result, err := analyzeFile(job.Input, opts)
+			if err != nil {
+				return err
+			}
+			return writeJSON(job.Output, nil, result, pretty)
+		}
+	case "thumbnail":
+		thumb.bands = opts.Bands
+		if thumb.bands == 0 {
+			thumb.bands = thumbnail.DefaultBands
+		}
+		render, err := thumb.options()
+		if err != nil {
+			return usageError("%v", err)
+		}
+		format := thumb.format
+		if format == "" {
+			format = "png"
+		}
+		ext = "." + format
+		process = func(_ context.Context, job batch.Job) error {
+			t, err := renderThumbnail(job.Input, render)
+			if err != nil {
+				return err
+			}
+			return writeThumbnail(job.Output, nil, t, format)
+		}
+	default:
+		return usageError("unknown mode %q", mode)
+	}
+	if outDir == "" {
+		return usageError("-out is required")
+	}
+	if workers < 1 {
+		return usageError("-jobs: %d must be at least 1", workers)
+	}
+
+	inputs := fs.Args()
+	if list != "" {
+		paths, err := readInputList(list)
+		if err != nil {
+			fmt.Fprintln(stderr, "batch:", err)
+			return exitError
+		}
+		inputs = append(inputs, paths...)
+	}
+	if len(inputs) == 0 {
+		return usageError("no inputs given")
+	}
+	jobs, err := batch.Plan(inputs, outDir, ext)
+	if err != nil {
+		fmt.Fprintln(stderr, "batch:", err)
+		return exitError
+	}
+
+	// Ctrl+C stops handing out files; the ones in progress are finished
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	width := len(fmt.Sprint(len(jobs)))
+	runner := &batch.Runner{
+		Workers: workers,
+		Force:   force,
+		Process: process,
+		Progress: func(r batch.Result, finished, total int) {
+			status := fmt.Sprintf("done    %s (%.1fs)", r.Input, r.Elapsed.Seconds())
+			switch {
+			case r.Err != nil:
+				status = fmt.Sprintf("FAILED  %s: %v", r.Input, r.Err)
+			case r.Skipped:
+				status = "skipped " + r.Input
+			}
+			fmt.Fprintf(stderr, "[%*d/%d] %s\n", width, finished, total, status)
+		},
+	}
+	summary := runner.Run(ctx, jobs)
+
+	fmt.Fprintf(stderr, "\n%d files: %d done, %d skipped, %d failed", summary.Total, summary.Done, summary.Skipped, len(summary.Failed))
+	if summary.Canceled > 0 {
+		fmt.Fprintf(stderr, ", %d not started (interrupted)", summary.Canceled)
+	}
+	fmt.Fprintln(stderr)
+	if len(summary.Failed) > 0 {
+		fmt.Fprintln(stderr, "\nFailed:")
+		for _, r := range summary.Failed {
+			fmt.Fprintf(stderr, "  %s: %v\n", r.Input, r.Err)
+		}
+	}
+	if len(summary.Failed) > 0 || summary.Canceled > 0 {
+		return exitError
+	}
+	return exitOK
+}
+
+func readInputList(path string) ([]string, error) {
+	if path == "-" {
+		return batch.ReadList(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return batch.ReadList(f)
+}
@@ -0,0 +1,48 @@
+// Command audiotool analyzes audio files and renders thumbnails of them
+// without a window or an audio device, so it builds and runs on servers
+// that lack the graphics and sound libraries of the player.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit codes, the same as the player's
+const (
+	exitOK    = 0
+	exitError = 1 // runtime or file errors
+	exitUsage = 2 // bad flags or arguments
+)
+
+const usage = `Usage: audiotool analyze [flags] file
+       audiotool thumbnail [flags] -o image file
+       audiotool batch [flags] -out dir [file|folder|playlist ...]
+
+Run a command with -help for its flags.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	switch args[0] {
+	case "analyze":
+		return runAnalyze(args[1:], stdout, stderr)
+	case "thumbnail":
+		return runThumbnail(args[1:], stdout, stderr)
+	case "batch":
+		return runBatch(args[1:], stderr)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	}
+	fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+	return exitUsage
+}
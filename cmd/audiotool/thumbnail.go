@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/iburimskiy/audio-visualization/internal/audio"
+	"github.com/iburimskiy/audio-visualization/internal/batch"
+	"github.com/iburimskiy/audio-visualization/internal/thumbnail"
+)
+
+const thumbnailUsage = `Usage: audiotool thumbnail [flags] -o image file
+
+Renders a waveform or spectrum overview of the whole file as PNG or SVG,
+without opening a window or an audio device. The format follows the output
+file's extension unless -format is given.
+
+Flags:
+`
+
+// thumbnailFlags are shared by the thumbnail command and the batch mode.
+type thumbnailFlags struct {
+	size   string
+	kind   string
+	theme  string
+	bands  int
+	format string
+}
+
+func (f *thumbnailFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.size, "size", "1200x200", "image size in pixels, `WxH`")
+	fs.StringVar(&f.kind, "kind", "waveform", "waveform or spectrum")
+	fs.StringVar(&f.theme, "theme", thumbnail.DefaultTheme, "color theme: "+strings.Join(thumbnail.Themes(), ", "))
+	fs.StringVar(&f.format, "format", "", "png or svg")
+}
+
+// options checks the flags and returns the render options.
+func (f *thumbnailFlags) options() (thumbnail.Options, error) {
+	var opts thumbnail.Options
+	if _, err := fmt.Sscanf(f.size, "%dx%d", &opts.Width, &opts.Height); err != nil {
+		return opts, fmt.Errorf("-size: %q is not WxH", f.size)
+	}
+	var err error
+	if opts.Kind, err = thumbnail.ParseKind(f.kind); err != nil {
+		return opts, fmt.Errorf("-kind: %w", err)
+	}
+	if opts.Theme, err = thumbnail.LookupTheme(f.theme); err != nil {
+		return opts, fmt.Errorf("-theme: %w", err)
+	}
+	opts.Bands = f.bands
+	if err := opts.Validate(); err != nil {
+		return opts, err
+	}
+	switch f.format {
+	case "", "png", "svg":
+	default:
+		return opts, fmt.Errorf("-format: %q is not png or svg", f.format)
+	}
+	return opts, nil
+}
+
+func runThumbnail(args []string, stdout, stderr io.Writer) int {
+	flags := thumbnailFlags{bands: thumbnail.DefaultBands}
+	var output string
+
+	fs := flag.NewFlagSet("thumbnail", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, thumbnailUsage)
+		fs.PrintDefaults()
+	}
+	flags.register(fs)
+	fs.IntVar(&flags.bands, "bands", flags.bands, "number of frequency rows of a spectrum")
+	fs.StringVar(&output, "o", "", "write to `file` (- for standard output, as PNG unless -format is given)")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	usageError := func(format string, a ...any) int {
+		fmt.Fprintf(stderr, "thumbnail: "+format+"\n", a...)
+		fs.Usage()
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		return usageError("expected exactly one file")
+	}
+	if output == "" {
+		return usageError("-o is required")
+	}
+	opts, err := flags.options()
+	if err != nil {
+		return usageError("%v", err)
+	}
+	format := flags.format
+	if format == "" {
+		format = imageFormat(output)
+	}
+
+	t, err := renderThumbnail(fs.Arg(0), opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if err := writeThumbnail(output, stdout, t, format); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// imageFormat picks the format from a file name, defaulting to PNG.
+func imageFormat(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".svg") {
+		return "svg"
+	}
+	return "png"
+}
+
+func renderThumbnail(path string, opts thumbnail.Options) (*thumbnail.Thumbnail, error) {
+	streamer, format, err := audio.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer streamer.Close()
+
+	t, err := thumbnail.Generate(streamer, format, streamer.Len(), opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// writeThumbnail encodes t to the file at path, or to stdout when path is
+// "-".
+func writeThumbnail(path string, stdout io.Writer, t *thumbnail.Thumbnail, format string) error {
+	encode := t.WritePNG
+	if format == "svg" {
+		encode = t.WriteSVG
+	}
+	if path == "-" {
+		return encode(stdout)
+	}
+	return batch.WriteFile(path, encode)
+}
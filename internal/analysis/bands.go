@@ -0,0 +1,88 @@
+package analysis
+
+import "math"
+
+// BandAnalyzer splits windows of mono samples into logarithmically spaced
+// frequency bands and measures the level of each.
+type BandAnalyzer struct {
+	size   int
+	window []float64
+	edges  []float64 // band edges in Hz, one more than the number of bands
+	bins   [][2]int  // FFT bin range [first, last) of each band
+	buf    []complex128
+	norm   float64 // scales summed bin power so a full-scale sine reads 0 dB
+}
+
+// NewBandAnalyzer prepares an analyzer for windows of size samples (a power
+// of two) at sampleRate, covering 20 Hz up to 20 kHz or the Nyquist
+// frequency, whichever is lower.
+func NewBandAnalyzer(size int, sampleRate float64, bands int) *BandAnalyzer {
+	a := &BandAnalyzer{
+		size:   size,
+		window: make([]float64, size),
+		edges:  make([]float64, bands+1),
+		bins:   make([][2]int, bands),
+		buf:    make([]complex128, size),
+	}
+	// Hann window. A sine leaks into neighbouring bins, so the summed power
+	// is normalized by the window's energy rather than its gain.
+	var sumSq float64
+	for i := range a.window {
+		w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size))
+		a.window[i] = w
+		sumSq += w * w
+	}
+	a.norm = 4 / (float64(size) * sumSq)
+
+	low, high := 20.0, math.Min(20000, sampleRate/2)
+	for i := range a.edges {
+		a.edges[i] = low * math.Pow(high/low, float64(i)/float64(bands))
+	}
+	binWidth := sampleRate / float64(size)
+	for i := range a.bins {
+		first := int(math.Ceil(a.edges[i] / binWidth))
+		last := int(math.Ceil(a.edges[i+1] / binWidth))
+		// Narrow low bands get at least the bin they fall into
+		if last <= first {
+			last = first + 1
+		}
+		a.bins[i] = [2]int{first, min(last, size/2+1)}
+	}
+	return a
+}
+
+// Edges returns the band edges in Hz.
+func (a *BandAnalyzer) Edges() []float64 {
+	return a.edges
+}
+
+// Levels writes the level of each band in dBFS to out, which must have one
+// entry per band. A full-scale sine in a band reads about 0 dB; silence is
+// clamped to -120 dB.
+func (a *BandAnalyzer) Levels(samples []float64, out []float64) {
+	for i := range a.buf {
+		var s float64
+		if i < len(samples) {
+			s = samples[i]
+		}
+		a.buf[i] = complex(s*a.window[i], 0)
+	}
+	FFT(a.buf)
+
+	for b, r := range a.bins {
+		var power float64
+		for k := r[0]; k < r[1]; k++ {
+			re, im := real(a.buf[k]), imag(a.buf[k])
+			power += re*re + im*im
+		}
+		out[b] = decibels(math.Sqrt(power * a.norm))
+	}
+}
+
+// decibels converts a linear amplitude to dBFS, clamped at -120 dB.
+func decibels(amplitude float64) float64 {
+	if amplitude <= 1e-6 {
+		return -120
+	}
+	return 20 * math.Log10(amplitude)
+}
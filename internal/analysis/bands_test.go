@@ -0,0 +1,39 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBandAnalyzer(t *testing.T) {
+	const rate, size = 48000, 2048
+	a := NewBandAnalyzer(size, rate, 16)
+	edges := a.Edges()
+	if len(edges) != 17 || edges[0] != 20 || math.Abs(edges[16]-20000) > 1e-6 {
+		t.Fatalf("edges %v", edges)
+	}
+
+	// A full-scale sine reads about 0 dB in its band and far less elsewhere
+	samples := make([]float64, size)
+	for i := range samples {
+		samples[i] = math.Sin(2 * math.Pi * 1000 * float64(i) / rate)
+	}
+	levels := make([]float64, 16)
+	a.Levels(samples, levels)
+	for b, level := range levels {
+		inside := edges[b] <= 1000 && 1000 < edges[b+1]
+		switch {
+		case inside && math.Abs(level) > 1:
+			t.Errorf("band %d (%.0f-%.0f Hz) with the sine: %.2f dB", b, edges[b], edges[b+1], level)
+		case !inside && (edges[b+1] < 700 || edges[b] > 1400) && level > -40:
+			t.Errorf("band %d (%.0f-%.0f Hz) without the sine: %.2f dB", b, edges[b], edges[b+1], level)
+		}
+	}
+
+	a.Levels(make([]float64, size), levels)
+	for b, level := range levels {
+		if level != -120 {
+			t.Errorf("band %d of silence: %v dB", b, level)
+		}
+	}
+}
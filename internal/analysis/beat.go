@@ -0,0 +1,118 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+)
+
+// BeatTracker detects beats from a stream of energy values and estimates the
+// tempo from the intervals between them. Energies may arrive at any rate; the
+// running statistics are time based so the result does not depend on how
+// often Process is called.
+type BeatTracker struct {
+	// Sensitivity is how far above the running average (in standard
+	// deviations) the energy has to rise to count as a beat.
+	Sensitivity float64
+	// MinInterval is the shortest time between two beats in seconds.
+	MinInterval float64
+	// Window is the time constant of the running average in seconds.
+	Window float64
+
+	mean      float64
+	variance  float64
+	lastT     float64
+	lastBeat  float64
+	started   bool
+	intervals []float64
+	bpm       float64
+}
+
+func NewBeatTracker() *BeatTracker {
+	return &BeatTracker{
+		Sensitivity: 1.5,
+		MinInterval: 0.3,
+		Window:      1.0,
+		lastBeat:    math.Inf(-1),
+	}
+}
+
+// Process feeds the energy measured at time t (seconds) and reports whether
+// it is a beat.
+func (b *BeatTracker) Process(energy, t float64) bool {
+	if !b.started {
+		b.started = true
+		b.lastT = t
+		b.mean = energy
+		return false
+	}
+	dt := t - b.lastT
+	b.lastT = t
+	if dt <= 0 {
+		return false
+	}
+
+	// Compare against the statistics before this value is folded in
+	threshold := b.mean + b.Sensitivity*math.Sqrt(b.variance)
+	beat := energy > threshold && energy > 1e-6 && t-b.lastBeat >= b.MinInterval
+
+	alpha := 1 - math.Exp(-dt/b.Window)
+	diff := energy - b.mean
+	b.mean += alpha * diff
+	b.variance = (1 - alpha) * (b.variance + alpha*diff*diff)
+
+	if beat {
+		b.addInterval(t - b.lastBeat)
+		b.lastBeat = t
+	}
+	return beat
+}
+
+func (b *BeatTracker) addInterval(interval float64) {
+	// Ignore gaps that cannot belong to a plausible tempo
+	if interval < 0.25 || interval > 2.0 {
+		return
+	}
+	const keep = 16
+	b.intervals = append(b.intervals, interval)
+	if len(b.intervals) > keep {
+		b.intervals = b.intervals[len(b.intervals)-keep:]
+	}
+	if len(b.intervals) >= 4 {
+		b.bpm = FoldTempo(60 / median(b.intervals))
+	}
+}
+
+// BPM returns the current tempo estimate, or 0 if it is not known yet.
+func (b *BeatTracker) BPM() float64 {
+	return b.bpm
+}
+
+// LastBeat returns the time of the most recent beat.
+func (b *BeatTracker) LastBeat() float64 {
+	return b.lastBeat
+}
+
+// FoldTempo doubles or halves bpm until it lies in the 70-180 range most
+// music is felt in.
+func FoldTempo(bpm float64) float64 {
+	if bpm <= 0 {
+		return 0
+	}
+	for bpm < 70 {
+		bpm *= 2
+	}
+	for bpm >= 180 {
+		bpm /= 2
+	}
+	return bpm
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,47 @@
+package analysis
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+// dft is the textbook transform the FFT must agree with.
+func dft(x []complex128) []complex128 {
+	n := len(x)
+	out := make([]complex128, n)
+	for k := range out {
+		for j, v := range x {
+			out[k] += v * cmplx.Exp(complex(0, -2*math.Pi*float64(j*k)/float64(n)))
+		}
+	}
+	return out
+}
+
+func TestFFT(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 2, 4, 8, 64, 512} {
+		x := make([]complex128, n)
+		for i := range x {
+			x[i] = complex(rng.Float64()*2-1, rng.Float64()*2-1)
+		}
+		want := dft(x)
+		FFT(x)
+		for k := range x {
+			if cmplx.Abs(x[k]-want[k]) > 1e-9*float64(n) {
+				t.Errorf("n=%d bin %d: %v, want %v", n, k, x[k], want[k])
+				break
+			}
+		}
+	}
+}
+
+func TestFFTPanicsOnOddLength(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for length 6")
+		}
+	}()
+	FFT(make([]complex128, 6))
+}
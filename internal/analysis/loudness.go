@@ -0,0 +1,141 @@
+package analysis
+
+import "math"
+
+// Loudness measures integrated loudness as specified in ITU-R BS.1770: the
+// signal is K-weighted, split into 400 ms blocks overlapping by 75% and gated
+// at -70 LUFS and at 10 LU below the ungated average.
+type Loudness struct {
+	channels int
+	filters  [2][2]biquad // per channel: high shelf, then high pass
+
+	subBlock  int       // samples per 100 ms step
+	count     int       // samples in the current step
+	energy    float64   // weighted energy of the current step
+	steps     []float64 // energy of the last four steps
+	blocks    []float64 // mean square of every 400 ms block
+	blockSize float64
+}
+
+// NewLoudness prepares a meter for audio at sampleRate with one or two
+// channels. Mono audio that has been duplicated to stereo is measured once.
+func NewLoudness(sampleRate float64, channels int) *Loudness {
+	if channels < 1 || channels > 2 {
+		channels = 2
+	}
+	l := &Loudness{
+		channels: channels,
+		subBlock: int(math.Round(sampleRate / 10)),
+	}
+	l.blockSize = float64(4 * l.subBlock)
+
+	// K-weighting filters computed for the sample rate (the constants are
+	// the analog prototypes behind the 48 kHz coefficients in the standard)
+	shelf := highShelf(sampleRate, 1681.974450955533, 3.999843853973347, 0.7071752369554196)
+	pass := highPass(sampleRate, 38.13547087602444, 0.5003270373238773)
+	for ch := range l.filters {
+		l.filters[ch] = [2]biquad{shelf, pass}
+	}
+	return l
+}
+
+// Add feeds stereo samples to the meter.
+func (l *Loudness) Add(samples [][2]float64) {
+	for _, s := range samples {
+		for ch := 0; ch < l.channels; ch++ {
+			v := l.filters[ch][0].process(s[ch])
+			v = l.filters[ch][1].process(v)
+			l.energy += v * v
+		}
+		l.count++
+		if l.count == l.subBlock {
+			l.finishStep()
+		}
+	}
+}
+
+func (l *Loudness) finishStep() {
+	l.steps = append(l.steps, l.energy)
+	l.energy, l.count = 0, 0
+	if len(l.steps) < 4 {
+		return
+	}
+	l.steps = l.steps[len(l.steps)-4:]
+	var sum float64
+	for _, e := range l.steps {
+		sum += e
+	}
+	l.blocks = append(l.blocks, sum/l.blockSize)
+}
+
+// Integrated returns the gated loudness in LUFS. It reports false when the
+// audio is shorter than one block or entirely below the absolute gate.
+func (l *Loudness) Integrated() (float64, bool) {
+	gated := func(threshold float64) (float64, int) {
+		var sum float64
+		var n int
+		for _, ms := range l.blocks {
+			if lufs(ms) > threshold {
+				sum += ms
+				n++
+			}
+		}
+		if n == 0 {
+			return 0, 0
+		}
+		return sum / float64(n), n
+	}
+
+	mean, n := gated(-70)
+	if n == 0 {
+		return 0, false
+	}
+	mean, n = gated(lufs(mean) - 10)
+	if n == 0 {
+		return 0, false
+	}
+	return lufs(mean), true
+}
+
+func lufs(meanSquare float64) float64 {
+	return -0.691 + 10*math.Log10(meanSquare)
+}
+
+// biquad is a direct form I second-order filter.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+func highShelf(sampleRate, freq, gainDB, q float64) biquad {
+	k := math.Tan(math.Pi * freq / sampleRate)
+	vh := math.Pow(10, gainDB/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	return biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+}
+
+func highPass(sampleRate, freq, q float64) biquad {
+	k := math.Tan(math.Pi * freq / sampleRate)
+	a0 := 1 + k/q + k*k
+	return biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+}
@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+// sine returns seconds of a sine at freq and amplitude, on the channels
+// given.
+func sine(rate, freq, amplitude, seconds float64, left, right bool) [][2]float64 {
+	samples := make([][2]float64, int(rate*seconds))
+	for i := range samples {
+		v := amplitude * math.Sin(2*math.Pi*freq*float64(i)/rate)
+		if left {
+			samples[i][0] = v
+		}
+		if right {
+			samples[i][1] = v
+		}
+	}
+	return samples
+}
+
+func TestLoudnessReference(t *testing.T) {
+	// BS.1770: a full-scale 997 Hz sine on one channel reads -3.01 LKFS, at
+	// any sample rate
+	for _, rate := range []float64{44100, 48000, 96000} {
+		l := NewLoudness(rate, 1)
+		l.Add(sine(rate, 997, 1, 5, true, true))
+		got, ok := l.Integrated()
+		if !ok || math.Abs(got-(-3.01)) > 0.05 {
+			t.Errorf("%v Hz mono: %.3f LUFS, %v", rate, got, ok)
+		}
+
+		l = NewLoudness(rate, 2)
+		l.Add(sine(rate, 997, 1, 5, true, false))
+		if got, ok := l.Integrated(); !ok || math.Abs(got-(-3.01)) > 0.05 {
+			t.Errorf("%v Hz left only: %.3f LUFS, %v", rate, got, ok)
+		}
+
+		// Both channels add up
+		l = NewLoudness(rate, 2)
+		l.Add(sine(rate, 997, 1, 5, true, true))
+		if got, ok := l.Integrated(); !ok || math.Abs(got) > 0.05 {
+			t.Errorf("%v Hz stereo: %.3f LUFS, %v", rate, got, ok)
+		}
+	}
+
+	// Halving the amplitude takes 6 dB off
+	l := NewLoudness(48000, 1)
+	l.Add(sine(48000, 997, 0.5, 5, true, true))
+	if got, _ := l.Integrated(); math.Abs(got-(-9.03)) > 0.05 {
+		t.Errorf("half scale: %.3f LUFS", got)
+	}
+}
+
+func TestLoudnessGating(t *testing.T) {
+	const rate = 48000
+	tone := sine(rate, 997, 0.5, 5, true, true)
+
+	// Silence around the tone falls below the absolute gate and quiet
+	// passages below the relative one, so neither lowers the reading by the
+	// 9.5 dB they would take off an ungated average; only the blocks that
+	// overlap the tone's edges count a little
+	l := NewLoudness(rate, 1)
+	l.Add(make([][2]float64, 20*rate))
+	l.Add(tone)
+	l.Add(sine(rate, 997, 0.001, 20, true, true))
+	got, ok := l.Integrated()
+	if !ok || math.Abs(got-(-9.03)) > 0.3 {
+		t.Errorf("tone between silence: %.3f LUFS, %v", got, ok)
+	}
+
+	l = NewLoudness(rate, 1)
+	l.Add(make([][2]float64, 5*rate))
+	if _, ok := l.Integrated(); ok {
+		t.Error("silence measured")
+	}
+	l = NewLoudness(rate, 1)
+	l.Add(tone[:rate/5])
+	if _, ok := l.Integrated(); ok {
+		t.Error("audio shorter than a block measured")
+	}
+}
@@ -0,0 +1,213 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/faiface/beep"
+)
+
+// Options control offline analysis.
+type Options struct {
+	// Hop is the distance between band frames in samples.
+	Hop int
+	// WindowSize is the FFT size of each band frame, a power of two.
+	WindowSize int
+	// Bands is the number of frequency bands per frame.
+	Bands int
+}
+
+// DefaultOptions returns the settings used when none are given.
+func DefaultOptions() Options {
+	return Options{Hop: 1024, WindowSize: 2048, Bands: 32}
+}
+
+// Validate reports options that cannot be used.
+func (o Options) Validate() error {
+	switch {
+	case o.Hop < 1:
+		return fmt.Errorf("hop: %d must be at least 1", o.Hop)
+	case o.WindowSize < 64 || o.WindowSize&(o.WindowSize-1) != 0:
+		return fmt.Errorf("window size: %d must be a power of two of at least 64", o.WindowSize)
+	case o.Bands < 1 || o.Bands > 1024:
+		return fmt.Errorf("bands: %d is out of range (1 to 1024)", o.Bands)
+	}
+	return nil
+}
+
+// Report holds the features of a whole file.
+type Report struct {
+	Duration   float64   `json:"duration"` // seconds
+	SampleRate int       `json:"sample_rate"`
+	Channels   int       `json:"channels"`
+	Peak       float64   `json:"peak"`     // dBFS
+	RMS        float64   `json:"rms"`      // dBFS
+	Loudness   *float64  `json:"loudness"` // integrated, LUFS; null when too short or silent
+	BPM        float64   `json:"bpm"`      // 0 when no tempo was found
+	Beats      []float64 `json:"beats"`    // seconds
+	Frames     Frames    `json:"frames"`
+}
+
+// Frames are band levels measured every Hop samples.
+type Frames struct {
+	Hop        int         `json:"hop"`         // samples
+	HopSeconds float64     `json:"hop_seconds"` // seconds
+	WindowSize int         `json:"window_size"` // samples
+	Edges      []float64   `json:"band_edges"`  // Hz, one more than the number of bands
+	Levels     [][]float64 `json:"levels"`      // dBFS, one row per frame
+}
+
+// Beat detection uses the same window the player looks at every frame
+const (
+	beatWindow = 1024
+	beatHop    = 512
+)
+
+// Analyze reads s to the end and measures its features.
+func Analyze(s beep.Streamer, format beep.Format, opts Options) (*Report, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	rate := float64(format.SampleRate)
+	bands := NewBandAnalyzer(opts.WindowSize, rate, opts.Bands)
+	loudness := NewLoudness(rate, format.NumChannels)
+	beats := NewBeatTracker()
+
+	r := &Report{
+		SampleRate: int(format.SampleRate),
+		Channels:   format.NumChannels,
+		Beats:      []float64{},
+		Frames: Frames{
+			Hop:        opts.Hop,
+			HopSeconds: float64(opts.Hop) / rate,
+			WindowSize: opts.WindowSize,
+			Edges:      bands.Edges(),
+			Levels:     [][]float64{},
+		},
+	}
+
+	bandFrames := newFramer(opts.WindowSize, opts.Hop, func(window []float64, _ int) {
+		levels := make([]float64, opts.Bands)
+		bands.Levels(window, levels)
+		r.Frames.Levels = append(r.Frames.Levels, levels)
+	})
+	beatFrames := newFramer(beatWindow, beatHop, func(window []float64, start int) {
+		var energy float64
+		for _, v := range window {
+			energy += v * v
+		}
+		energy /= float64(len(window))
+		t := float64(start+len(window)) / rate
+		if beats.Process(energy, t) {
+			r.Beats = append(r.Beats, t)
+		}
+	})
+
+	var (
+		total   int
+		peak    float64
+		sumSq   float64
+		buf     = make([][2]float64, 4096)
+		mono    = make([]float64, len(buf))
+		channel = float64(min(max(format.NumChannels, 1), 2))
+	)
+	for {
+		n, ok := s.Stream(buf)
+		for i, smp := range buf[:n] {
+			for ch := 0; ch < int(channel); ch++ {
+				peak = math.Max(peak, math.Abs(smp[ch]))
+				sumSq += smp[ch] * smp[ch]
+			}
+			mono[i] = (smp[0] + smp[1]) * 0.5
+		}
+		loudness.Add(buf[:n])
+		bandFrames.push(mono[:n])
+		beatFrames.push(mono[:n])
+		total += n
+		if !ok {
+			break
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	bandFrames.flush()
+
+	r.Duration = float64(total) / rate
+	r.Peak = decibels(peak)
+	if total > 0 {
+		r.RMS = decibels(math.Sqrt(sumSq / (float64(total) * channel)))
+	} else {
+		r.RMS = decibels(0)
+	}
+	if lufs, ok := loudness.Integrated(); ok {
+		r.Loudness = &lufs
+	}
+	r.BPM = tempo(r.Beats)
+	return r, nil
+}
+
+// tempo estimates the BPM of a whole track from the median beat interval.
+func tempo(beats []float64) float64 {
+	var intervals []float64
+	for i := 1; i < len(beats); i++ {
+		if d := beats[i] - beats[i-1]; d >= 0.25 && d <= 2 {
+			intervals = append(intervals, d)
+		}
+	}
+	if len(intervals) < 4 {
+		return 0
+	}
+	return FoldTempo(60 / median(intervals))
+}
+
+// framer cuts a stream of samples into overlapping windows.
+type framer struct {
+	size, hop int
+	buf       []float64
+	start     int // stream position of buf[0]
+	total     int // samples pushed so far
+	skip      int // samples still to drop when the hop is longer than the window
+	fn        func(window []float64, start int)
+}
+
+func newFramer(size, hop int, fn func(window []float64, start int)) *framer {
+	return &framer{size: size, hop: hop, fn: fn}
+}
+
+func (f *framer) push(samples []float64) {
+	f.total += len(samples)
+	if f.skip > 0 {
+		d := min(f.skip, len(samples))
+		samples = samples[d:]
+		f.skip -= d
+	}
+	f.buf = append(f.buf, samples...)
+	for len(f.buf) >= f.size {
+		f.fn(f.buf[:f.size], f.start)
+		f.advance()
+	}
+}
+
+// flush emits the windows that start before the end of the stream, padded
+// with silence.
+func (f *framer) flush() {
+	for len(f.buf) > 0 && f.start < f.total {
+		window := make([]float64, f.size)
+		copy(window, f.buf)
+		f.fn(window, f.start)
+		f.advance()
+	}
+}
+
+func (f *framer) advance() {
+	if f.hop >= len(f.buf) {
+		// The hop may skip samples that have not arrived yet
+		f.start += f.hop
+		f.skip = f.hop - len(f.buf)
+		f.buf = f.buf[:0]
+		return
+	}
+	f.buf = f.buf[f.hop:]
+	f.start += f.hop
+}
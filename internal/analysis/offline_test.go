@@ -0,0 +1,125 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faiface/beep"
+)
+
+func TestFramer(t *testing.T) {
+	for _, tc := range []struct {
+		size, hop, total, chunk int
+	}{
+		{2048, 1024, 10000, 4096},
+		{2048, 1024, 10240, 333},
+		{1024, 512, 100, 4096}, // shorter than one window
+		{64, 100, 1000, 7},     // the hop skips samples
+		{64, 64, 640, 64},
+	} {
+		samples := make([]float64, tc.total)
+		for i := range samples {
+			samples[i] = float64(i)
+		}
+		var starts []int
+		f := newFramer(tc.size, tc.hop, func(window []float64, start int) {
+			if len(window) != tc.size {
+				t.Errorf("%+v: window of %d samples", tc, len(window))
+			}
+			// Windows hold the samples from their start, padded with silence
+			for i, v := range window {
+				want := 0.0
+				if start+i < tc.total {
+					want = float64(start + i)
+				}
+				if v != want {
+					t.Errorf("%+v: window at %d: sample %d is %v", tc, start, i, v)
+					break
+				}
+			}
+			starts = append(starts, start)
+		})
+		for i := 0; i < tc.total; i += tc.chunk {
+			f.push(samples[i:min(i+tc.chunk, tc.total)])
+		}
+		f.flush()
+
+		// One window for every hop that starts inside the stream
+		want := (tc.total + tc.hop - 1) / tc.hop
+		if len(starts) != want {
+			t.Errorf("%+v: %d windows, want %d", tc, len(starts), want)
+			continue
+		}
+		for i, s := range starts {
+			if s != i*tc.hop {
+				t.Errorf("%+v: window %d starts at %d", tc, i, s)
+				break
+			}
+		}
+	}
+}
+
+// clicks is a stereo click track: a short burst of noise on every beat.
+type clicks struct {
+	rate, bpm float64
+	pos, n    int
+}
+
+func (c *clicks) Stream(samples [][2]float64) (int, bool) {
+	if c.pos >= c.n {
+		return 0, false
+	}
+	k := min(len(samples), c.n-c.pos)
+	period := int(c.rate * 60 / c.bpm)
+	for i := range samples[:k] {
+		v := 0.0
+		if p := (c.pos + i) % period; p < int(c.rate/50) {
+			v = 0.8 * math.Sin(float64(p)*0.7)
+		}
+		samples[i] = [2]float64{v, v}
+	}
+	c.pos += k
+	return k, true
+}
+
+func (c *clicks) Err() error { return nil }
+
+func TestAnalyze(t *testing.T) {
+	const rate = 44100
+	s := &clicks{rate: rate, bpm: 120, n: 20 * rate}
+	opts := DefaultOptions()
+	r, err := Analyze(s, beep.Format{SampleRate: rate, NumChannels: 2, Precision: 2}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Duration != 20 || r.SampleRate != rate || r.Channels != 2 {
+		t.Errorf("report %v s, %d Hz, %d channels", r.Duration, r.SampleRate, r.Channels)
+	}
+	if math.Abs(r.BPM-120) > 2 {
+		t.Errorf("tempo %.1f BPM", r.BPM)
+	}
+	if len(r.Beats) < 30 {
+		t.Errorf("%d beats", len(r.Beats))
+	}
+	if want := (20*rate + opts.Hop - 1) / opts.Hop; len(r.Frames.Levels) != want {
+		t.Errorf("%d frames, want %d", len(r.Frames.Levels), want)
+	}
+	if r.Frames.HopSeconds != float64(opts.Hop)/rate || len(r.Frames.Edges) != opts.Bands+1 {
+		t.Errorf("frames %v s, %d edges", r.Frames.HopSeconds, len(r.Frames.Edges))
+	}
+	if r.Peak > 0 || r.Peak < -3 || r.Loudness == nil {
+		t.Errorf("peak %.2f dB, loudness %v", r.Peak, r.Loudness)
+	}
+
+	if _, err := Analyze(s, beep.Format{SampleRate: rate, NumChannels: 2}, Options{Hop: 0, WindowSize: 2048, Bands: 8}); err == nil {
+		t.Error("invalid options accepted")
+	}
+}
+
+func TestFoldTempo(t *testing.T) {
+	for in, want := range map[float64]float64{60: 120, 240: 120, 90: 90, 400: 100, 0: 0, -5: 0} {
+		if got := FoldTempo(in); got != want {
+			t.Errorf("FoldTempo(%v) = %v, want %v", in, got, want)
+		}
+	}
+}
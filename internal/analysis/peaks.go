@@ -0,0 +1,105 @@
+package analysis
+
+import (
+	"math"
+
+	"github.com/faiface/beep"
+)
+
+// Peak summarizes the mono signal of one column of a waveform overview.
+type Peak struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	RMS float64 `json:"rms"`
+}
+
+// Peaks reads s to the end and splits it into columns of equal duration.
+// length is the expected number of samples (for example the streamer's Len);
+// samples past it are counted in the last column.
+func Peaks(s beep.Streamer, length, columns int) ([]Peak, error) {
+	peaks := make([]Peak, columns)
+	sums := make([]float64, columns)
+	counts := make([]int, columns)
+	if columns == 0 {
+		return peaks, nil
+	}
+	length = max(length, 1)
+
+	buf := make([][2]float64, 4096)
+	pos := 0
+	for {
+		n, ok := s.Stream(buf)
+		for _, smp := range buf[:n] {
+			col := min(pos*columns/length, columns-1)
+			mono := (smp[0] + smp[1]) * 0.5
+			p := &peaks[col]
+			if counts[col] == 0 || mono < p.Min {
+				p.Min = mono
+			}
+			if counts[col] == 0 || mono > p.Max {
+				p.Max = mono
+			}
+			sums[col] += mono * mono
+			counts[col]++
+			pos++
+		}
+		if !ok {
+			break
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	for i := range peaks {
+		if counts[i] > 0 {
+			peaks[i].RMS = math.Sqrt(sums[i] / float64(counts[i]))
+		}
+	}
+	return peaks, nil
+}
+
+// Spectrum reads s to the end and measures the band levels (dBFS) of
+// columns of equal duration, each from one FFT window at the column's start.
+// length is the expected number of samples as for Peaks.
+func Spectrum(s beep.Streamer, format beep.Format, length, columns, bands int) ([][]float64, []float64, error) {
+	const windowSize = 2048
+	analyzer := NewBandAnalyzer(windowSize, float64(format.SampleRate), bands)
+	hop := max((length+columns-1)/max(columns, 1), 1)
+
+	levels := make([][]float64, 0, columns)
+	frames := newFramer(windowSize, hop, func(window []float64, _ int) {
+		if len(levels) == columns {
+			return
+		}
+		row := make([]float64, bands)
+		analyzer.Levels(window, row)
+		levels = append(levels, row)
+	})
+
+	buf := make([][2]float64, 4096)
+	mono := make([]float64, len(buf))
+	for {
+		n, ok := s.Stream(buf)
+		for i, smp := range buf[:n] {
+			mono[i] = (smp[0] + smp[1]) * 0.5
+		}
+		frames.push(mono[:n])
+		if !ok {
+			break
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, nil, err
+	}
+	frames.flush()
+
+	// A file shorter than announced leaves the last columns silent
+	for len(levels) < columns {
+		row := make([]float64, bands)
+		for i := range row {
+			row[i] = decibels(0)
+		}
+		levels = append(levels, row)
+	}
+	return levels, analyzer.Edges(), nil
+}
@@ -0,0 +1,60 @@
+// Package audio opens the supported audio file formats as beep streamers.
+package audio
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/flac"
+	"github.com/faiface/beep/mp3"
+	"github.com/faiface/beep/wav"
+)
+
+// Extensions lists the file types that can be decoded, in lower case.
+var Extensions = []string{".wav", ".mp3", ".flac"}
+
+// IsSupported reports whether path has a decodable extension.
+func IsSupported(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Open decodes the file at path based on its extension. Closing the returned
+// streamer also closes the file.
+func Open(path string) (beep.StreamSeekCloser, beep.Format, error) {
+	ext := filepath.Ext(path)
+	if !IsSupported(path) {
+		return nil, beep.Format{}, errors.New("unsupported file type: " + ext)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+
+	var (
+		streamer beep.StreamSeekCloser
+		format   beep.Format
+	)
+	switch strings.ToLower(ext) {
+	case ".wav":
+		streamer, format, err = wav.Decode(f)
+	case ".mp3":
+		streamer, format, err = mp3.Decode(f)
+	case ".flac":
+		streamer, format, err = flac.Decode(f)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, beep.Format{}, err
+	}
+	return streamer, format, nil
+}
@@ -0,0 +1,228 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// PCMFormat describes headerless PCM such as the output of arecord or parec.
+type PCMFormat struct {
+	Encoding   string // s16le, s24le, s32le or f32le
+	SampleRate int
+	Channels   int // 1 or 2
+}
+
+// pcmEncodings maps encodings to their sample size and decoder.
+var pcmEncodings = map[string]struct {
+	size   int
+	decode func(b []byte) float64
+}{
+	"s16le": {2, func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15) }},
+	"s24le": {3, func(b []byte) float64 {
+		v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+		return float64(v) / (1 << 23)
+	}},
+	"s32le": {4, func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }},
+	"f32le": {4, func(b []byte) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) }},
+}
+
+// ParsePCMFormat reads a format written as encoding:rate:channels, for
+// example s16le:44100:2.
+func ParsePCMFormat(s string) (PCMFormat, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return PCMFormat{}, fmt.Errorf("%q is not encoding:rate:channels", s)
+	}
+	rate, err1 := strconv.Atoi(parts[1])
+	channels, err2 := strconv.Atoi(parts[2])
+	if err1 != nil || err2 != nil {
+		return PCMFormat{}, fmt.Errorf("%q is not encoding:rate:channels", s)
+	}
+	f := PCMFormat{Encoding: strings.ToLower(parts[0]), SampleRate: rate, Channels: channels}
+	return f, f.Validate()
+}
+
+// Validate reports formats that cannot be read.
+func (f PCMFormat) Validate() error {
+	if _, ok := pcmEncodings[f.Encoding]; !ok {
+		return fmt.Errorf("encoding %q is not one of s16le, s24le, s32le, f32le", f.Encoding)
+	}
+	if f.SampleRate < 8000 || f.SampleRate > 384000 {
+		return fmt.Errorf("sample rate %d is out of range (8000 to 384000)", f.SampleRate)
+	}
+	if f.Channels < 1 || f.Channels > 2 {
+		return fmt.Errorf("channels %d is out of range (1 to 2)", f.Channels)
+	}
+	return nil
+}
+
+func (f PCMFormat) String() string {
+	return fmt.Sprintf("%s:%d:%d", f.Encoding, f.SampleRate, f.Channels)
+}
+
+// Live sources keep at most this much audio buffered; when the producer runs
+// ahead of the output the oldest samples are dropped to keep latency low.
+const (
+	pcmMaxLatency    = 200 * time.Millisecond
+	pcmTargetLatency = 100 * time.Millisecond
+)
+
+// pcmSource reads raw PCM on its own goroutine so a slow or stalled producer
+// never blocks playback; missing samples play as silence.
+type pcmSource struct {
+	format beep.Format
+	pcm    PCMFormat
+
+	mu      sync.Mutex
+	r       io.ReadCloser
+	pending [][2]float64
+	pos     int
+	done    bool
+	closed  bool
+	err     error
+}
+
+// OpenPCM starts reading PCM from path, or from standard input when path is
+// "-". Named pipes are opened in the background, so this does not wait for
+// the writer to appear.
+func OpenPCM(path string, format PCMFormat) (Source, error) {
+	if err := format.Validate(); err != nil {
+		return nil, err
+	}
+	s := &pcmSource{
+		format: beep.Format{SampleRate: beep.SampleRate(format.SampleRate), NumChannels: format.Channels, Precision: 2},
+		pcm:    format,
+	}
+	go s.run(path)
+	return s, nil
+}
+
+func (s *pcmSource) run(path string) {
+	var r io.ReadCloser = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			s.finish(err)
+			return
+		}
+		r = f
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = r.Close()
+		return
+	}
+	s.r = r
+	s.mu.Unlock()
+
+	enc := pcmEncodings[s.pcm.Encoding]
+	frameSize := enc.size * s.pcm.Channels
+	maxPending := s.format.SampleRate.N(pcmMaxLatency)
+	targetPending := s.format.SampleRate.N(pcmTargetLatency)
+	buf := make([]byte, 1024*frameSize)
+	fill := 0
+	for {
+		n, err := r.Read(buf[fill:])
+		fill += n
+		frames := fill / frameSize
+
+		decoded := make([][2]float64, frames)
+		for i := range decoded {
+			b := buf[i*frameSize:]
+			left := enc.decode(b)
+			right := left
+			if s.pcm.Channels == 2 {
+				right = enc.decode(b[enc.size:])
+			}
+			decoded[i] = [2]float64{left, right}
+		}
+		// Keep a partial frame for the next read
+		fill = copy(buf, buf[frames*frameSize:fill])
+
+		s.mu.Lock()
+		s.pending = append(s.pending, decoded...)
+		if len(s.pending) > maxPending {
+			s.pending = append(s.pending[:0:0], s.pending[len(s.pending)-targetPending:]...)
+		}
+		s.mu.Unlock()
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			s.finish(err)
+			return
+		}
+	}
+}
+
+func (s *pcmSource) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	if !s.closed {
+		s.err = err
+	}
+}
+
+// Stream plays the buffered samples, padding with silence while waiting for
+// more. It ends once the input is closed and drained.
+func (s *pcmSource) Stream(samples [][2]float64) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done && len(s.pending) == 0 {
+		return 0, false
+	}
+	n := copy(samples, s.pending)
+	s.pending = s.pending[n:]
+	if !s.done {
+		for i := n; i < len(samples); i++ {
+			samples[i] = [2]float64{}
+		}
+		n = len(samples)
+	}
+	s.pos += n
+	return n, true
+}
+
+func (s *pcmSource) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *pcmSource) Len() int            { return 0 }
+func (s *pcmSource) Format() beep.Format { return s.format }
+func (s *pcmSource) Live() bool          { return true }
+
+func (s *pcmSource) Position() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pos
+}
+
+func (s *pcmSource) Seek(int) error {
+	return errors.New("cannot seek a live input")
+}
+
+func (s *pcmSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.done = true
+	if s.r != nil {
+		return s.r.Close()
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package audio
+
+import (
+	"github.com/faiface/beep"
+)
+
+// Source is something the player can play: a decoded file or a live capture.
+// Live sources have no length (Len returns 0) and cannot seek.
+type Source interface {
+	beep.StreamSeekCloser
+	Format() beep.Format
+	Live() bool
+}
+
+type fileSource struct {
+	beep.StreamSeekCloser
+	format beep.Format
+}
+
+func (f *fileSource) Format() beep.Format { return f.format }
+func (f *fileSource) Live() bool          { return false }
+
+// OpenSource opens the file or http(s) URL at path as a Source.
+func OpenSource(path string) (Source, error) {
+	if IsURL(path) {
+		return OpenURL(path)
+	}
+	streamer, format, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSource{StreamSeekCloser: streamer, format: format}, nil
+}
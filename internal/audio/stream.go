@@ -0,0 +1,608 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/flac"
+	"github.com/faiface/beep/mp3"
+	"github.com/faiface/beep/vorbis"
+)
+
+// Streams buffer this much compressed audio from the network, which rides
+// out short stalls, and decode at most streamAhead ahead of playback.
+const (
+	streamBuffer = 256 << 10
+	streamAhead  = time.Second
+)
+
+// A dropped connection is retried this many times, waiting twice as long
+// before every attempt, starting at streamBackoff.
+const (
+	streamRetries = 5
+	streamBackoff = 500 * time.Millisecond
+)
+
+// streamProbe bounds how long a server may take, after its headers, to send
+// a playlist or enough audio to tell the format. A variable for tests.
+var streamProbe = 10 * time.Second
+
+// IsURL reports whether path is an http or https URL rather than a file.
+func IsURL(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// Titler is implemented by sources that know what is currently playing, such
+// as internet radio sending ICY metadata.
+type Titler interface {
+	Title() string
+}
+
+// streamClient accepts the "ICY 200 OK" status line of SHOUTcast servers,
+// which net/http rejects.
+var streamClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := (&net.Dialer{Timeout: 10 * time.Second}).DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &icyConn{Conn: conn}, nil
+		},
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+	},
+}
+
+// httpSource plays an MP3, Ogg Vorbis or FLAC stream. The body is read into
+// a bounded ring and decoded on a separate goroutine, so neither the network
+// nor the decoder can block playback; gaps play as silence. A dropped
+// connection is reopened. Streams cannot seek.
+type httpSource struct {
+	url    string
+	format beep.Format // of the first connection; later ones are resampled to it
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	room    *sync.Cond // signaled when playback has taken samples
+	pending [][2]float64
+	pos     int
+	name    string // icy-name of the station
+	title   string // StreamTitle of the current song
+	done    bool
+	err     error
+}
+
+// streamConn is one connection with its decoder.
+type streamConn struct {
+	decoder beep.StreamSeekCloser
+	format  beep.Format
+	ring    *ring
+	// finite is set for responses with a known length, which end normally
+	// instead of being reconnected when they run out
+	finite bool
+}
+
+// OpenURL connects to an http(s) URL and starts decoding it in the
+// background. It returns once the stream's format is known, which can take
+// a while on a slow network, so the player calls it off the game goroutine.
+func OpenURL(url string) (Source, error) {
+	s := &httpSource{url: url}
+	s.room = sync.NewCond(&s.mu)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	conn, err := s.connect(url, 0)
+	if err != nil {
+		s.cancel()
+		return nil, err
+	}
+	s.format = conn.format
+	go s.run(conn)
+	return s, nil
+}
+
+// connect requests url and sets up a decoder for the response. Playlists
+// served in place of audio are followed to their first entry.
+func (s *httpSource) connect(url string, depth int) (*streamConn, error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Icy-MetaData", "1")
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	// A server can send its headers and then nothing, which would leave the
+	// playlist reader or the decoder waiting forever
+	probe := time.AfterFunc(streamProbe, func() { resp.Body.Close() })
+	errProbe := fmt.Errorf("%s: no data within %v", url, streamProbe)
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if isPlaylistType(contentType) || (!strings.HasPrefix(contentType, "audio/") && isPlaylistPath(url)) {
+		defer resp.Body.Close()
+		if depth > 2 {
+			probe.Stop()
+			return nil, fmt.Errorf("%s: playlists nested too deeply", url)
+		}
+		entry, err := firstPlaylistURL(io.LimitReader(resp.Body, 64<<10))
+		if !probe.Stop() {
+			return nil, errProbe
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", url, err)
+		}
+		return s.connect(entry, depth+1)
+	}
+
+	var body io.Reader = resp.Body
+	if interval, err := strconv.Atoi(resp.Header.Get("Icy-Metaint")); err == nil && interval > 0 {
+		body = &icyReader{r: resp.Body, interval: interval, left: interval, onMeta: s.setMeta}
+	}
+	s.mu.Lock()
+	s.name = resp.Header.Get("Icy-Name")
+	s.mu.Unlock()
+
+	r := newRing(streamBuffer, resp.Body)
+	go r.fill(body)
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(4)
+	rc := struct {
+		io.Reader
+		io.Closer
+	}{br, r}
+
+	var (
+		decoder beep.StreamSeekCloser
+		format  beep.Format
+	)
+	switch kind := streamKind(contentType, url, head); kind {
+	case "mp3":
+		decoder, format, err = mp3.Decode(rc)
+	case "ogg":
+		decoder, format, err = vorbis.Decode(rc)
+	case "flac":
+		decoder, format, err = flac.Decode(rc)
+	default:
+		err = fmt.Errorf("unsupported stream type %q", contentType)
+	}
+	if !probe.Stop() {
+		r.Close()
+		return nil, errProbe
+	}
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	return &streamConn{decoder: decoder, format: format, ring: r, finite: resp.ContentLength >= 0}, nil
+}
+
+// run decodes connections until the stream ends, it is closed or it cannot
+// be reopened.
+func (s *httpSource) run(conn *streamConn) {
+	failures := 0
+	for {
+		played := s.decode(conn)
+		_ = conn.decoder.Close()
+		if s.ctx.Err() != nil {
+			return
+		}
+		// A network error explains a decoder error, and some decoders
+		// report the end of their input as io.EOF
+		err := conn.ring.Err()
+		if err == nil && !errors.Is(conn.decoder.Err(), io.EOF) {
+			err = conn.decoder.Err()
+		}
+		if conn.finite && err == nil {
+			s.finish(nil)
+			return
+		}
+		if err == nil {
+			err = errors.New("connection closed")
+		}
+
+		// Retries start over after a connection that played, so only a
+		// stream that keeps failing right away gives up
+		if played {
+			failures = 0
+		}
+		if conn = s.reconnect(err, &failures); conn == nil {
+			return
+		}
+	}
+}
+
+// reconnect reopens the stream after it failed with cause, waiting longer
+// after every failure.
+func (s *httpSource) reconnect(cause error, failures *int) *streamConn {
+	for *failures < streamRetries {
+		select {
+		case <-time.After(streamBackoff << *failures):
+		case <-s.ctx.Done():
+			return nil
+		}
+		*failures++
+		conn, err := s.connect(s.url, 0)
+		if err == nil {
+			return conn
+		}
+		cause = err
+	}
+	s.finish(fmt.Errorf("stream lost after %d retries: %w", streamRetries, cause))
+	return nil
+}
+
+// decode moves samples from conn into the playback queue until it ends. It
+// reports whether any audio was decoded.
+func (s *httpSource) decode(conn *streamConn) bool {
+	var streamer beep.Streamer = conn.decoder
+	if conn.format.SampleRate != s.format.SampleRate {
+		streamer = beep.Resample(4, conn.format.SampleRate, s.format.SampleRate, streamer)
+	}
+	ahead := s.format.SampleRate.N(streamAhead)
+	buf := make([][2]float64, 512)
+	played := false
+	for {
+		n, ok := streamer.Stream(buf)
+		s.mu.Lock()
+		for len(s.pending) >= ahead && s.ctx.Err() == nil {
+			s.room.Wait()
+		}
+		s.pending = append(s.pending, buf[:n]...)
+		s.mu.Unlock()
+		played = played || n > 0
+		if !ok || s.ctx.Err() != nil {
+			return played
+		}
+	}
+}
+
+func (s *httpSource) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	s.err = err
+}
+
+func (s *httpSource) setMeta(meta string) {
+	title, ok := streamTitle(meta)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	s.title = title
+	s.mu.Unlock()
+}
+
+// Stream plays the decoded samples, padding with silence while waiting for
+// more.
+func (s *httpSource) Stream(samples [][2]float64) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done && len(s.pending) == 0 {
+		return 0, false
+	}
+	n := copy(samples, s.pending)
+	s.pending = s.pending[n:]
+	s.room.Signal()
+	if !s.done {
+		for i := n; i < len(samples); i++ {
+			samples[i] = [2]float64{}
+		}
+		n = len(samples)
+	}
+	s.pos += n
+	return n, true
+}
+
+// Title returns the current song title, or the station name until the
+// first title arrives.
+func (s *httpSource) Title() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.title != "" {
+		return s.title
+	}
+	return s.name
+}
+
+func (s *httpSource) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *httpSource) Len() int            { return 0 }
+func (s *httpSource) Format() beep.Format { return s.format }
+func (s *httpSource) Live() bool          { return true }
+
+func (s *httpSource) Position() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pos
+}
+
+func (s *httpSource) Seek(int) error {
+	return errors.New("cannot seek a network stream")
+}
+
+func (s *httpSource) Close() error {
+	s.cancel()
+	s.mu.Lock()
+	s.done = true
+	s.room.Broadcast()
+	s.mu.Unlock()
+	return nil
+}
+
+// streamKind picks a decoder from the content type, then from the first
+// bytes of the stream and finally from the URL's extension.
+func streamKind(contentType, url string, head []byte) string {
+	switch contentType {
+	case "audio/mpeg", "audio/mp3", "audio/mpeg3", "audio/x-mpeg":
+		return "mp3"
+	case "audio/ogg", "application/ogg", "audio/vorbis", "audio/x-ogg":
+		return "ogg"
+	case "audio/flac", "audio/x-flac":
+		return "flac"
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return "ogg"
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return "flac"
+	case bytes.HasPrefix(head, []byte("ID3")), len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		return "mp3"
+	}
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	switch strings.ToLower(path.Ext(url)) {
+	case ".mp3":
+		return "mp3"
+	case ".ogg", ".oga":
+		return "ogg"
+	case ".flac":
+		return "flac"
+	}
+	return ""
+}
+
+func isPlaylistType(contentType string) bool {
+	switch contentType {
+	case "audio/x-scpls", "audio/scpls", "audio/x-mpegurl", "audio/mpegurl":
+		return true
+	}
+	return false
+}
+
+func isPlaylistPath(url string) bool {
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	switch strings.ToLower(path.Ext(url)) {
+	case ".m3u", ".pls":
+		return true
+	}
+	return false
+}
+
+// firstPlaylistURL returns the first http(s) entry of an M3U or PLS
+// playlist.
+func firstPlaylistURL(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+		if key, value, ok := strings.Cut(line, "="); ok && strings.HasPrefix(strings.ToLower(key), "file") {
+			line = strings.TrimSpace(value)
+		}
+		if IsURL(line) {
+			return line, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("playlist has no stream URL")
+}
+
+// icyReader removes the metadata blocks that ICY servers insert after every
+// interval bytes of audio.
+type icyReader struct {
+	r        io.Reader
+	interval int
+	left     int // audio bytes until the next metadata block
+	onMeta   func(string)
+}
+
+func (ir *icyReader) Read(p []byte) (int, error) {
+	if ir.left == 0 {
+		if err := ir.readMeta(); err != nil {
+			return 0, err
+		}
+		ir.left = ir.interval
+	}
+	if len(p) > ir.left {
+		p = p[:ir.left]
+	}
+	n, err := ir.r.Read(p)
+	ir.left -= n
+	return n, err
+}
+
+// readMeta reads a block of one length byte, counting 16 byte units,
+// followed by the NUL padded metadata. Most blocks are empty.
+func (ir *icyReader) readMeta() error {
+	var size [1]byte
+	if _, err := io.ReadFull(ir.r, size[:]); err != nil {
+		return err
+	}
+	if size[0] == 0 {
+		return nil
+	}
+	meta := make([]byte, int(size[0])*16)
+	if _, err := io.ReadFull(ir.r, meta); err != nil {
+		return err
+	}
+	ir.onMeta(string(bytes.TrimRight(meta, "\x00")))
+	return nil
+}
+
+// streamTitle extracts the title from ICY metadata, which looks like
+// StreamTitle='Artist - Song';StreamUrl='http://example.com';. Titles may
+// contain quotes, so the value ends at "';" or at the end of the block.
+func streamTitle(meta string) (string, bool) {
+	const key = "StreamTitle='"
+	i := strings.Index(meta, key)
+	if i < 0 {
+		return "", false
+	}
+	value := meta[i+len(key):]
+	if end := strings.Index(value, "';"); end >= 0 {
+		value = value[:end]
+	} else {
+		value = strings.TrimSuffix(value, "'")
+	}
+	return strings.TrimSpace(value), true
+}
+
+// icyConn rewrites the "ICY" status line of SHOUTcast v1 servers to HTTP/1.0.
+type icyConn struct {
+	net.Conn
+	checked bool
+	extra   []byte
+}
+
+func (c *icyConn) Read(p []byte) (int, error) {
+	if len(c.extra) > 0 {
+		n := copy(p, c.extra)
+		c.extra = c.extra[n:]
+		return n, nil
+	}
+	n, err := c.Conn.Read(p)
+	if !c.checked && n > 0 {
+		c.checked = true
+		if bytes.HasPrefix(p[:n], []byte("ICY ")) {
+			c.extra = append([]byte("HTTP/1.0 "), p[4:n]...)
+			return c.Read(p)
+		}
+	}
+	return n, err
+}
+
+// ring is a bounded buffer between the network and the decoder. fill blocks
+// while it is full and Read blocks while it is empty.
+type ring struct {
+	body   io.Closer
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    []byte
+	start  int
+	size   int
+	err    error // set when fill stops; io.EOF at the end of the body
+	closed bool
+}
+
+func newRing(capacity int, body io.Closer) *ring {
+	r := &ring{body: body, buf: make([]byte, capacity)}
+	r.cond = sync.NewCond(&r.mu)
+	return r
+}
+
+// fill copies src into the ring until it ends or the ring is closed.
+func (r *ring) fill(src io.Reader) {
+	defer r.body.Close()
+	chunk := make([]byte, 16<<10)
+	for {
+		n, err := src.Read(chunk)
+		if !r.write(chunk[:n]) {
+			return
+		}
+		if err != nil {
+			r.mu.Lock()
+			r.err = err
+			r.cond.Broadcast()
+			r.mu.Unlock()
+			return
+		}
+	}
+}
+
+// write adds p, waiting for room. It returns false once the ring is closed.
+func (r *ring) write(p []byte) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for len(p) > 0 {
+		for r.size == len(r.buf) && !r.closed {
+			r.cond.Wait()
+		}
+		if r.closed {
+			return false
+		}
+		end := (r.start + r.size) % len(r.buf)
+		n := copy(r.buf[end:min(len(r.buf), end+len(r.buf)-r.size)], p)
+		r.size += n
+		p = p[n:]
+		r.cond.Broadcast()
+	}
+	return !r.closed
+}
+
+func (r *ring) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for r.size == 0 && r.err == nil && !r.closed {
+		r.cond.Wait()
+	}
+	if r.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if r.size == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.buf[r.start:min(len(r.buf), r.start+r.size)])
+	r.start = (r.start + n) % len(r.buf)
+	r.size -= n
+	r.cond.Broadcast()
+	return n, nil
+}
+
+// Err returns why filling stopped: nil while running or after a clean end.
+func (r *ring) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if errors.Is(r.err, io.EOF) {
+		return nil
+	}
+	return r.err
+}
+
+// Close stops the ring and closes the body, which also ends a fill that is
+// waiting for the network.
+func (r *ring) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	r.cond.Broadcast()
+	r.mu.Unlock()
+	return r.body.Close()
+}
@@ -0,0 +1,316 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iburimskiy/audio-visualization/internal/record"
+)
+
+func TestIcyReader(t *testing.T) {
+	meta := func(s string) []byte {
+		block := make([]byte, (len(s)+15)/16*16)
+		copy(block, s)
+		return append([]byte{byte(len(block) / 16)}, block...)
+	}
+	var data []byte
+	data = append(data, "abcd"...)
+	data = append(data, meta("StreamTitle='Artist - Song';StreamUrl='';")...)
+	data = append(data, "efgh"...)
+	data = append(data, 0) // most blocks are empty
+	data = append(data, "ijkl"...)
+	data = append(data, meta("StreamTitle='It's';")...)
+	data = append(data, "mn"...)
+
+	var titles []string
+	r := &icyReader{r: bytes.NewReader(data), interval: 4, left: 4, onMeta: func(m string) {
+		title, _ := streamTitle(m)
+		titles = append(titles, title)
+	}}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "abcdefghijklmn" {
+		t.Errorf("audio %q", got)
+	}
+	if len(titles) != 2 || titles[0] != "Artist - Song" || titles[1] != "It's" {
+		t.Errorf("titles %q", titles)
+	}
+
+	// A block cut short is an error
+	r = &icyReader{r: bytes.NewReader(data[:10]), interval: 4, left: 4, onMeta: func(string) {}}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Error("truncated metadata read without an error")
+	}
+}
+
+func TestStreamTitle(t *testing.T) {
+	for _, tc := range []struct {
+		meta, title string
+		ok          bool
+	}{
+		{"StreamTitle='Artist - Song';StreamUrl='http://example.com';", "Artist - Song", true},
+		{"StreamTitle='Don't Stop';", "Don't Stop", true},
+		{"StreamTitle='No semicolon'", "No semicolon", true},
+		{"StreamTitle='';", "", true},
+		{"StreamUrl='http://example.com';", "", false},
+	} {
+		title, ok := streamTitle(tc.meta)
+		if title != tc.title || ok != tc.ok {
+			t.Errorf("streamTitle(%q) = %q, %v", tc.meta, title, ok)
+		}
+	}
+}
+
+func TestIcyConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		io.WriteString(server, "ICY 200 OK\r\nicy-name: Radio\r\nContent-Type: audio/mpeg\r\n\r\nbody")
+	}()
+	resp, err := http.ReadResponse(bufio.NewReader(&icyConn{Conn: client}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Icy-Name") != "Radio" || string(body) != "body" {
+		t.Errorf("response %d %v %q", resp.StatusCode, resp.Header, body)
+	}
+}
+
+func TestFirstPlaylistURL(t *testing.T) {
+	for _, list := range []string{
+		"#EXTM3U\n#EXTINF:-1,Radio\nhttp://example.com/radio\n",
+		"\uFEFFhttp://example.com/radio\r\nhttp://example.com/backup\r\n",
+		"[playlist]\nNumberOfEntries=1\nFile1=http://example.com/radio\nTitle1=Radio\n",
+	} {
+		got, err := firstPlaylistURL(strings.NewReader(list))
+		if err != nil || got != "http://example.com/radio" {
+			t.Errorf("%q: %q, %v", list, got, err)
+		}
+	}
+	if _, err := firstPlaylistURL(strings.NewReader("#EXTM3U\nlocal.mp3\n")); err == nil {
+		t.Error("playlist without URLs accepted")
+	}
+}
+
+// testRate keeps the test streams small.
+const testRate = 8000
+
+// testLevel is the level of sample i of a test stream; it is never silent,
+// so decoded audio can be told from the silence played while waiting.
+func testLevel(i int) float64 {
+	return 0.25 + float64(i%50)/200
+}
+
+// flacStream returns n samples encoded as FLAC.
+func flacStream(t *testing.T, n int) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stream.flac")
+	rec, err := record.Start(path, testRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make([][2]float64, n)
+	for i := range samples {
+		samples[i] = [2]float64{testLevel(i), -testLevel(i)}
+	}
+	rec.Write(samples)
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// playAll plays s until it ends and returns the number of decoded samples,
+// leaving out the silence played while waiting for the network.
+func playAll(t *testing.T, s Source) int {
+	t.Helper()
+	buf := make([][2]float64, 1024)
+	decoded := 0
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		n, ok := s.Stream(buf)
+		if !ok {
+			return decoded
+		}
+		for _, smp := range buf[:n] {
+			if smp[0] != 0 {
+				decoded++
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("stream did not end")
+	return 0
+}
+
+func TestOpenURL(t *testing.T) {
+	const n = 3 * 4096
+	data := flacStream(t, n)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/radio.flac":
+			w.Header().Set("Content-Type", "audio/flac")
+			w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+			w.Write(data)
+		case "/list.m3u":
+			w.Header().Set("Content-Type", "audio/x-mpegurl")
+			fmt.Fprintf(w, "#EXTM3U\n#EXTINF:-1,Radio\nhttp://%s/radio.flac\n", r.Host)
+		case "/list.pls":
+			// Served as text, so the extension tells it is a playlist
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprintf(w, "[playlist]\nFile1=http://%s/list.m3u\n", r.Host)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	for _, path := range []string{"/radio.flac", "/list.m3u", "/list.pls"} {
+		s, err := OpenURL(srv.URL + path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if f := s.Format(); f.SampleRate != testRate || f.NumChannels != 2 || !s.Live() {
+			t.Errorf("%s: format %+v", path, f)
+		}
+		if got := playAll(t, s); got != n {
+			t.Errorf("%s: played %d samples, want %d", path, got, n)
+		}
+		if err := s.Err(); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+		s.Close()
+	}
+
+	if _, err := OpenURL(srv.URL + "/missing.flac"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("missing stream: %v", err)
+	}
+}
+
+// TestOpenURLIcy plays a SHOUTcast v1 server, which answers with an ICY
+// status line and interleaves titles with the audio.
+func TestOpenURLIcy(t *testing.T) {
+	const n, interval = 2 * 4096, 1000
+	data := flacStream(t, n)
+	var body []byte
+	for i := 0; i < len(data); i += interval {
+		body = append(body, data[i:min(i+interval, len(data))]...)
+		if i+interval < len(data) {
+			if i == 0 {
+				meta := make([]byte, 32)
+				copy(meta, "StreamTitle='Artist - Song';")
+				body = append(body, 2)
+				body = append(body, meta...)
+			} else {
+				body = append(body, 0)
+			}
+		}
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		req, err := http.ReadRequest(br)
+		if err != nil || req.Header.Get("Icy-MetaData") != "1" {
+			return
+		}
+		fmt.Fprintf(conn, "ICY 200 OK\r\nicy-name: Test Radio\r\nicy-metaint: %d\r\ncontent-type: audio/flac\r\ncontent-length: %d\r\n\r\n", interval, len(body))
+		conn.Write(body)
+	}()
+
+	s, err := OpenURL("http://" + ln.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got := playAll(t, s); got != n {
+		t.Errorf("played %d samples, want %d", got, n)
+	}
+	if title := s.(Titler).Title(); title != "Artist - Song" {
+		t.Errorf("title %q", title)
+	}
+}
+
+func TestOpenURLReconnect(t *testing.T) {
+	const n = 3 * 4096
+	data := flacStream(t, n)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/flac")
+		if requests.Add(1) == 1 {
+			// The first connection drops halfway through
+			w.Write(data[:len(data)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	s, err := OpenURL(srv.URL + "/radio.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	// The second connection starts over, after what the first one played
+	if got := playAll(t, s); got <= n || got >= 2*n {
+		t.Errorf("played %d samples, want between %d and %d", got, n, 2*n)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("%d requests", got)
+	}
+	if err := s.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOpenURLSilentServer(t *testing.T) {
+	defer func(d time.Duration) { streamProbe = d }(streamProbe)
+	streamProbe = 100 * time.Millisecond
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/flac")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	_, err := OpenURL(srv.URL + "/radio.flac")
+	if err == nil || !strings.Contains(err.Error(), "no data") {
+		t.Errorf("silent server: %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("gave up after %v", d)
+	}
+}
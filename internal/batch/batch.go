@@ -0,0 +1,119 @@
+// Package batch runs a task over many input files with a bounded number of
+// workers, skipping inputs whose output already exists.
+package batch
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// Job is one input file and the output it produces.
+type Job struct {
+	Input  string
+	Output string
+}
+
+// Result describes how a job went.
+type Result struct {
+	Job
+	Err     error
+	Skipped bool // the output already existed
+	Elapsed time.Duration
+}
+
+// Summary counts the results of a run.
+type Summary struct {
+	Total    int
+	Done     int
+	Skipped  int
+	Failed   []Result
+	Canceled int // jobs not started because the context was canceled
+}
+
+// Runner processes jobs in parallel.
+type Runner struct {
+	// Workers is the number of jobs processed at once; at least one is used.
+	Workers int
+	// Force processes jobs even if their output already exists.
+	Force bool
+	// Process produces the output of one job. It should write the output
+	// with WriteFile so an interrupted job leaves nothing behind.
+	Process func(ctx context.Context, job Job) error
+	// Progress, if set, is called after each job in the order they finish.
+	// Calls never overlap.
+	Progress func(r Result, finished, total int)
+}
+
+// Run processes jobs until all are finished or ctx is canceled. Jobs that
+// already started are allowed to finish.
+func (r *Runner) Run(ctx context.Context, jobs []Job) Summary {
+	workers := max(r.Workers, 1)
+	queue := make(chan Job)
+	results := make(chan Result)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				results <- r.run(ctx, job)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(queue)
+		for _, job := range jobs {
+			select {
+			case queue <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	s := Summary{Total: len(jobs)}
+	finished := 0
+	for res := range results {
+		finished++
+		switch {
+		case res.Err != nil:
+			s.Failed = append(s.Failed, res)
+		case res.Skipped:
+			s.Skipped++
+		default:
+			s.Done++
+		}
+		if r.Progress != nil {
+			r.Progress(res, finished, len(jobs))
+		}
+	}
+	s.Canceled = len(jobs) - finished
+	return s
+}
+
+func (r *Runner) run(ctx context.Context, job Job) Result {
+	res := Result{Job: job}
+	if !r.Force {
+		if _, err := os.Stat(job.Output); err == nil {
+			res.Skipped = true
+			return res
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			res.Err = err
+			return res
+		}
+	}
+	start := time.Now()
+	res.Err = r.Process(ctx, job)
+	res.Elapsed = time.Since(start)
+	return res
+}
@@ -0,0 +1,226 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// touch creates empty files under dir and returns their paths.
+func touch(t *testing.T, dir string, names ...string) []string {
+	t.Helper()
+	var paths []string
+	for _, name := range names {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func outputs(jobs []Job, outDir string) []string {
+	var rel []string
+	for _, j := range jobs {
+		r, _ := filepath.Rel(outDir, j.Output)
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	return rel
+}
+
+func TestPlan(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	files := touch(t, dir,
+		"music/a/01 Intro.flac", "music/b/01 Intro.flac", "music/b/cover.jpg",
+		"catalog/x/song.mp3", "catalog/y/z/song.mp3", "single/one.wav")
+
+	// Files in different folders are named from the folder they share
+	jobs, err := Plan([]string{files[0], files[1]}, out, ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := outputs(jobs, out), []string{"a/01 Intro.flac.json", "b/01 Intro.flac.json"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("loose files: %q, want %q", got, want)
+	}
+	if jobs[0].Input != files[0] {
+		t.Errorf("input %q", jobs[0].Input)
+	}
+
+	// A single file keeps its own name
+	jobs, err = Plan([]string{files[5]}, out, ".png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := outputs(jobs, out); !reflect.DeepEqual(got, []string{"one.wav.png"}) {
+		t.Errorf("single file: %q", got)
+	}
+
+	// Folders keep the paths below them and skip other files; a playlist's
+	// entries are named like loose files
+	list := filepath.Join(dir, "list.m3u")
+	if err := os.WriteFile(list, []byte("catalog/x/song.mp3\ncatalog/y/z/song.mp3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err = Plan([]string{filepath.Join(dir, "music"), list}, out, ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a/01 Intro.flac.json", "b/01 Intro.flac.json", "x/song.mp3.json", "y/z/song.mp3.json"}
+	if got := outputs(jobs, out); !reflect.DeepEqual(got, want) {
+		t.Errorf("folder and playlist: %q, want %q", got, want)
+	}
+
+	// The same file given twice is planned once
+	jobs, err = Plan([]string{files[5], files[5]}, out, ".json")
+	if err != nil || len(jobs) != 1 {
+		t.Errorf("duplicate input: %v, %v", jobs, err)
+	}
+
+	// A folder's file and a loose file of the same name collide
+	_, err = Plan([]string{filepath.Join(dir, "music", "a"), touch(t, dir, "other/01 Intro.flac")[0]}, out, ".json")
+	if err == nil || !strings.Contains(err.Error(), "would both write") {
+		t.Errorf("collision: %v", err)
+	}
+
+	if _, err := Plan([]string{filepath.Join(dir, "missing.flac")}, out, ".json"); err == nil {
+		t.Error("missing input accepted")
+	}
+}
+
+func TestReadList(t *testing.T) {
+	got, err := ReadList(strings.NewReader("a.flac\n\n# comment\n  b c.mp3  \n"))
+	if err != nil || !reflect.DeepEqual(got, []string{"a.flac", "b c.mp3"}) {
+		t.Errorf("%q, %v", got, err)
+	}
+}
+
+func TestRunSkipsExisting(t *testing.T) {
+	dir := t.TempDir()
+	var jobs []Job
+	for _, name := range []string{"a", "b", "c"} {
+		jobs = append(jobs, Job{Input: name, Output: filepath.Join(dir, name+".json")})
+	}
+	touch(t, dir, "b.json")
+
+	var processed []string
+	runner := &Runner{Workers: 2, Process: func(_ context.Context, job Job) error {
+		if job.Input == "c" {
+			return errors.New("bad file")
+		}
+		return WriteFile(job.Output, func(w io.Writer) error {
+			_, err := io.WriteString(w, job.Input)
+			return err
+		})
+	}, Progress: func(r Result, finished, total int) {
+		processed = append(processed, r.Input)
+		if total != 3 || finished != len(processed) {
+			t.Errorf("progress %d of %d", finished, total)
+		}
+	}}
+	s := runner.Run(context.Background(), jobs)
+	if s.Total != 3 || s.Done != 1 || s.Skipped != 1 || len(s.Failed) != 1 || s.Failed[0].Input != "c" || s.Canceled != 0 {
+		t.Errorf("summary %+v", s)
+	}
+	if len(processed) != 3 {
+		t.Errorf("progress for %q", processed)
+	}
+	if data, _ := os.ReadFile(jobs[0].Output); string(data) != "a" {
+		t.Errorf("output %q", data)
+	}
+
+	// Running again resumes with what is missing, unless forced
+	runner.Progress = nil
+	s = runner.Run(context.Background(), jobs)
+	if s.Skipped != 2 || len(s.Failed) != 1 {
+		t.Errorf("second run %+v", s)
+	}
+	runner.Force = true
+	s = runner.Run(context.Background(), jobs)
+	if s.Done != 2 || s.Skipped != 0 {
+		t.Errorf("forced run %+v", s)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	dir := t.TempDir()
+	jobs := make([]Job, 20)
+	for i := range jobs {
+		jobs[i] = Job{Input: "in", Output: filepath.Join(dir, "out", string(rune('a'+i)))}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var started atomic.Int32
+	runner := &Runner{Workers: 2, Process: func(ctx context.Context, job Job) error {
+		// The third job cancels the run; the jobs already started finish
+		if started.Add(1) == 3 {
+			cancel()
+		}
+		return nil
+	}}
+	s := runner.Run(ctx, jobs)
+	if s.Done != int(started.Load()) || s.Done < 3 || s.Done > len(jobs)/2 {
+		t.Errorf("%d done after cancel, %d started", s.Done, started.Load())
+	}
+	if s.Canceled != len(jobs)-s.Done || len(s.Failed) != 0 {
+		t.Errorf("summary %+v", s)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "out.json")
+
+	// Nothing appears until the write is complete
+	err := WriteFile(path, func(w io.Writer) error {
+		if _, err := io.WriteString(w, "{"); err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("output visible while writing: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "{" {
+		t.Errorf("output %q", data)
+	}
+
+	// A failed write keeps the previous output and leaves no temporary file
+	failure := errors.New("encoder failed")
+	err = WriteFile(path, func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("error %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "{" {
+		t.Errorf("output after failure %q", data)
+	}
+	failed := filepath.Join(dir, "failed.json")
+	if err := WriteFile(failed, func(io.Writer) error { return failure }); !errors.Is(err, failure) {
+		t.Errorf("error %v", err)
+	}
+	for _, d := range []string{dir, filepath.Dir(path)} {
+		entries, _ := os.ReadDir(d)
+		for _, e := range entries {
+			if !e.IsDir() && e.Name() != "out.json" {
+				t.Errorf("left behind %s", e.Name())
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package batch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/iburimskiy/audio-visualization/internal/audio"
+	"github.com/iburimskiy/audio-visualization/internal/playlist"
+)
+
+// Plan lists the jobs for inputs, which may be audio files, folders or
+// playlists. Outputs are named after the input with ext appended, under
+// outDir; files found in a folder keep their path relative to that folder,
+// and other files keep theirs relative to the deepest folder they all share,
+// so equal names in different folders do not collide.
+func Plan(inputs []string, outDir, ext string) ([]Job, error) {
+	var jobs []Job
+	owner := map[string]string{}
+	add := func(input, rel string) error {
+		out := filepath.Join(outDir, rel+ext)
+		if prev, ok := owner[out]; ok {
+			if prev == input {
+				return nil
+			}
+			return fmt.Errorf("%s and %s would both write %s", prev, input, out)
+		}
+		owner[out] = input
+		jobs = append(jobs, Job{Input: input, Output: out})
+		return nil
+	}
+
+	// Folders are walked first so the files given on their own can be named
+	// once all of them are known
+	type entry struct {
+		path, rel string
+		loose     bool
+	}
+	var entries []entry
+	var loose []string
+	for _, in := range inputs {
+		info, err := os.Stat(in)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			tracks, err := playlist.Expand([]string{in})
+			if err != nil {
+				return nil, err
+			}
+			for _, t := range tracks {
+				if audio.IsURL(t) {
+					entries = append(entries, entry{path: t, rel: filepath.Base(t)})
+					continue
+				}
+				abs, err := filepath.Abs(t)
+				if err != nil {
+					return nil, err
+				}
+				entries = append(entries, entry{path: t, rel: abs, loose: true})
+				loose = append(loose, abs)
+			}
+			continue
+		}
+
+		var found []string
+		err = filepath.WalkDir(in, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && audio.IsSupported(p) {
+				found = append(found, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(found)
+		for _, p := range found {
+			rel, err := filepath.Rel(in, p)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry{path: p, rel: rel})
+		}
+	}
+
+	base := commonDir(loose)
+	for _, e := range entries {
+		rel := e.rel
+		if e.loose {
+			rel = relativeTo(base, e.rel)
+		}
+		if err := add(e.path, rel); err != nil {
+			return nil, err
+		}
+	}
+	return jobs, nil
+}
+
+// commonDir returns the deepest folder that contains all of the absolute
+// paths, or "" if they are on different volumes.
+func commonDir(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	dir := filepath.Dir(paths[0])
+	for _, p := range paths[1:] {
+		for !within(dir, p) {
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return ""
+			}
+			dir = parent
+		}
+	}
+	return dir
+}
+
+// within reports whether path is inside dir.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// relativeTo names the absolute path relative to dir, or without its volume
+// if there is no common folder.
+func relativeTo(dir, path string) string {
+	if dir != "" {
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			return rel
+		}
+	}
+	path = strings.TrimPrefix(path, filepath.VolumeName(path))
+	return strings.TrimLeft(path, `/\`)
+}
+
+// ReadList reads input paths from r, one per line. Blank lines and lines
+// starting with # are ignored.
+func ReadList(r io.Reader) ([]string, error) {
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	return paths, scanner.Err()
+}
+
+// WriteFile creates path and its parent folders and fills it using write.
+// The data goes to a temporary file that is renamed into place only when
+// write succeeds, so an interrupted run never leaves a partial output that
+// would be skipped on the next run.
+func WriteFile(path string, write func(w io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,183 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"io"
+	"math"
+)
+
+// aviMaxSize keeps files below the 4 GB limit of the 32-bit RIFF sizes. A
+// variable for tests.
+var aviMaxSize int64 = 4<<30 - 64<<20
+
+// errAVIFull is returned once a file would grow past aviMaxSize.
+var errAVIFull = errors.New("avi: file size limit reached")
+
+// aviWriter writes Motion JPEG video to an AVI file. The headers are written
+// with placeholder values first and completed by Close, so the writer needs
+// to seek.
+type aviWriter struct {
+	w             io.WriteSeeker
+	width, height int
+	quality       int
+	offset        int64 // bytes written after the headers
+	index         []aviIndexEntry
+	maxFrame      uint32
+	buf           bytes.Buffer
+}
+
+type aviIndexEntry struct {
+	offset uint32 // from the start of the movi list type
+	size   uint32
+}
+
+// The headers have a fixed layout, so the movi data always starts here
+const aviHeaderSize = 224
+
+func newAVIWriter(w io.WriteSeeker, width, height, quality int) (*aviWriter, error) {
+	a := &aviWriter{w: w, width: width, height: height, quality: quality}
+	if _, err := w.Write(a.headers(0, 0)); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// WriteFrame encodes img as one JPEG frame. Images of a different size are
+// scaled to the size of the first frame.
+func (a *aviWriter) WriteFrame(img image.Image) error {
+	if b := img.Bounds(); b.Dx() != a.width || b.Dy() != a.height {
+		img = resize(img, a.width, a.height)
+	}
+	a.buf.Reset()
+	// Chunk header is filled in once the size is known
+	a.buf.Write(make([]byte, 8))
+	if err := jpeg.Encode(&a.buf, img, &jpeg.Options{Quality: a.quality}); err != nil {
+		return err
+	}
+	size := a.buf.Len() - 8
+	if size%2 == 1 {
+		a.buf.WriteByte(0)
+	}
+	data := a.buf.Bytes()
+	copy(data[0:4], "00dc")
+	binary.LittleEndian.PutUint32(data[4:8], uint32(size))
+
+	if aviHeaderSize+a.offset+int64(len(data))+int64(16*(len(a.index)+1)) > aviMaxSize {
+		return errAVIFull
+	}
+	if _, err := a.w.Write(data); err != nil {
+		return err
+	}
+	// Offsets count from the "movi" list type, four bytes before the data
+	a.index = append(a.index, aviIndexEntry{offset: uint32(4 + a.offset), size: uint32(size)})
+	a.offset += int64(len(data))
+	a.maxFrame = max(a.maxFrame, uint32(size))
+	return nil
+}
+
+// Close writes the index and completes the headers. fps is the average frame
+// rate, used as the nominal rate of the stream.
+func (a *aviWriter) Close(fps float64) error {
+	var idx bytes.Buffer
+	idx.WriteString("idx1")
+	binary.Write(&idx, binary.LittleEndian, uint32(16*len(a.index)))
+	for _, e := range a.index {
+		idx.WriteString("00dc")
+		binary.Write(&idx, binary.LittleEndian, []uint32{0x10, e.offset, e.size}) // AVIIF_KEYFRAME
+	}
+	if _, err := a.w.Write(idx.Bytes()); err != nil {
+		return err
+	}
+	if _, err := a.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := a.w.Write(a.headers(fps, int64(idx.Len()))); err != nil {
+		return err
+	}
+	_, err := a.w.Seek(0, io.SeekEnd)
+	return err
+}
+
+// headers lays out the RIFF header, the hdrl list and the start of the movi
+// list for the frames written so far.
+func (a *aviWriter) headers(fps float64, indexSize int64) []byte {
+	if fps <= 0 {
+		fps = 60
+	}
+	// Express the rate as a fraction with a scale of 1000
+	scale := uint32(1000)
+	rate := uint32(math.Round(fps * float64(scale)))
+	frames := uint32(len(a.index))
+
+	var b bytes.Buffer
+	le := func(v ...any) {
+		for _, x := range v {
+			binary.Write(&b, binary.LittleEndian, x)
+		}
+	}
+	b.WriteString("RIFF")
+	le(uint32(aviHeaderSize - 8 + a.offset + indexSize))
+	b.WriteString("AVI ")
+
+	b.WriteString("LIST")
+	le(uint32(4 + 64 + 12 + 64 + 48))
+	b.WriteString("hdrl")
+
+	// MainAVIHeader
+	b.WriteString("avih")
+	le(uint32(56),
+		uint32(math.Round(1e6/fps)), // microseconds per frame
+		uint32(float64(a.maxFrame)*fps),
+		uint32(0),
+		uint32(0x10), // AVIF_HASINDEX
+		frames,
+		uint32(0),
+		uint32(1), // streams
+		a.maxFrame,
+		uint32(a.width), uint32(a.height),
+		[4]uint32{})
+
+	b.WriteString("LIST")
+	le(uint32(4 + 64 + 48))
+	b.WriteString("strl")
+
+	// AVIStreamHeader
+	b.WriteString("strh")
+	le(uint32(56))
+	b.WriteString("vidsMJPG")
+	le(uint32(0), uint16(0), uint16(0), uint32(0),
+		scale, rate,
+		uint32(0), frames, a.maxFrame,
+		int32(-1), // default quality
+		uint32(0),
+		[4]int16{0, 0, int16(a.width), int16(a.height)})
+
+	// BITMAPINFOHEADER
+	b.WriteString("strf")
+	le(uint32(40), uint32(40), int32(a.width), int32(a.height), uint16(1), uint16(24))
+	b.WriteString("MJPG")
+	le(uint32(a.width*a.height*3), int32(0), int32(0), uint32(0), uint32(0))
+
+	b.WriteString("LIST")
+	le(uint32(4 + a.offset))
+	b.WriteString("movi")
+	return b.Bytes()
+}
+
+// resize scales img to width×height with nearest-neighbour sampling.
+func resize(img image.Image, width, height int) *image.RGBA {
+	src := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy := src.Min.Y + y*src.Dy()/height
+		for x := 0; x < width; x++ {
+			sx := src.Min.X + x*src.Dx()/width
+			dst.Set(x, y, img.At(sx, sy))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,278 @@
+// Package capture writes frames of the live window to disk, as an MJPEG AVI
+// or a PNG sequence, along with a timestamp file for muxing them with the
+// audio later.
+package capture
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Options configure a capture session.
+type Options struct {
+	// Dir receives the capture; it is created if missing.
+	Dir string
+	// Format is "avi" for Motion JPEG video or "png" for one file per frame.
+	Format string
+	// Quality is the JPEG quality of AVI frames, 1 to 100.
+	Quality int
+}
+
+// Frame file names inside the capture folder. Video that outgrows one AVI
+// file continues in video-002.avi, video-003.avi and so on.
+const (
+	VideoFile      = "video.avi"
+	VideoPartFile  = "video-%03d.avi"
+	FramesDir      = "frames"
+	TimestampsFile = "timestamps.txt"
+)
+
+type frame struct {
+	img *image.RGBA
+	t   time.Duration
+}
+
+// Session encodes frames on its own goroutine. Frames that arrive while the
+// encoder is busy are dropped rather than slowing down the window; the
+// timestamp file records when every written frame was shown.
+type Session struct {
+	dir     string
+	frames  chan frame
+	pool    sync.Pool // of *image.RGBA the encoder is done with
+	done    chan struct{}
+	written atomic.Int64
+	dropped atomic.Int64
+	err     error
+}
+
+// Start creates the capture folder and starts the encoder.
+func Start(opts Options) (*Session, error) {
+	if opts.Format != "avi" && opts.Format != "png" {
+		return nil, fmt.Errorf("unsupported capture format %q", opts.Format)
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	ts, err := os.Create(filepath.Join(opts.Dir, TimestampsFile))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		dir:    opts.Dir,
+		frames: make(chan frame, 8),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		err := s.run(opts, ts)
+		if cerr := ts.Close(); err == nil {
+			err = cerr
+		}
+		s.err = err
+	}()
+	return s, nil
+}
+
+func (s *Session) run(opts Options, ts *os.File) error {
+	// Timecode format v2 lists the time of every frame in milliseconds
+	tw := bufio.NewWriter(ts)
+	fmt.Fprintln(tw, "# timecode format v2")
+
+	var video *aviParts
+	if opts.Format == "avi" {
+		video = &aviParts{dir: opts.Dir, quality: opts.Quality}
+		defer video.close()
+	} else if err := os.MkdirAll(filepath.Join(opts.Dir, FramesDir), 0o755); err != nil {
+		return err
+	}
+
+	for f := range s.frames {
+		n := s.written.Load() + 1
+		if video != nil {
+			if err := video.write(f.img, f.t); err != nil {
+				return err
+			}
+		} else {
+			path := filepath.Join(opts.Dir, FramesDir, fmt.Sprintf("%06d.png", n))
+			if err := SavePNG(path, f.img); err != nil {
+				return err
+			}
+		}
+		s.pool.Put(f.img)
+		fmt.Fprintf(tw, "%.3f\n", float64(f.t)/float64(time.Millisecond))
+		s.written.Store(n)
+	}
+
+	if video != nil {
+		if err := video.close(); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// aviParts writes the video to VideoFile, continuing in a new part whenever
+// a file reaches the AVI size limit. The first frame fixes the frame size
+// of every part.
+type aviParts struct {
+	dir           string
+	quality       int
+	width, height int
+	part          int
+	file          *os.File
+	avi           *aviWriter
+	frames        int           // in the current part
+	first, last   time.Duration // times of its first and last frame
+}
+
+func (p *aviParts) write(img *image.RGBA, t time.Duration) error {
+	if p.avi == nil {
+		b := img.Bounds()
+		p.width, p.height = b.Dx(), b.Dy()
+		if err := p.open(); err != nil {
+			return err
+		}
+	}
+	err := p.avi.WriteFrame(img)
+	if errors.Is(err, errAVIFull) {
+		if err := p.close(); err != nil {
+			return err
+		}
+		if err := p.open(); err != nil {
+			return err
+		}
+		err = p.avi.WriteFrame(img)
+	}
+	if err != nil {
+		return err
+	}
+	if p.frames == 0 {
+		p.first = t
+	}
+	p.frames++
+	p.last = t
+	return nil
+}
+
+func (p *aviParts) open() error {
+	p.part++
+	name := VideoFile
+	if p.part > 1 {
+		name = fmt.Sprintf(VideoPartFile, p.part)
+	}
+	f, err := os.Create(filepath.Join(p.dir, name))
+	if err != nil {
+		return err
+	}
+	avi, err := newAVIWriter(f, p.width, p.height, p.quality)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	p.file, p.avi, p.frames = f, avi, 0
+	return nil
+}
+
+// close completes the current part, if any.
+func (p *aviParts) close() error {
+	if p.avi == nil {
+		return nil
+	}
+	// Use the average rate as the nominal one; players that honour the
+	// timestamp file get the exact timing
+	fps := 0.0
+	if p.frames > 1 && p.last > p.first {
+		fps = float64(p.frames-1) / (p.last - p.first).Seconds()
+	}
+	err := p.avi.Close(fps)
+	if cerr := p.file.Close(); err == nil {
+		err = cerr
+	}
+	p.file, p.avi = nil, nil
+	return err
+}
+
+// Frame returns an image with bounds r to grab the next frame into, reusing
+// one the encoder is done with.
+func (s *Session) Frame(r image.Rectangle) *image.RGBA {
+	if img, ok := s.pool.Get().(*image.RGBA); ok && img.Rect == r {
+		return img
+	}
+	return image.NewRGBA(r)
+}
+
+// Add queues a frame shown at time t after the start of the capture. The
+// session takes ownership of img, and hands it out again from Frame once it
+// is written. It reports false if the frame was dropped.
+func (s *Session) Add(img *image.RGBA, t time.Duration) bool {
+	select {
+	case <-s.done:
+	default:
+		select {
+		case s.frames <- frame{img: img, t: t}:
+			return true
+		default:
+		}
+	}
+	s.dropped.Add(1)
+	s.pool.Put(img)
+	return false
+}
+
+// Written returns the number of frames written so far.
+func (s *Session) Written() int {
+	return int(s.written.Load())
+}
+
+// Dropped returns the number of frames skipped because the encoder was busy.
+func (s *Session) Dropped() int {
+	return int(s.dropped.Load())
+}
+
+// Dir returns the capture folder.
+func (s *Session) Dir() string {
+	return s.dir
+}
+
+// Failed reports whether the encoder stopped because of an error, which Stop
+// returns.
+func (s *Session) Failed() bool {
+	select {
+	case <-s.done:
+		return s.err != nil
+	default:
+		return false
+	}
+}
+
+// Stop writes the queued frames and completes the files.
+func (s *Session) Stop() error {
+	close(s.frames)
+	<-s.done
+	return s.err
+}
+
+// SavePNG writes img to path, creating missing folders.
+func SavePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,116 @@
+package capture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAVIParts(t *testing.T) {
+	defer func(size int64) { aviMaxSize = size }(aviMaxSize)
+	aviMaxSize = aviHeaderSize + 4096
+
+	dir := t.TempDir()
+	s, err := Start(Options{Dir: dir, Format: "avi", Quality: 85})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const frames = 20
+	r := image.Rect(0, 0, 32, 32)
+	for i := 0; i < frames; i++ {
+		img := s.Frame(r)
+		for y := 0; y < 32; y++ {
+			for x := 0; x < 32; x++ {
+				img.Set(x, y, color.RGBA{uint8(x * 8), uint8(y * 8), uint8(i * 12), 255})
+			}
+		}
+		// Wait for the encoder rather than dropping frames
+		for !s.Add(img, time.Duration(i)*time.Second/30) {
+			img = s.Frame(r)
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Written() != frames {
+		t.Fatalf("wrote %d frames", s.Written())
+	}
+
+	total := 0
+	for part := 1; ; part++ {
+		name := VideoFile
+		if part > 1 {
+			name = fmt.Sprintf(VideoPartFile, part)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			if part < 3 {
+				t.Fatalf("only %d parts", part-1)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(len(data)) > aviMaxSize {
+			t.Errorf("%s is %d bytes", name, len(data))
+		}
+		if string(data[:4]) != "RIFF" || int(binary.LittleEndian.Uint32(data[4:])) != len(data)-8 {
+			t.Errorf("%s: RIFF size %d for %d bytes", name, binary.LittleEndian.Uint32(data[4:]), len(data))
+		}
+		// The frame count of the main header matches the index
+		n := int(binary.LittleEndian.Uint32(data[48:]))
+		idx := len(data) - 16*n - 8
+		if n == 0 || string(data[idx:idx+4]) != "idx1" {
+			t.Errorf("%s: %d frames without a matching index", name, n)
+		}
+		if w, h := binary.LittleEndian.Uint32(data[64:]), binary.LittleEndian.Uint32(data[68:]); w != 32 || h != 32 {
+			t.Errorf("%s: size %dx%d", name, w, h)
+		}
+		total += n
+	}
+	if total != frames {
+		t.Errorf("%d frames in the parts, want %d", total, frames)
+	}
+
+	ts, err := os.ReadFile(filepath.Join(dir, TimestampsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(ts)), "\n"); len(lines) != frames+1 || lines[2] != "33.333" {
+		t.Errorf("timestamps %q", lines)
+	}
+}
+
+func TestFramePool(t *testing.T) {
+	s, err := Start(Options{Dir: t.TempDir(), Format: "png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := image.Rect(0, 0, 8, 8)
+	img := s.Frame(r)
+	if !s.Add(img, 0) {
+		t.Fatal("frame dropped")
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	// Frames come back at the size asked for, whatever is in the pool
+	if got := s.Frame(image.Rect(0, 0, 4, 4)); got.Rect != image.Rect(0, 0, 4, 4) {
+		t.Errorf("frame bounds %v", got.Rect)
+	}
+	if got := s.Frame(r); got.Rect != r {
+		t.Errorf("frame bounds %v", got.Rect)
+	}
+	// A stopped session drops frames and keeps them for reuse
+	if s.Add(s.Frame(r), time.Second) || s.Dropped() != 1 {
+		t.Errorf("added after stop, %d dropped", s.Dropped())
+	}
+}
@@ -0,0 +1,54 @@
+package clock
+
+import "time"
+
+// Clock measures the time that passes between two updates.
+type Clock interface {
+	// Tick advances the clock and returns the time elapsed since the previous tick.
+	Tick() time.Duration
+}
+
+// MaxDelta caps a single real tick so that a stall (window drag, breakpoint,
+// slow frame) does not make animations jump ahead.
+const MaxDelta = 100 * time.Millisecond
+
+// Real is a Clock backed by the wall clock.
+type Real struct {
+	now  func() time.Time
+	last time.Time
+}
+
+func NewReal() *Real {
+	return &Real{now: time.Now}
+}
+
+func (c *Real) Tick() time.Duration {
+	now := c.now()
+	if c.last.IsZero() {
+		c.last = now
+		return 0
+	}
+	dt := now.Sub(c.last)
+	c.last = now
+	if dt < 0 {
+		return 0
+	}
+	if dt > MaxDelta {
+		return MaxDelta
+	}
+	return dt
+}
+
+// Fixed is a deterministic Clock that advances by the same step on every tick,
+// for offline rendering and tests.
+type Fixed struct {
+	Step time.Duration
+}
+
+func NewFixed(step time.Duration) *Fixed {
+	return &Fixed{Step: step}
+}
+
+func (c *Fixed) Tick() time.Duration {
+	return c.Step
+}
@@ -0,0 +1,38 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReal(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	c := &Real{now: func() time.Time { return now }}
+
+	for _, tc := range []struct {
+		name    string
+		advance time.Duration
+		want    time.Duration
+	}{
+		{"first tick", 5 * time.Second, 0},
+		{"normal frame", 16 * time.Millisecond, 16 * time.Millisecond},
+		{"stall", 3 * time.Second, MaxDelta},
+		{"backwards", -time.Second, 0},
+		{"after going back", 20 * time.Millisecond, 20 * time.Millisecond},
+	} {
+		now = now.Add(tc.advance)
+		if got := c.Tick(); got != tc.want {
+			t.Errorf("%s: %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFixed(t *testing.T) {
+	c := NewFixed(time.Second / 60)
+	for range 3 {
+		if got := c.Tick(); got != time.Second/60 {
+			t.Errorf("tick %v", got)
+		}
+	}
+}
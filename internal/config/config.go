@@ -1,23 +1,258 @@
 package config
 
-const (
-	WindowWidth  = 1024
-	WindowHeight = 512
-
-	VisualRingSize  = 8192
-	SmoothingFactor = 0.6
-
-	// Button dimensions
-	ButtonWidth  = 120
-	ButtonHeight = 40
-	ButtonX      = 20
-	ButtonY      = 50
-
-	// Visualization parameters
-	CircleCount     = 8
-	WaveCount       = 12
-	ParticleCount   = 50
-	MaxRadius       = 200
-	RotationSpeed   = 0.02
-	ColorShiftSpeed = 0.01
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
 )
+
+// Config holds every user-tunable setting. It is read from a JSON file; keys
+// that are missing keep their default value.
+type Config struct {
+	Window  Window  `json:"window"`
+	Audio   Audio   `json:"audio"`
+	Visual  Visual  `json:"visual"`
+	Scenes  Scenes  `json:"scenes"`
+	Record  Record  `json:"record"`
+	Capture Capture `json:"capture"`
+	Input   Input   `json:"input"`
+	Remote  Remote  `json:"remote"`
+	OSC     OSC     `json:"osc"`
+	DMX     DMX     `json:"dmx"`
+	MPRIS   MPRIS   `json:"mpris"`
+	// Keys rebinds actions by name; an action listed here loses its
+	// default bindings, and an empty list unbinds it.
+	Keys map[string][]string `json:"keys"`
+}
+
+type Window struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type Audio struct {
+	VisualRingSize        int     `json:"visual_ring_size"`        // samples kept for the visualizers
+	SmoothingTime         float64 `json:"smoothing_time"`          // seconds, time constant of the band level smoothing
+	CorrelationSmoothTime float64 `json:"correlation_smooth_time"` // seconds
+}
+
+type Visual struct {
+	CircleCount     int     `json:"circle_count"`
+	WaveCount       int     `json:"wave_count"`
+	ParticleCount   int     `json:"particle_count"`
+	RotationSpeed   float64 `json:"rotation_speed"`    // radians per second
+	ColorShiftSpeed float64 `json:"color_shift_speed"` // hue turns per second
+	PhosphorDecay   float64 `json:"phosphor_decay"`    // brightness removed from the vectorscope trail per second
+
+	// Colors
+	HueOffset  float64 `json:"hue_offset"` // added to every hue (1.0 = full turn)
+	Saturation float64 `json:"saturation"` // multiplies every saturation
+	Brightness float64 `json:"brightness"` // multiplies every brightness
+}
+
+type Scenes struct {
+	Default            []string `json:"default"`             // layers shown on start, bottom first
+	Playlist           []string `json:"playlist"`            // entries are scene names joined with "+"
+	Transition         string   `json:"transition"`          // crossfade, wipe, zoom or cut
+	TransitionDuration float64  `json:"transition_duration"` // seconds
+	PlaylistBars       int      `json:"playlist_bars"`       // bars each playlist entry stays on screen
+	BeatsPerBar        int      `json:"beats_per_bar"`
+	AutoPlay           bool     `json:"autoplay"`
+}
+
+type Record struct {
+	// Template names recordings. {date}, {time} and {track} are replaced with
+	// the start date, the start time and the playing track's name; the
+	// extension (.wav or .flac) picks the format.
+	Template string `json:"template"`
+}
+
+type Capture struct {
+	// Template names the folder a capture is written to, with the same
+	// placeholders as Record.Template.
+	Template    string `json:"template"`
+	Format      string `json:"format"`       // avi (Motion JPEG) or png (one file per frame)
+	JPEGQuality int    `json:"jpeg_quality"` // 1 to 100
+	// Screenshot names single frames saved as PNG.
+	Screenshot string `json:"screenshot"`
+}
+
+// Input describes the raw PCM read by -input.
+type Input struct {
+	Encoding   string `json:"encoding"` // s16le, s24le, s32le or f32le
+	SampleRate int    `json:"sample_rate"`
+	Channels   int    `json:"channels"`
+	// Monitor plays the input through the speakers; otherwise it is only
+	// visualized, which avoids feedback when listening to a microphone.
+	Monitor bool `json:"monitor"`
+}
+
+// Remote configures the HTTP remote control. It is read at startup only.
+type Remote struct {
+	Listen string `json:"listen"` // host:port to serve on; empty disables it
+	// Token, if set, must be sent as "Authorization: Bearer <token>".
+	Token string `json:"token"`
+}
+
+// OSC configures Open Sound Control input and output. It is read at startup
+// only.
+type OSC struct {
+	Listen string   `json:"listen"` // host:port to receive commands on; empty disables it
+	Send   []string `json:"send"`   // host:port destinations for the analysis
+	Prefix string   `json:"prefix"` // address prefix of sent messages
+}
+
+// DMX configures lighting output. It is read at startup only.
+type DMX struct {
+	Mapping string `json:"mapping"` // fixture mapping file; empty disables it
+}
+
+// MPRIS configures the desktop media player interface on Linux. It is read
+// at startup only.
+type MPRIS struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Default returns the built-in configuration.
+func Default() *Config {
+	return &Config{
+		Window: Window{
+			Width:  1024,
+			Height: 512,
+		},
+		Audio: Audio{
+			VisualRingSize:        8192,
+			SmoothingTime:         0.033,
+			CorrelationSmoothTime: 0.075,
+		},
+		Visual: Visual{
+			CircleCount:     8,
+			WaveCount:       12,
+			ParticleCount:   50,
+			RotationSpeed:   1.2,
+			ColorShiftSpeed: 0.6,
+			PhosphorDecay:   3.6,
+			HueOffset:       0,
+			Saturation:      1,
+			Brightness:      1,
+		},
+		Scenes: Scenes{
+			Default: []string{"circles", "waves", "particles", "rings"},
+			Playlist: []string{
+				"circles+waves+particles+rings",
+				"oscilloscope",
+				"rings+particles",
+				"goniometer",
+				"waves+circles",
+				"vectorscope+rings",
+			},
+			Transition:         "crossfade",
+			TransitionDuration: 1.0,
+			PlaylistBars:       8,
+			BeatsPerBar:        4,
+		},
+		Record: Record{
+			Template: "recording-{date}-{time}.wav",
+		},
+		Capture: Capture{
+			Template:    "capture-{date}-{time}",
+			Format:      "avi",
+			JPEGQuality: 85,
+			Screenshot:  "screenshot-{date}-{time}.png",
+		},
+		Input: Input{
+			Encoding:   "s16le",
+			SampleRate: 44100,
+			Channels:   2,
+		},
+		OSC: OSC{
+			Prefix: "/audio",
+		},
+		MPRIS: MPRIS{
+			Enabled: true,
+		},
+	}
+}
+
+// DefaultPath returns the location of the config file:
+// $XDG_CONFIG_HOME/audio-visualization/config.json on Linux and the
+// platform's user config directory elsewhere.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "audio-visualization", "config.json"), nil
+}
+
+// Load reads the config file at path on top of the defaults. A missing file
+// is not an error when optional is set, so that running without a config
+// file works out of the box.
+func Load(path string, optional bool) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if optional && errors.Is(err, fs.ErrNotExist) {
+			return Default(), nil
+		}
+		return nil, err
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse decodes JSON config data on top of the defaults and validates the
+// result. Unknown keys and out-of-range values are reported together.
+func Parse(data []byte) (*Config, error) {
+	cfg := Default()
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, describeJSONError(data, err)
+	}
+
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, describeJSONError(data, err)
+	}
+	var problems []error
+	for _, key := range unknownKeys(raw, cfg) {
+		problems = append(problems, fmt.Errorf("unknown key %q", key))
+	}
+	if err := cfg.Validate(); err != nil {
+		problems = append(problems, err)
+	}
+	if len(problems) > 0 {
+		return nil, errors.Join(problems...)
+	}
+	return cfg, nil
+}
+
+// describeJSONError adds the line and column to syntax and type errors.
+func describeJSONError(data []byte, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+		if typeErr.Field != "" {
+			err = fmt.Errorf("%s: cannot use JSON %s as %s", typeErr.Field, typeErr.Value, typeErr.Type)
+		}
+	default:
+		return err
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Errorf("line %d, column %d: %w", line, column, err)
+}
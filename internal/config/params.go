@@ -0,0 +1,37 @@
+package config
+
+import "fmt"
+
+// VisualParams lists the visual parameters that can be changed while
+// playing, by their JSON key.
+var VisualParams = []string{
+	"rotation_speed", "color_shift_speed", "phosphor_decay",
+	"hue_offset", "saturation", "brightness",
+}
+
+// WithVisual returns a copy of c with the visual parameter name set to
+// value. The result is validated like a loaded config.
+func (c *Config) WithVisual(name string, value float64) (*Config, error) {
+	next := *c
+	v := &next.Visual
+	switch name {
+	case "rotation_speed":
+		v.RotationSpeed = value
+	case "color_shift_speed":
+		v.ColorShiftSpeed = value
+	case "phosphor_decay":
+		v.PhosphorDecay = value
+	case "hue_offset":
+		v.HueOffset = value
+	case "saturation":
+		v.Saturation = value
+	case "brightness":
+		v.Brightness = value
+	default:
+		return nil, fmt.Errorf("unknown parameter %q", name)
+	}
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
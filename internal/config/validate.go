@@ -0,0 +1,166 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Validate checks that every value is within its supported range and returns
+// all problems at once.
+func (c *Config) Validate() error {
+	var v validator
+
+	v.intRange("window.width", c.Window.Width, 320, 16384)
+	v.intRange("window.height", c.Window.Height, 200, 16384)
+
+	v.intRange("audio.visual_ring_size", c.Audio.VisualRingSize, 2048, 1<<20)
+	v.floatRange("audio.smoothing_time", c.Audio.SmoothingTime, 0, 10)
+	v.floatRange("audio.correlation_smooth_time", c.Audio.CorrelationSmoothTime, 0, 10)
+
+	v.intRange("visual.circle_count", c.Visual.CircleCount, 0, 256)
+	v.intRange("visual.wave_count", c.Visual.WaveCount, 0, 256)
+	v.intRange("visual.particle_count", c.Visual.ParticleCount, 0, 4096)
+	v.floatRange("visual.rotation_speed", c.Visual.RotationSpeed, -100, 100)
+	v.floatRange("visual.color_shift_speed", c.Visual.ColorShiftSpeed, -100, 100)
+	v.floatRange("visual.phosphor_decay", c.Visual.PhosphorDecay, 0, 1000)
+	v.floatRange("visual.hue_offset", c.Visual.HueOffset, 0, 1)
+	v.floatRange("visual.saturation", c.Visual.Saturation, 0, 2)
+	v.floatRange("visual.brightness", c.Visual.Brightness, 0, 2)
+
+	if len(c.Scenes.Default) == 0 {
+		v.add("scenes.default: at least one scene is required")
+	}
+	for i, entry := range c.Scenes.Playlist {
+		if strings.TrimSpace(entry) == "" {
+			v.add("scenes.playlist[%d]: entry is empty", i)
+		}
+	}
+	switch c.Scenes.Transition {
+	case "crossfade", "wipe", "zoom", "cut":
+	default:
+		v.add("scenes.transition: %q is not one of crossfade, wipe, zoom, cut", c.Scenes.Transition)
+	}
+	v.floatRange("scenes.transition_duration", c.Scenes.TransitionDuration, 0, 60)
+	v.intRange("scenes.playlist_bars", c.Scenes.PlaylistBars, 1, 1024)
+	v.intRange("scenes.beats_per_bar", c.Scenes.BeatsPerBar, 1, 16)
+
+	switch strings.ToLower(filepath.Ext(c.Record.Template)) {
+	case ".wav", ".flac":
+	default:
+		v.add("record.template: %q must end in .wav or .flac", c.Record.Template)
+	}
+	if strings.TrimSpace(c.Capture.Template) == "" {
+		v.add("capture.template: must not be empty")
+	}
+	switch c.Capture.Format {
+	case "avi", "png":
+	default:
+		v.add("capture.format: %q is not one of avi, png", c.Capture.Format)
+	}
+	v.intRange("capture.jpeg_quality", c.Capture.JPEGQuality, 1, 100)
+	if !strings.EqualFold(filepath.Ext(c.Capture.Screenshot), ".png") {
+		v.add("capture.screenshot: %q must end in .png", c.Capture.Screenshot)
+	}
+
+	switch c.Input.Encoding {
+	case "s16le", "s24le", "s32le", "f32le":
+	default:
+		v.add("input.encoding: %q is not one of s16le, s24le, s32le, f32le", c.Input.Encoding)
+	}
+	v.intRange("input.sample_rate", c.Input.SampleRate, 8000, 384000)
+	v.intRange("input.channels", c.Input.Channels, 1, 2)
+
+	if c.Remote.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Remote.Listen); err != nil {
+			v.add("remote.listen: %v", err)
+		}
+	}
+
+	if c.OSC.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.OSC.Listen); err != nil {
+			v.add("osc.listen: %v", err)
+		}
+	}
+	for i, dest := range c.OSC.Send {
+		if _, _, err := net.SplitHostPort(dest); err != nil {
+			v.add("osc.send[%d]: %v", i, err)
+		}
+	}
+	if !strings.HasPrefix(c.OSC.Prefix, "/") || strings.HasSuffix(c.OSC.Prefix, "/") {
+		v.add("osc.prefix: %q must start and must not end with /", c.OSC.Prefix)
+	}
+
+	return v.err()
+}
+
+type validator struct {
+	problems []error
+}
+
+func (v *validator) add(format string, args ...any) {
+	v.problems = append(v.problems, fmt.Errorf(format, args...))
+}
+
+func (v *validator) intRange(name string, value, min, max int) {
+	if value < min || value > max {
+		v.add("%s: %d is out of range (%d to %d)", name, value, min, max)
+	}
+}
+
+func (v *validator) floatRange(name string, value, min, max float64) {
+	if value < min || value > max {
+		v.add("%s: %g is out of range (%g to %g)", name, value, min, max)
+	}
+}
+
+func (v *validator) err() error {
+	return errors.Join(v.problems...)
+}
+
+// unknownKeys walks decoded JSON alongside the struct it was decoded into and
+// returns the dotted paths of keys that have no matching field.
+func unknownKeys(raw any, target any) []string {
+	var keys []string
+	walkKeys(raw, reflect.TypeOf(target), "", &keys)
+	sort.Strings(keys)
+	return keys
+}
+
+func walkKeys(raw any, t reflect.Type, prefix string, keys *[]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	obj, ok := raw.(map[string]any)
+	if !ok || t.Kind() != reflect.Struct {
+		return
+	}
+
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = f.Name
+		}
+		// encoding/json matches keys case-insensitively
+		fields[strings.ToLower(name)] = f.Type
+	}
+
+	for key, value := range obj {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		ft, ok := fields[strings.ToLower(key)]
+		if !ok {
+			*keys = append(*keys, path)
+			continue
+		}
+		walkKeys(value, ft, path, keys)
+	}
+}
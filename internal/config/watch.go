@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Update is the result of reloading a watched config file. Exactly one of
+// Config and Err is set.
+type Update struct {
+	Config *Config
+	Err    error
+}
+
+// Watcher polls a config file and reloads it whenever its modification time
+// or size changes. Polling works on every platform and file system, including
+// network mounts and editors that replace the file on save.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	updates  chan Update
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// Watch starts polling path every interval. The current state of the file is
+// taken as the baseline, so the first update is sent on the first change.
+func Watch(path string, interval time.Duration) *Watcher {
+	w := &Watcher{
+		path:     path,
+		interval: interval,
+		updates:  make(chan Update, 1),
+		stop:     make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Updates delivers reload results. Only the latest result is kept if the
+// receiver falls behind.
+func (w *Watcher) Updates() <-chan Update {
+	return w.updates
+}
+
+// Close stops polling.
+func (w *Watcher) Close() {
+	w.once.Do(func() { close(w.stop) })
+}
+
+func (w *Watcher) run() {
+	modTime, size, _ := w.stat()
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+
+		mt, sz, ok := w.stat()
+		if !ok || (mt.Equal(modTime) && sz == size) {
+			// Keep the current settings while the file is missing
+			continue
+		}
+		modTime, size = mt, sz
+
+		cfg, err := Load(w.path, false)
+		w.send(Update{Config: cfg, Err: err})
+	}
+}
+
+func (w *Watcher) stat() (time.Time, int64, bool) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return info.ModTime(), info.Size(), true
+}
+
+func (w *Watcher) send(u Update) {
+	// Replace a pending update that has not been picked up yet
+	select {
+	case <-w.updates:
+	default:
+	}
+	select {
+	case w.updates <- u:
+	default:
+	}
+}
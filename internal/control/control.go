@@ -0,0 +1,149 @@
+// Package control lets other goroutines, such as the remote control server,
+// drive the player. Commands are queued and carried out by the game in its
+// Update, so nothing outside the game loop touches its state; the game
+// publishes a status snapshot every frame in return.
+package control
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Kind selects what a command does.
+type Kind int
+
+const (
+	Play     Kind = iota
+	Pause         // pause; Play resumes
+	Toggle        // play/pause
+	Seek          // jump to Value seconds
+	Next          // skip to the next queued track
+	Previous      // go back to the previous track
+	Volume        // set the volume to Value percent
+	Scene         // show the scenes in Text, joined with "+"
+	Enqueue       // queue the file, folder or playlist in Text; Value 1 plays it right away
+	Param         // set the visual parameter named Text to Value
+)
+
+// Command is one request for the game.
+type Command struct {
+	Kind  Kind
+	Value float64
+	Text  string
+
+	done     chan error
+	canceled bool // guarded by the hub's mu
+}
+
+// Status is a snapshot of the player.
+type Status struct {
+	State    string   `json:"state"` // playing, paused or stopped
+	Track    string   `json:"track,omitempty"`
+	Title    string   `json:"title,omitempty"` // from stream metadata
+	Position float64  `json:"position"`        // seconds
+	Duration float64  `json:"duration"`        // seconds; 0 for live input and streams
+	Live     bool     `json:"live"`
+	Volume   float64  `json:"volume"` // percent
+	Scenes   string   `json:"scenes"` // names joined with "+"
+	BPM      float64  `json:"bpm"`
+	Queue    []string `json:"queue"`
+	Index    int      `json:"index"` // of the current track in Queue
+}
+
+// ErrNotRunning is returned when the game does not pick up a command, for
+// example because it is shutting down.
+var ErrNotRunning = errors.New("player is not responding")
+
+// Hub connects command sources to the game.
+type Hub struct {
+	commands chan *Command
+
+	mu     sync.Mutex // guards status and the canceled flag of commands
+	status Status
+}
+
+func NewHub() *Hub {
+	return &Hub{commands: make(chan *Command, 16)}
+}
+
+// Do queues cmd and waits until the game has carried it out. It fails with
+// ErrNotRunning if the queue is full and with ctx's error if the game takes
+// too long, in which case the command is dropped unless the game has already
+// taken it.
+func (h *Hub) Do(ctx context.Context, cmd Command) error {
+	cmd.done = make(chan error, 1)
+	select {
+	case h.commands <- &cmd:
+	default:
+		return ErrNotRunning
+	}
+	select {
+	case err := <-cmd.done:
+		return err
+	case <-ctx.Done():
+		h.mu.Lock()
+		cmd.canceled = true
+		h.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Post queues cmd without waiting for it, for sources that send a stream of
+// messages and get no answer, like OSC controllers. The game shows failures
+// of posted commands in its status line. It fails with ErrNotRunning if the
+// queue is full.
+func (h *Hub) Post(cmd Command) error {
+	select {
+	case h.commands <- &cmd:
+		return nil
+	default:
+		return ErrNotRunning
+	}
+}
+
+// Pending returns the next queued command, or nil, skipping commands whose
+// sender gave up waiting. The game must call Reply on every command it takes.
+func (h *Hub) Pending() *Command {
+	for {
+		select {
+		case cmd := <-h.commands:
+			h.mu.Lock()
+			canceled := cmd.canceled
+			h.mu.Unlock()
+			if !canceled {
+				return cmd
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// Reply reports the outcome of cmd to its sender. It does nothing for posted
+// commands.
+func (cmd *Command) Reply(err error) {
+	if cmd.done != nil {
+		cmd.done <- err
+	}
+}
+
+// Posted reports whether cmd was queued by Post, so nobody learns its outcome
+// from Reply.
+func (cmd *Command) Posted() bool {
+	return cmd.done == nil
+}
+
+// Publish replaces the status snapshot.
+func (h *Hub) Publish(s Status) {
+	h.mu.Lock()
+	h.status = s
+	h.mu.Unlock()
+}
+
+// Status returns the last published snapshot.
+func (h *Hub) Status() Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status
+}
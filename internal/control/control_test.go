@@ -0,0 +1,64 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	h := NewHub()
+	go func() {
+		for {
+			if cmd := h.Pending(); cmd != nil {
+				h.Publish(Status{Volume: cmd.Value})
+				cmd.Reply(nil)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	if err := h.Do(context.Background(), Command{Kind: Volume, Value: 80}); err != nil {
+		t.Fatal(err)
+	}
+	if s := h.Status(); s.Volume != 80 {
+		t.Errorf("status %+v", s)
+	}
+}
+
+func TestDoTimeout(t *testing.T) {
+	h := NewHub()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := h.Do(ctx, Command{Kind: Next}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("do: %v", err)
+	}
+	// The game comes back after the sender gave up
+	if err := h.Post(Command{Kind: Volume, Value: 50}); err != nil {
+		t.Fatal(err)
+	}
+	cmd := h.Pending()
+	if cmd == nil || cmd.Kind != Volume || !cmd.Posted() {
+		t.Fatalf("pending %+v", cmd)
+	}
+	cmd.Reply(errors.New("nobody listens"))
+	if cmd := h.Pending(); cmd != nil {
+		t.Errorf("pending %+v", cmd)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	h := NewHub()
+	for range cap(h.commands) {
+		if err := h.Post(Command{Kind: Toggle}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.Post(Command{Kind: Toggle}); err != ErrNotRunning {
+		t.Errorf("post: %v", err)
+	}
+	if err := h.Do(context.Background(), Command{Kind: Toggle}); err != ErrNotRunning {
+		t.Errorf("do: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package control
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// commandTimeout bounds how long a request waits for the game loop. A
+// variable for tests.
+var commandTimeout = 2 * time.Second
+
+// request is the JSON body of a command. Each endpoint reads its own field.
+type request struct {
+	Position *float64 `json:"position"` // seconds
+	Volume   *float64 `json:"volume"`   // percent
+	Scenes   string   `json:"scenes"`
+	Path     string   `json:"path"`
+	Play     bool     `json:"play"`
+}
+
+// NewHandler serves the remote control API for h:
+//
+//	GET  /status                           current status
+//	POST /play, /pause, /toggle            playback
+//	POST /next, /previous                  queue navigation
+//	POST /seek     {"position": 90}        seconds
+//	POST /volume   {"volume": 80}          percent, 0 to 200
+//	POST /scene    {"scenes": "rings+particles"}
+//	POST /enqueue  {"path": "...", "play": true}
+//
+// Commands answer with the status after they were carried out.
+func NewHandler(h *Hub) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, h.Status())
+	})
+
+	command := func(path string, build func(req *request) (Command, error)) {
+		mux.HandleFunc("POST "+path, func(w http.ResponseWriter, r *http.Request) {
+			var req request
+			if r.ContentLength != 0 {
+				dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+				dec.DisallowUnknownFields()
+				if err := dec.Decode(&req); err != nil {
+					writeError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+					return
+				}
+			}
+			cmd, err := build(&req)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), commandTimeout)
+			defer cancel()
+			switch err := h.Do(ctx, cmd); {
+			case errors.Is(err, ErrNotRunning), errors.Is(err, context.DeadlineExceeded):
+				writeError(w, http.StatusServiceUnavailable, ErrNotRunning)
+			case err != nil:
+				writeError(w, http.StatusConflict, err)
+			default:
+				writeJSON(w, http.StatusOK, h.Status())
+			}
+		})
+	}
+	simple := func(kind Kind) func(*request) (Command, error) {
+		return func(*request) (Command, error) { return Command{Kind: kind}, nil }
+	}
+	command("/play", simple(Play))
+	command("/pause", simple(Pause))
+	command("/toggle", simple(Toggle))
+	command("/next", simple(Next))
+	command("/previous", simple(Previous))
+	command("/seek", func(req *request) (Command, error) {
+		if req.Position == nil || *req.Position < 0 {
+			return Command{}, errors.New(`"position" must be a number of seconds >= 0`)
+		}
+		return Command{Kind: Seek, Value: *req.Position}, nil
+	})
+	command("/volume", func(req *request) (Command, error) {
+		if req.Volume == nil || *req.Volume < 0 || *req.Volume > 200 {
+			return Command{}, errors.New(`"volume" must be a percentage from 0 to 200`)
+		}
+		return Command{Kind: Volume, Value: *req.Volume}, nil
+	})
+	command("/scene", func(req *request) (Command, error) {
+		if strings.TrimSpace(req.Scenes) == "" {
+			return Command{}, errors.New(`"scenes" is required`)
+		}
+		return Command{Kind: Scene, Text: req.Scenes}, nil
+	})
+	command("/enqueue", func(req *request) (Command, error) {
+		if strings.TrimSpace(req.Path) == "" {
+			return Command{}, errors.New(`"path" is required`)
+		}
+		cmd := Command{Kind: Enqueue, Text: req.Path}
+		if req.Play {
+			cmd.Value = 1
+		}
+		return cmd, nil
+	})
+
+	return mux
+}
+
+// RequireToken lets through requests that send token as
+// "Authorization: Bearer <token>" or, for browsers opening a WebSocket, as
+// the token query parameter. An empty token allows everything.
+func RequireToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			got = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("missing or wrong token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
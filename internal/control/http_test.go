@@ -0,0 +1,184 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve carries out the hub's commands like the game until the test ends,
+// failing scene changes to unknown scenes.
+func serve(t *testing.T, h *Hub) {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+	})
+	go func() {
+		defer close(done)
+		status := Status{State: "playing", Volume: 100}
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			for cmd := h.Pending(); cmd != nil; cmd = h.Pending() {
+				var err error
+				switch cmd.Kind {
+				case Pause:
+					status.State = "paused"
+				case Volume:
+					status.Volume = cmd.Value
+				case Seek:
+					status.Position = cmd.Value
+				case Scene:
+					if cmd.Text == "missing" {
+						err = errors.New(`unknown scene "missing"`)
+					} else {
+						status.Scenes = cmd.Text
+					}
+				}
+				h.Publish(status)
+				cmd.Reply(err)
+			}
+		}
+	}()
+}
+
+func post(t *testing.T, handler http.Handler, path, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if body == "" {
+		req.ContentLength = 0
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var got map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("%s: %v in %q", path, err, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("%s: content type %q", path, ct)
+	}
+	return rec.Code, got
+}
+
+func TestHandler(t *testing.T) {
+	h := NewHub()
+	serve(t, h)
+	handler := NewHandler(h)
+
+	for _, tc := range []struct {
+		path, body string
+		code       int
+		field      string // of the answer, or "error"
+		want       any
+	}{
+		{"/pause", "", http.StatusOK, "state", "paused"},
+		{"/volume", `{"volume": 80}`, http.StatusOK, "volume", 80.0},
+		{"/seek", `{"position": 12.5}`, http.StatusOK, "position", 12.5},
+		{"/scene", `{"scenes": "rings+particles"}`, http.StatusOK, "scenes", "rings+particles"},
+
+		{"/volume", `{"volume": 80`, http.StatusBadRequest, "error", nil},
+		{"/volume", `{"volume": "loud"}`, http.StatusBadRequest, "error", nil},
+		{"/volume", `{"loudness": 80}`, http.StatusBadRequest, "error", nil},
+		{"/volume", `{"volume": 201}`, http.StatusBadRequest, "error", nil},
+		{"/volume", `{"volume": -1}`, http.StatusBadRequest, "error", nil},
+		{"/volume", "", http.StatusBadRequest, "error", nil},
+		{"/seek", `{"position": -3}`, http.StatusBadRequest, "error", nil},
+		{"/scene", `{"scenes": "  "}`, http.StatusBadRequest, "error", nil},
+		{"/enqueue", `{}`, http.StatusBadRequest, "error", nil},
+
+		// The game refusing a command is a conflict
+		{"/scene", `{"scenes": "missing"}`, http.StatusConflict, "error", `unknown scene "missing"`},
+	} {
+		code, got := post(t, handler, tc.path, tc.body)
+		if code != tc.code {
+			t.Errorf("%s %s: status %d, want %d (%v)", tc.path, tc.body, code, tc.code, got)
+			continue
+		}
+		if v, ok := got[tc.field]; !ok || tc.want != nil && v != tc.want {
+			t.Errorf("%s %s: %s is %v, want %v", tc.path, tc.body, tc.field, v, tc.want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var s Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("status: %d %v", rec.Code, err)
+	}
+	if s.State != "paused" || s.Volume != 80 || s.Scenes != "rings+particles" {
+		t.Errorf("status %+v", s)
+	}
+}
+
+func TestHandlerNotRunning(t *testing.T) {
+	defer func(d time.Duration) { commandTimeout = d }(commandTimeout)
+	commandTimeout = 20 * time.Millisecond
+
+	// Nobody takes the commands, so they time out until the queue is full
+	h := NewHub()
+	handler := NewHandler(h)
+	for i := 0; i < cap(h.commands); i++ {
+		if code, got := post(t, handler, "/toggle", ""); code != http.StatusServiceUnavailable || got["error"] != ErrNotRunning.Error() {
+			t.Fatalf("timeout: %d %v", code, got)
+		}
+	}
+	start := time.Now()
+	if code, got := post(t, handler, "/toggle", ""); code != http.StatusServiceUnavailable || got["error"] != ErrNotRunning.Error() {
+		t.Errorf("queue full: %d %v", code, got)
+	}
+	if d := time.Since(start); d >= commandTimeout {
+		t.Errorf("waited %v with the queue full", d)
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	for _, tc := range []struct {
+		name, header, query string
+		code                int
+	}{
+		{"no token", "", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer nope", "", http.StatusUnauthorized},
+		{"not bearer", "Basic c2VjcmV0", "", http.StatusUnauthorized},
+		{"prefix of the token", "Bearer sec", "", http.StatusUnauthorized},
+		{"wrong query token", "", "nope", http.StatusUnauthorized},
+		{"bearer token", "Bearer secret", "", http.StatusNoContent},
+		{"query token", "", "secret", http.StatusNoContent},
+	} {
+		target := "/status"
+		if tc.query != "" {
+			target += "?token=" + tc.query
+		}
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		rec := httptest.NewRecorder()
+		RequireToken("secret", ok).ServeHTTP(rec, req)
+		if rec.Code != tc.code {
+			t.Errorf("%s: status %d, want %d", tc.name, rec.Code, tc.code)
+		}
+		if tc.code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("%s: no challenge", tc.name)
+		}
+	}
+
+	// Without a token everything passes
+	rec := httptest.NewRecorder()
+	RequireToken("", ok).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("no token required: status %d", rec.Code)
+	}
+}
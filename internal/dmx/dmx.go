@@ -0,0 +1,114 @@
+// Package dmx drives stage lights from the audio analysis by sending DMX
+// over Art-Net or E1.31 (sACN).
+package dmx
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Output sends the mapped channels at the mapping's rate on its own
+// goroutine. The game hands it the analysis with Update.
+type Output struct {
+	m    *Mapping
+	conn net.Conn
+	cid  [16]byte // identifies this sACN source
+	seq  byte
+
+	mu       sync.Mutex
+	state    State
+	lastBeat time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Start connects to the mapping's target and starts sending.
+func Start(m *Mapping) (*Output, error) {
+	conn, err := net.Dial("udp", m.address())
+	if err != nil {
+		return nil, err
+	}
+	o := &Output{m: m, conn: conn, stop: make(chan struct{}), done: make(chan struct{})}
+	_, _ = rand.Read(o.cid[:])
+	go o.run()
+	return o, nil
+}
+
+// Update replaces the analysis the next packets are computed from. beat
+// marks a detected beat. The bands are copied.
+func (o *Output) Update(s State, beat bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	bands := append(o.state.Bands[:0], s.Bands...)
+	o.state = s
+	o.state.Bands = bands
+	if beat {
+		o.lastBeat = time.Now()
+	}
+}
+
+func (o *Output) run() {
+	defer close(o.done)
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / o.m.Rate))
+	defer ticker.Stop()
+
+	var (
+		u      Universe
+		failed bool
+	)
+	for {
+		select {
+		case <-o.stop:
+			// Leave the lights dark rather than frozen on the last frame
+			u = Universe{}
+			_ = o.send(&u)
+			return
+		case now := <-ticker.C:
+			o.mu.Lock()
+			s := o.state
+			s.SinceBeat = -1
+			if !o.lastBeat.IsZero() {
+				s.SinceBeat = now.Sub(o.lastBeat).Seconds()
+			}
+			o.m.render(&u, &s)
+			o.mu.Unlock()
+
+			// Report a failing network once instead of on every packet
+			err := o.send(&u)
+			if err != nil && !failed {
+				fmt.Printf("DMX: %v\n", err)
+			}
+			failed = err != nil
+		}
+	}
+}
+
+func (o *Output) send(u *Universe) error {
+	var packet []byte
+	if o.m.Protocol == "sacn" {
+		packet = e131(u, o.m.Universe, o.seq, o.cid, "audio-visualization")
+		o.seq++
+	} else {
+		// Art-Net sequence numbers run from 1 to 255; 0 turns them off
+		o.seq = o.seq%255 + 1
+		packet = artDmx(u, o.m.Universe, o.seq)
+	}
+	_, err := o.conn.Write(packet)
+	return err
+}
+
+// Addr returns the address packets are sent to.
+func (o *Output) Addr() net.Addr {
+	return o.conn.RemoteAddr()
+}
+
+// Close blacks out the lights and stops sending.
+func (o *Output) Close() error {
+	close(o.stop)
+	<-o.done
+	return o.conn.Close()
+}
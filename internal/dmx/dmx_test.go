@@ -0,0 +1,217 @@
+package dmx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/iburimskiy/audio-visualization/internal/config"
+)
+
+// fixtures covers every channel source at known addresses.
+var fixtures = []Fixture{
+	{Name: "par", Address: 1, Channels: []Channel{{Source: "red"}, {Source: "green"}, {Source: "blue"}}},
+	{Name: "dimmer", Address: 10, Channels: []Channel{
+		{Source: "level", Gain: 2},
+		{Source: "band", From: 0, To: 1, Min: 100, Max: 200},
+		{Source: "beat"},
+		{Source: "fixed", Value: 200},
+	}},
+}
+
+// wantChannels are the first 13 channels rendered from testState.
+var wantChannels = []byte{229, 45, 45, 0, 0, 0, 0, 0, 0, 128, 175, 0, 200}
+
+var testState = State{
+	Bands:  []float64{0.5, 1},
+	RMS:    0.25,
+	Visual: config.Visual{Saturation: 1, Brightness: 1},
+}
+
+// listen opens a UDP port standing in for the lights.
+func listen(t *testing.T) net.PacketConn {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// receive returns the next packet that carries the rendered channels,
+// skipping those sent before Update.
+func receive(t *testing.T, conn net.PacketConn, offset int) []byte {
+	t.Helper()
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n >= offset+len(wantChannels) && bytes.Equal(buf[offset:offset+len(wantChannels)], wantChannels) {
+			return append([]byte(nil), buf[:n]...)
+		}
+	}
+}
+
+// blackout waits for the all-dark packet sent by Close.
+func blackout(t *testing.T, conn net.PacketConn, offset int) {
+	t.Helper()
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == offset+512 && bytes.Equal(buf[offset:n], make([]byte, 512)) {
+			return
+		}
+	}
+}
+
+func TestArtNet(t *testing.T) {
+	conn := listen(t)
+	m := &Mapping{Protocol: "artnet", Target: conn.LocalAddr().String(), Universe: 0x1234, Rate: 44, Fixtures: fixtures}
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	o, err := Start(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Update(testState, false)
+
+	p := receive(t, conn, 18)
+	if len(p) != 18+512 || string(p[:8]) != "Art-Net\x00" {
+		t.Fatalf("packet of %d bytes starting %q", len(p), p[:8])
+	}
+	if op := binary.LittleEndian.Uint16(p[8:]); op != 0x5000 {
+		t.Errorf("opcode %#x", op)
+	}
+	if version := binary.BigEndian.Uint16(p[10:]); version != 14 {
+		t.Errorf("protocol version %d", version)
+	}
+	if p[12] == 0 {
+		t.Error("sequence 0")
+	}
+	if p[14] != 0x34 || p[15] != 0x12 {
+		t.Errorf("port-address %#x %#x", p[14], p[15])
+	}
+	if length := binary.BigEndian.Uint16(p[16:]); length != 512 {
+		t.Errorf("length %d", length)
+	}
+	next := receive(t, conn, 18)
+	if next[12] != p[12]%255+1 {
+		t.Errorf("sequence %d after %d", next[12], p[12])
+	}
+
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+	blackout(t, conn, 18)
+}
+
+func TestSACN(t *testing.T) {
+	conn := listen(t)
+	m := &Mapping{Protocol: "sacn", Target: conn.LocalAddr().String(), Universe: 7, Rate: 44, Fixtures: fixtures}
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	o, err := Start(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Update(testState, false)
+
+	p := receive(t, conn, 126)
+	if len(p) != 126+512 {
+		t.Fatalf("packet of %d bytes", len(p))
+	}
+	be16 := func(i int) int { return int(binary.BigEndian.Uint16(p[i:])) }
+	be32 := func(i int) int { return int(binary.BigEndian.Uint32(p[i:])) }
+	for _, f := range []struct {
+		name      string
+		got, want int
+	}{
+		{"preamble size", be16(0), 0x10},
+		{"postamble size", be16(2), 0},
+		{"root flags and length", be16(16), 0x7000 | (len(p) - 16)},
+		{"root vector", be32(18), 4},
+		{"framing flags and length", be16(38), 0x7000 | (len(p) - 38)},
+		{"framing vector", be32(40), 2},
+		{"priority", int(p[108]), 100},
+		{"universe", be16(113), 7},
+		{"DMP flags and length", be16(115), 0x7000 | (len(p) - 115)},
+		{"DMP vector", int(p[117]), 2},
+		{"address and data type", int(p[118]), 0xa1},
+		{"first address", be16(119), 0},
+		{"address increment", be16(121), 1},
+		{"property values", be16(123), 513},
+		{"start code", int(p[125]), 0},
+	} {
+		if f.got != f.want {
+			t.Errorf("%s: %#x, want %#x", f.name, f.got, f.want)
+		}
+	}
+	if string(p[4:16]) != "ASC-E1.17\x00\x00\x00" {
+		t.Errorf("packet identifier %q", p[4:16])
+	}
+	if bytes.Equal(p[22:38], make([]byte, 16)) {
+		t.Error("CID is zero")
+	}
+	if source := string(bytes.TrimRight(p[44:108], "\x00")); source != "audio-visualization" {
+		t.Errorf("source name %q", source)
+	}
+	next := receive(t, conn, 126)
+	if next[111] != p[111]+1 {
+		t.Errorf("sequence %d after %d", next[111], p[111])
+	}
+
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+	blackout(t, conn, 126)
+}
+
+func TestRenderBeat(t *testing.T) {
+	m := &Mapping{Fixtures: fixtures}
+	for _, tc := range []struct {
+		since float64
+		want  byte
+	}{
+		{-1, 0}, // no beat yet
+		{0, 255},
+		{0.125, 128},
+		{0.25, 0},
+		{1, 0},
+	} {
+		s := testState
+		s.SinceBeat = tc.since
+		var u Universe
+		m.render(&u, &s)
+		if u[11] != tc.want {
+			t.Errorf("%gs after a beat: %d, want %d", tc.since, u[11], tc.want)
+		}
+	}
+}
+
+func TestAddress(t *testing.T) {
+	for _, tc := range []struct {
+		m    Mapping
+		want string
+	}{
+		{Mapping{Protocol: "artnet", Target: "10.0.0.5"}, "10.0.0.5:6454"},
+		{Mapping{Protocol: "artnet", Target: "10.0.0.5:7000"}, "10.0.0.5:7000"},
+		{Mapping{Protocol: "sacn", Universe: 1}, "239.255.0.1:5568"},
+		{Mapping{Protocol: "sacn", Universe: 0x1234}, "239.255.18.52:5568"},
+	} {
+		if got := tc.m.address(); got != tc.want {
+			t.Errorf("%+v: %s, want %s", tc.m, got, tc.want)
+		}
+	}
+}
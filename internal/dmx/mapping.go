@@ -0,0 +1,225 @@
+package dmx
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+
+	"github.com/iburimskiy/audio-visualization/internal/config"
+	"github.com/iburimskiy/audio-visualization/internal/palette"
+)
+
+// Mapping describes where to send DMX and how the fixtures' channels follow
+// the audio. It is read from a JSON file.
+type Mapping struct {
+	Protocol string `json:"protocol"` // artnet or sacn
+	// Target is the receiver's host or host:port. It is required for
+	// Art-Net; for sACN it defaults to the universe's multicast group.
+	Target   string    `json:"target"`
+	Universe int       `json:"universe"` // Art-Net 0 to 32767, sACN 1 to 63999
+	Rate     float64   `json:"rate"`     // packets per second
+	Fixtures []Fixture `json:"fixtures"`
+}
+
+// Fixture is a light occupying consecutive channels from Address.
+type Fixture struct {
+	Name     string    `json:"name"`
+	Address  int       `json:"address"` // first channel, 1 to 512
+	Hue      float64   `json:"hue"`     // added to the color's hue (1.0 = full turn), to spread colors over fixtures
+	Channels []Channel `json:"channels"`
+}
+
+// Channel is one channel of a fixture.
+type Channel struct {
+	// Source is what drives the channel:
+	//   red, green, blue  the current color, like the audio bar
+	//   level             the RMS level
+	//   band              the average of bands From to To (inclusive)
+	//   beat              full on a beat, fading out over Decay seconds (default 0.25)
+	//   fixed             always Value
+	Source string  `json:"source"`
+	From   int     `json:"from"`
+	To     int     `json:"to"`
+	Decay  float64 `json:"decay"`
+	Value  int     `json:"value"`
+	// Gain scales level and band sources before clipping; 0 means 1.
+	Gain float64 `json:"gain"`
+	// Min and Max map 0 to 1 onto a DMX range; both 0 means 0 to 255.
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// LoadMapping reads and checks a mapping file.
+func LoadMapping(path string) (*Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Mapping{Protocol: "artnet", Rate: 40}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Validate returns every problem with m at once.
+func (m *Mapping) Validate() error {
+	var problems []error
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	switch m.Protocol {
+	case "artnet":
+		if m.Target == "" {
+			add("target: required for artnet")
+		}
+		if m.Universe < 0 || m.Universe > 32767 {
+			add("universe: %d is out of range (0 to 32767)", m.Universe)
+		}
+	case "sacn":
+		if m.Universe < 1 || m.Universe > 63999 {
+			add("universe: %d is out of range (1 to 63999)", m.Universe)
+		}
+	default:
+		add("protocol: %q is not one of artnet, sacn", m.Protocol)
+	}
+	if m.Rate < 1 || m.Rate > 44 {
+		add("rate: %g is out of range (1 to 44)", m.Rate)
+	}
+
+	used := make(map[int]string)
+	for i, f := range m.Fixtures {
+		name := fmt.Sprintf("fixtures[%d]", i)
+		if f.Address < 1 || f.Address+len(f.Channels)-1 > len(Universe{}) {
+			add("%s: channels %d to %d do not fit in 1 to 512", name, f.Address, f.Address+len(f.Channels)-1)
+		}
+		for j, c := range f.Channels {
+			cname := fmt.Sprintf("%s.channels[%d]", name, j)
+			if other, ok := used[f.Address+j]; ok {
+				add("%s: channel %d is also used by %s", cname, f.Address+j, other)
+			}
+			used[f.Address+j] = cname
+			switch c.Source {
+			case "red", "green", "blue", "level":
+			case "band":
+				if c.From < 0 || c.To < c.From {
+					add("%s: band range %d to %d is invalid", cname, c.From, c.To)
+				}
+			case "beat":
+				if c.Decay < 0 || c.Decay > 10 {
+					add("%s: decay %g is out of range (0 to 10)", cname, c.Decay)
+				}
+			case "fixed":
+				if c.Value < 0 || c.Value > 255 {
+					add("%s: value %d is out of range (0 to 255)", cname, c.Value)
+				}
+			default:
+				add("%s: source %q is not one of red, green, blue, level, band, beat, fixed", cname, c.Source)
+			}
+			if c.Min < 0 || c.Max > 255 || c.Min > c.Max {
+				add("%s: min %d and max %d are not a range within 0 to 255", cname, c.Min, c.Max)
+			}
+			if c.Gain < 0 {
+				add("%s: gain %g is negative", cname, c.Gain)
+			}
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// address returns the UDP address packets are sent to.
+func (m *Mapping) address() string {
+	port := ArtNetPort
+	if m.Protocol == "sacn" {
+		port = SACNPort
+	}
+	target := m.Target
+	if target == "" {
+		target = sacnGroup(m.Universe)
+	}
+	if _, _, err := net.SplitHostPort(target); err == nil {
+		return target
+	}
+	return net.JoinHostPort(target, fmt.Sprint(port))
+}
+
+// State is the analysis the channels are computed from.
+type State struct {
+	Bands      []float64 // smoothed band levels, 0 to 1
+	RMS        float64
+	ColorPhase float64 // hue offset of the visuals (1.0 = full turn)
+	Visual     config.Visual
+	SinceBeat  float64 // seconds since the last beat; negative if none yet
+}
+
+// render fills u from s. Channels not used by any fixture stay 0.
+func (m *Mapping) render(u *Universe, s *State) {
+	*u = Universe{}
+	for _, f := range m.Fixtures {
+		// The same color as the low end of the audio bar, shifted per fixture
+		h := (s.ColorPhase+f.Hue)*360 + s.Visual.HueOffset*360
+		sat := math.Min(1, 0.8*s.Visual.Saturation)
+		val := math.Min(1, 0.9*s.Visual.Brightness)
+		r, g, b := palette.HSVToRGB(h, sat, val)
+
+		for i, c := range f.Channels {
+			var v float64
+			switch c.Source {
+			case "red":
+				v = float64(r) / 255
+			case "green":
+				v = float64(g) / 255
+			case "blue":
+				v = float64(b) / 255
+			case "level":
+				v = s.RMS * gain(c.Gain)
+			case "band":
+				v = bandAverage(s.Bands, c.From, c.To) * gain(c.Gain)
+			case "beat":
+				if s.SinceBeat >= 0 {
+					v = 1 - s.SinceBeat/cmp.Or(c.Decay, defaultDecay)
+				}
+			case "fixed":
+				u[f.Address-1+i] = byte(c.Value)
+				continue
+			}
+			lo, hi := c.Min, c.Max
+			if lo == 0 && hi == 0 {
+				hi = 255
+			}
+			v = math.Max(0, math.Min(1, v))
+			u[f.Address-1+i] = byte(math.Round(float64(lo) + v*float64(hi-lo)))
+		}
+	}
+}
+
+// defaultDecay is the fade-out of beat channels without a decay.
+const defaultDecay = 0.25
+
+func gain(g float64) float64 {
+	return cmp.Or(g, 1)
+}
+
+// bandAverage averages bands from to to, clipped to the available bands.
+func bandAverage(bands []float64, from, to int) float64 {
+	to = min(to, len(bands)-1)
+	if from > to {
+		return 0
+	}
+	var sum float64
+	for _, v := range bands[from : to+1] {
+		sum += v
+	}
+	return sum / float64(to-from+1)
+}
@@ -0,0 +1,64 @@
+package dmx
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Universe holds the 512 channel values of one DMX universe; channel 1 is
+// index 0.
+type Universe [512]byte
+
+// Default UDP ports.
+const (
+	ArtNetPort = 6454
+	SACNPort   = 5568
+)
+
+// artDmx encodes an Art-Net ArtDmx packet. universe is the 15 bit
+// port-address (net, sub-net and universe); seq 0 disables reordering.
+func artDmx(u *Universe, universe int, seq byte) []byte {
+	b := make([]byte, 18+len(u))
+	copy(b, "Art-Net\x00")
+	binary.LittleEndian.PutUint16(b[8:], 0x5000) // OpDmx
+	binary.BigEndian.PutUint16(b[10:], 14)       // protocol version
+	b[12] = seq
+	b[13] = 0 // physical port
+	b[14] = byte(universe)
+	b[15] = byte(universe>>8) & 0x7f
+	binary.BigEndian.PutUint16(b[16:], uint16(len(u)))
+	copy(b[18:], u[:])
+	return b
+}
+
+// e131 encodes an E1.31 (sACN) data packet for universe 1 to 63999 from the
+// source identified by cid.
+func e131(u *Universe, universe int, seq byte, cid [16]byte, source string) []byte {
+	b := make([]byte, 126+len(u))
+	// Root layer
+	binary.BigEndian.PutUint16(b[0:], 0x0010) // preamble size
+	copy(b[4:], "ASC-E1.17\x00\x00\x00")
+	binary.BigEndian.PutUint16(b[16:], 0x7000|uint16(len(b)-16))
+	binary.BigEndian.PutUint32(b[18:], 0x00000004) // VECTOR_ROOT_E131_DATA
+	copy(b[22:38], cid[:])
+	// Framing layer
+	binary.BigEndian.PutUint16(b[38:], 0x7000|uint16(len(b)-38))
+	binary.BigEndian.PutUint32(b[40:], 0x00000002) // VECTOR_E131_DATA_PACKET
+	copy(b[44:107], source)                        // NUL terminated in 64 bytes
+	b[108] = 100                                   // priority
+	b[111] = seq
+	binary.BigEndian.PutUint16(b[113:], uint16(universe))
+	// DMP layer
+	binary.BigEndian.PutUint16(b[115:], 0x7000|uint16(len(b)-115))
+	b[117] = 0x02                                         // VECTOR_DMP_SET_PROPERTY
+	b[118] = 0xa1                                         // address and data type
+	binary.BigEndian.PutUint16(b[121:], 1)                // address increment
+	binary.BigEndian.PutUint16(b[123:], uint16(len(u)+1)) // start code and slots
+	copy(b[126:], u[:])
+	return b
+}
+
+// sacnGroup returns the multicast address of an E1.31 universe.
+func sacnGroup(universe int) string {
+	return fmt.Sprintf("239.255.%d.%d", universe>>8, universe&0xff)
+}
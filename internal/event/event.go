@@ -0,0 +1,190 @@
+// Package event passes playback and analysis events, such as a loaded track
+// or a detected beat, to whoever wants to hook them. Events are posted from
+// any goroutine and delivered by the game once per frame, so subscribers run
+// on the game goroutine unless they ask for delivery on a goroutine of their
+// own, as network integrations do so a slow peer cannot hold up a frame.
+package event
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event is one of the types below.
+type Event interface {
+	event()
+}
+
+// TrackLoaded is posted when a track or live input starts playing.
+type TrackLoaded struct {
+	Serial   int           // identifies the track in the player's state
+	Track    string        // path or URL; empty for live input
+	Live     bool          // the track has no length and cannot seek
+	Duration time.Duration // 0 for live input
+}
+
+// TrackFailed is posted when a track could not be opened. The previous
+// track, if any, keeps playing.
+type TrackFailed struct {
+	Track string
+	Err   error
+}
+
+// TrackEnded is posted when a track has played to its end. A live input
+// that stopped is followed by an Error saying why.
+type TrackEnded struct {
+	Serial int
+	Track  string
+}
+
+// Paused and Resumed are posted when playback pauses and continues.
+type Paused struct {
+	Position time.Duration
+}
+
+type Resumed struct {
+	Position time.Duration
+}
+
+// Seeked is posted once the decoder has moved. Of several quick seeks only
+// the last one is carried out and posted.
+type Seeked struct {
+	Position time.Duration
+}
+
+// Beat is posted for every detected beat.
+type Beat struct {
+	Time float64 // seconds since the player started
+	BPM  float64 // detected tempo, 0 if unknown
+	RMS  float64 // level of the newest samples, 0 to 1
+}
+
+// Error is posted for errors no caller is waiting for, which the game shows
+// in its status line.
+type Error struct {
+	Err error
+}
+
+// SceneChanged is posted when other scenes are shown.
+type SceneChanged struct {
+	Scenes string // names joined with "+"
+}
+
+func (TrackLoaded) event()  {}
+func (TrackFailed) event()  {}
+func (TrackEnded) event()   {}
+func (Paused) event()       {}
+func (Resumed) event()      {}
+func (Seeked) event()       {}
+func (Beat) event()         {}
+func (Error) event()        {}
+func (SceneChanged) event() {}
+
+// Bus queues posted events until they are dispatched to the subscribers.
+type Bus struct {
+	mu    sync.Mutex
+	queue []Event
+	subs  []*subscription
+}
+
+type subscription struct {
+	fn       func(Event)
+	ch       chan Event // nil for delivery by Dispatch
+	canceled atomic.Bool
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Post queues e for the next Dispatch. It is safe to call from any
+// goroutine, and does nothing on a nil Bus.
+func (b *Bus) Post(e Event) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.queue = append(b.queue, e)
+	b.mu.Unlock()
+}
+
+// Dispatch delivers the queued events in the order they were posted.
+// Synchronous subscribers run on the caller's goroutine; events they post
+// wait for the next call.
+func (b *Bus) Dispatch() {
+	b.mu.Lock()
+	queue := b.queue
+	b.queue = nil
+	var subs []*subscription
+	for _, s := range b.subs {
+		if s.ch == nil {
+			subs = append(subs, s)
+			continue
+		}
+		// Asynchronous subscribers are handed the events under the lock, so a
+		// canceled one's channel is never written after it is closed
+		for _, e := range queue {
+			select {
+			case s.ch <- e:
+			default:
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	for _, e := range queue {
+		for _, s := range subs {
+			if !s.canceled.Load() {
+				s.fn(e)
+			}
+		}
+	}
+}
+
+// Subscribe calls fn for every event on the goroutine that calls Dispatch.
+// The returned function cancels the subscription.
+func (b *Bus) Subscribe(fn func(Event)) (cancel func()) {
+	return b.add(&subscription{fn: fn})
+}
+
+// SubscribeAsync calls fn on a goroutine of its own. Up to buffer events
+// wait for fn; later ones are dropped until it catches up, so a slow
+// subscriber never holds up Dispatch. Canceling lets fn finish the events
+// already waiting.
+func (b *Bus) SubscribeAsync(buffer int, fn func(Event)) (cancel func()) {
+	s := &subscription{fn: fn, ch: make(chan Event, buffer)}
+	go func() {
+		for e := range s.ch {
+			fn(e)
+		}
+	}()
+	return b.add(s)
+}
+
+func (b *Bus) add(s *subscription) func() {
+	b.mu.Lock()
+	b.subs = append(b.subs, s)
+	b.mu.Unlock()
+	return func() {
+		if s.canceled.Swap(true) {
+			return
+		}
+		b.mu.Lock()
+		b.subs = slices.DeleteFunc(b.subs, func(other *subscription) bool { return other == s })
+		if s.ch != nil {
+			close(s.ch)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// On subscribes fn to the events of type T, delivered by Dispatch.
+func On[T Event](b *Bus, fn func(T)) (cancel func()) {
+	return b.Subscribe(func(e Event) {
+		if e, ok := e.(T); ok {
+			fn(e)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+// Package feed broadcasts the live analysis to other programs, such as a
+// browser overlay or an LED controller, over WebSocket.
+package feed
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"sync"
+)
+
+// Frame is the analysis of one rendered frame.
+type Frame struct {
+	Seq      uint32    `json:"seq"`      // counts published frames; gaps mean frames were skipped
+	Time     float64   `json:"time"`     // seconds since the player started
+	Position float64   `json:"position"` // playback position in seconds
+	RMS      float64   `json:"rms"`      // level of the newest samples, 0 to 1
+	Beat     bool      `json:"beat"`     // a beat was detected since the previous message
+	BPM      float64   `json:"bpm"`      // detected tempo, 0 if unknown
+	Bands    []float32 `json:"bands"`    // smoothed band levels, 0 to 1
+}
+
+// binaryVersion is the first byte of binary messages.
+const binaryVersion = 1
+
+// MarshalBinary encodes f in little endian as: version (u8), flags (u8, bit 0
+// is Beat), band count (u16), Seq (u32), then Time, Position, RMS and BPM
+// (f32 each) followed by the bands (f32 each).
+func (f *Frame) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 24+4*len(f.Bands))
+	b[0] = binaryVersion
+	if f.Beat {
+		b[1] = 1
+	}
+	binary.LittleEndian.PutUint16(b[2:], uint16(len(f.Bands)))
+	binary.LittleEndian.PutUint32(b[4:], f.Seq)
+	for i, v := range []float64{f.Time, f.Position, f.RMS, f.BPM} {
+		binary.LittleEndian.PutUint32(b[8+4*i:], math.Float32bits(float32(v)))
+	}
+	for i, v := range f.Bands {
+		binary.LittleEndian.PutUint32(b[24+4*i:], math.Float32bits(v))
+	}
+	return b, nil
+}
+
+// Broadcaster hands frames to every connected client without waiting for
+// them. Each client holds only the newest frame it has not sent yet, so a
+// slow client skips frames instead of falling behind or holding up the
+// publisher, and beats from skipped frames are carried over.
+type Broadcaster struct {
+	mu      sync.Mutex
+	clients map[*client]struct{}
+	seq     uint32
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{clients: map[*client]struct{}{}}
+}
+
+// Active reports whether anyone is listening, so callers can skip building
+// frames.
+func (b *Broadcaster) Active() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.clients) > 0
+}
+
+// Publish sends f to every client. The bands are copied, so the caller may
+// reuse them.
+func (b *Broadcaster) Publish(f Frame) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.clients) == 0 {
+		return
+	}
+	b.seq++
+	f.Seq = b.seq
+	f.Bands = append([]float32(nil), f.Bands...)
+	for c := range b.clients {
+		c.offer(f)
+	}
+}
+
+func (b *Broadcaster) add(c *client) {
+	b.mu.Lock()
+	b.clients[c] = struct{}{}
+	b.mu.Unlock()
+}
+
+func (b *Broadcaster) remove(c *client) {
+	b.mu.Lock()
+	delete(b.clients, c)
+	b.mu.Unlock()
+}
+
+// client is the per-connection slot between Publish and the writer.
+type client struct {
+	binary bool
+	wake   chan struct{} // signaled when a frame is waiting
+
+	mu      sync.Mutex
+	pending Frame
+	waiting bool
+}
+
+func newClient(binary bool) *client {
+	return &client{binary: binary, wake: make(chan struct{}, 1)}
+}
+
+// offer replaces the waiting frame with f, keeping its beat.
+func (c *client) offer(f Frame) {
+	c.mu.Lock()
+	f.Beat = f.Beat || (c.waiting && c.pending.Beat)
+	c.pending = f
+	c.waiting = true
+	c.mu.Unlock()
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the waiting frame, encoded for the client.
+func (c *client) take() ([]byte, bool) {
+	c.mu.Lock()
+	f, ok := c.pending, c.waiting
+	c.waiting = false
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	var data []byte
+	if c.binary {
+		data, _ = f.MarshalBinary()
+	} else {
+		data, _ = json.Marshal(&f)
+	}
+	return data, true
+}
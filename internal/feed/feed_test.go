@@ -0,0 +1,183 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestMarshalBinary(t *testing.T) {
+	f := Frame{Seq: 0x01020304, Time: 1.5, Position: 90.25, RMS: 0.5, Beat: true, BPM: 128, Bands: []float32{0, 0.25, 1}}
+	b, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 24+3*4 {
+		t.Fatalf("%d bytes", len(b))
+	}
+	if b[0] != binaryVersion || b[1] != 1 || binary.LittleEndian.Uint16(b[2:]) != 3 {
+		t.Errorf("header % x", b[:4])
+	}
+	if !bytes.Equal(b[4:8], []byte{0x04, 0x03, 0x02, 0x01}) {
+		t.Errorf("seq % x", b[4:8])
+	}
+	f32 := func(off int) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(b[off:])) }
+	for i, want := range []float32{1.5, 90.25, 0.5, 128, 0, 0.25, 1} {
+		if got := f32(8 + 4*i); got != want {
+			t.Errorf("float at %d: %v, want %v", 8+4*i, got, want)
+		}
+	}
+
+	f.Beat, f.Bands = false, nil
+	b, _ = f.MarshalBinary()
+	if len(b) != 24 || b[1] != 0 || binary.LittleEndian.Uint16(b[2:]) != 0 {
+		t.Errorf("without beat and bands: % x", b)
+	}
+}
+
+func TestOfferKeepsBeat(t *testing.T) {
+	c := newClient(false)
+	c.offer(Frame{Seq: 1, Beat: true})
+	c.offer(Frame{Seq: 2})
+	c.offer(Frame{Seq: 3})
+	data, ok := c.take()
+	var f Frame
+	if !ok || json.Unmarshal(data, &f) != nil || f.Seq != 3 || !f.Beat {
+		t.Fatalf("after skipped beat: %s", data)
+	}
+	if _, ok := c.take(); ok {
+		t.Error("frame taken twice")
+	}
+
+	// Once sent, the beat is not repeated
+	c.offer(Frame{Seq: 4})
+	data, _ = c.take()
+	if json.Unmarshal(data, &f) != nil || f.Seq != 4 || f.Beat {
+		t.Errorf("next frame: %s", data)
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	const frames, beatAt = 400, 5
+	b := NewBroadcaster()
+	srv := httptest.NewServer(b.Handler())
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url+"?format=xml", nil); err == nil || resp.StatusCode != 400 {
+		t.Errorf("unknown format: %v", err)
+	}
+
+	fast, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fast.Close()
+	slow, _, err := websocket.DefaultDialer.Dial(url+"?format=binary", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		b.mu.Lock()
+		n := len(b.clients)
+		b.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d clients connected", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The fast client reads everything it is sent
+	fastSeqs := make(chan []uint32, 1)
+	go func() {
+		var seqs []uint32
+		defer func() { fastSeqs <- seqs }()
+		for {
+			var f Frame
+			if err := fast.ReadJSON(&f); err != nil {
+				return
+			}
+			seqs = append(seqs, f.Seq)
+			if f.Seq == frames {
+				return
+			}
+		}
+	}()
+
+	// Large frames published faster than anyone can read them: the slow
+	// client, which reads nothing yet, soon fills the connection's buffers
+	bands := make([]float32, 16384)
+	start := time.Now()
+	for i := 1; i <= frames; i++ {
+		b.Publish(Frame{Beat: i == beatAt, Bands: bands})
+	}
+	if d := time.Since(start); d > writeTimeout/2 {
+		t.Errorf("publishing took %v", d)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	var got []uint32
+	var beats []uint32
+	_ = slow.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for len(got) == 0 || got[len(got)-1] != frames {
+		mt, data, err := slow.ReadMessage()
+		if err != nil {
+			t.Fatalf("slow client after %d frames: %v", len(got), err)
+		}
+		if mt != websocket.BinaryMessage || len(data) != 24+4*len(bands) {
+			t.Fatalf("message type %d of %d bytes", mt, len(data))
+		}
+		seq := binary.LittleEndian.Uint32(data[4:])
+		if len(got) > 0 && seq <= got[len(got)-1] {
+			t.Fatalf("frame %d after %d", seq, got[len(got)-1])
+		}
+		got = append(got, seq)
+		if data[1]&1 != 0 {
+			beats = append(beats, seq)
+		}
+	}
+	if len(got) >= frames {
+		t.Errorf("slow client got all %d frames", len(got))
+	}
+	// The beat arrives with the first frame sent after it
+	var want uint32
+	for _, seq := range got {
+		if seq >= beatAt {
+			want = seq
+			break
+		}
+	}
+	if len(beats) != 1 || beats[0] != want {
+		t.Errorf("beats in frames %v, want %d", beats, want)
+	}
+
+	select {
+	case seqs := <-fastSeqs:
+		if len(seqs) == 0 || seqs[len(seqs)-1] != frames {
+			t.Errorf("fast client got %d frames", len(seqs))
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("fast client did not get the last frame")
+	}
+
+	// Clients that leave are forgotten
+	fast.Close()
+	slow.Close()
+	for deadline := time.Now().Add(2 * time.Second); b.Active(); {
+		if time.Now().After(deadline) {
+			t.Fatal("clients still registered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
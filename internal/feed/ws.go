@@ -0,0 +1,75 @@
+package feed
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// writeTimeout disconnects clients that stop reading.
+const writeTimeout = 5 * time.Second
+
+var upgrader = websocket.Upgrader{
+	// Overlays are usually local pages or other origins; access is
+	// controlled by the remote control token instead
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// Handler upgrades requests to WebSocket connections that receive every
+// published frame, as JSON text messages or, with ?format=binary, as binary
+// messages in the layout of Frame.MarshalBinary.
+func (b *Broadcaster) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var binary bool
+		switch r.URL.Query().Get("format") {
+		case "", "json":
+		case "binary":
+			binary = true
+		default:
+			http.Error(w, `format must be "json" or "binary"`, http.StatusBadRequest)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// The upgrader has already answered
+			return
+		}
+		c := newClient(binary)
+		b.add(c)
+		defer b.remove(c)
+		defer conn.Close()
+
+		// Read to handle pings and notice when the client goes away;
+		// anything it sends is ignored
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		mt := websocket.TextMessage
+		if binary {
+			mt = websocket.BinaryMessage
+		}
+		for {
+			select {
+			case <-c.wake:
+			case <-closed:
+				return
+			}
+			data, ok := c.take()
+			if !ok {
+				continue
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteMessage(mt, data); err != nil {
+				return
+			}
+		}
+	})
+}
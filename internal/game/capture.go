@@ -0,0 +1,107 @@
+package game
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"github.com/iburimskiy/audio-visualization/internal/capture"
+	"github.com/iburimskiy/audio-visualization/internal/record"
+)
+
+// captureAudioFile is the audio written next to the captured frames.
+const captureAudioFile = "audio.wav"
+
+func (g *game) toggleCapture() error {
+	if g.capture != nil {
+		return g.stopCapture()
+	}
+
+	dir := g.expandTemplate(g.cfg.Capture.Template)
+	session, err := capture.Start(capture.Options{
+		Dir:     dir,
+		Format:  g.cfg.Capture.Format,
+		Quality: g.cfg.Capture.JPEGQuality,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Frame times follow the recorded audio so the two stay in sync; without
+	// a track there is only the wall clock
+	if g.playback.Rate != 0 {
+		rec, err := record.Start(filepath.Join(dir, captureAudioFile), g.playback.Rate)
+		if err != nil {
+			_ = session.Stop()
+			return err
+		}
+		g.captureAudio = rec
+		g.player.AddSink(rec)
+	}
+	g.capture = session
+	g.captureStart = time.Now()
+	fmt.Printf("Capturing to %v\n", dir)
+	return nil
+}
+
+func (g *game) stopCapture() error {
+	if g.capture == nil {
+		return nil
+	}
+	session := g.capture
+	g.capture = nil
+	var audioErr error
+	if g.captureAudio != nil {
+		g.player.RemoveSink(g.captureAudio)
+		audioErr = g.captureAudio.Stop()
+		g.captureAudio = nil
+	}
+	if err := session.Stop(); err != nil {
+		return fmt.Errorf("capture %s: %w", session.Dir(), err)
+	}
+	if audioErr != nil {
+		return fmt.Errorf("capture %s: %w", session.Dir(), audioErr)
+	}
+	fmt.Printf("Saved capture %v (%d frames, %d dropped)\n", session.Dir(), session.Written(), session.Dropped())
+	return nil
+}
+
+// captureTime returns the position of the capture, measured by the audio
+// written when there is any.
+func (g *game) captureTime() time.Duration {
+	if g.captureAudio != nil {
+		return g.captureAudio.Duration()
+	}
+	return time.Since(g.captureStart)
+}
+
+// captureFrame grabs the finished screen for a running capture and a pending
+// screenshot. It runs at the end of Draw, before the recording indicators.
+func (g *game) captureFrame(screen *ebiten.Image) {
+	if g.capture == nil && !g.screenshotPending {
+		return
+	}
+	var img *image.RGBA
+	if g.capture != nil {
+		img = g.capture.Frame(screen.Bounds())
+	} else {
+		img = image.NewRGBA(screen.Bounds())
+	}
+	screen.ReadPixels(img.Pix)
+
+	if g.screenshotPending {
+		g.screenshotPending = false
+		path := g.expandTemplate(g.cfg.Capture.Screenshot)
+		if err := capture.SavePNG(path, img); err != nil {
+			g.report(err)
+		} else {
+			fmt.Printf("Saved screenshot %v\n", path)
+		}
+	}
+	if g.capture != nil {
+		g.capture.Add(img, g.captureTime())
+	}
+}
@@ -0,0 +1,168 @@
+package game
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/iburimskiy/audio-visualization/internal/control"
+	"github.com/iburimskiy/audio-visualization/internal/dmx"
+	"github.com/iburimskiy/audio-visualization/internal/feed"
+	"github.com/iburimskiy/audio-visualization/internal/player"
+	"github.com/iburimskiy/audio-visualization/internal/playlist"
+)
+
+// pollControl carries out the commands queued by remote controls.
+func (g *game) pollControl() {
+	if g.control == nil {
+		return
+	}
+	for cmd := g.control.Pending(); cmd != nil; cmd = g.control.Pending() {
+		err := g.runCommand(cmd)
+		if cmd.Posted() {
+			g.report(err)
+		}
+		// Publish first, so the sender reads the status after the command
+		g.publishStatus()
+		cmd.Reply(err)
+	}
+}
+
+func (g *game) runCommand(cmd *control.Command) error {
+	switch cmd.Kind {
+	case control.Play:
+		if !g.playback.Loaded {
+			return g.playCurrent()
+		}
+		return g.do(player.Command{Kind: player.Resume})
+	case control.Pause:
+		return g.do(player.Command{Kind: player.Pause})
+	case control.Toggle:
+		if !g.playback.Loaded {
+			return g.playCurrent()
+		}
+		return g.togglePause()
+	case control.Seek:
+		if !g.playback.Loaded {
+			return errors.New("nothing is playing")
+		}
+		return g.seekToTime(time.Duration(cmd.Value * float64(time.Second)))
+	case control.Next:
+		path, ok := g.queue.Next()
+		g.skipTo(path, ok, g.queue.Next)
+	case control.Previous:
+		path, ok := g.queue.Previous()
+		g.skipTo(path, ok, g.queue.Previous)
+	case control.Volume:
+		return g.setGain(cmd.Value / 100)
+	case control.Scene:
+		return g.director.SwitchTo(strings.Split(cmd.Text, "+")...)
+	case control.Enqueue:
+		tracks, err := playlist.Expand([]string{cmd.Text})
+		if err != nil {
+			return err
+		}
+		if len(tracks) == 0 {
+			return errors.New("no audio files found")
+		}
+		first := g.queue.Len()
+		g.queue.Add(tracks...)
+		if cmd.Value != 0 {
+			path, ok := g.queue.Jump(first)
+			g.skipTo(path, ok, g.queue.Next)
+		} else if !g.playback.Loaded {
+			return g.playCurrent()
+		}
+	case control.Param:
+		cfg, err := g.cfg.WithVisual(cmd.Text, cmd.Value)
+		if err != nil {
+			return err
+		}
+		g.cfg = cfg
+	default:
+		return errors.New("unknown command")
+	}
+	return nil
+}
+
+// playCurrent starts the current queued track when nothing is playing.
+func (g *game) playCurrent() error {
+	path, ok := g.queue.Current()
+	if !ok {
+		return errors.New("the queue is empty")
+	}
+	return g.loadAndPlay(path)
+}
+
+// setGain changes the volume of the playing track and of later ones.
+func (g *game) setGain(gain float64) error {
+	return g.do(player.Command{Kind: player.Gain, Gain: gain})
+}
+
+// publishFeed sends this frame's analysis to WebSocket clients and OSC
+// destinations.
+func (g *game) publishFeed() {
+	toFeed := g.feed != nil && g.feed.Active()
+	if !toFeed && g.osc == nil {
+		return
+	}
+	g.feedBands = g.feedBands[:0]
+	for _, v := range g.audioData {
+		g.feedBands = append(g.feedBands, float32(v))
+	}
+	f := feed.Frame{
+		Time:     g.time,
+		Position: g.playback.Position.Seconds(),
+		RMS:      g.rms,
+		Beat:     g.beat,
+		BPM:      g.beats.BPM(),
+		Bands:    g.feedBands,
+	}
+	if toFeed {
+		g.feed.Publish(f)
+	}
+	if g.osc != nil {
+		// Lost UDP packets are not worth reporting; the next frame follows
+		_ = g.osc.Send(f)
+	}
+}
+
+// publishLights hands this frame's analysis to the DMX output.
+func (g *game) publishLights() {
+	if g.dmx == nil {
+		return
+	}
+	g.dmx.Update(dmx.State{
+		Bands:      g.audioData,
+		RMS:        g.rms,
+		ColorPhase: g.colorPhase,
+		Visual:     g.cfg.Visual,
+	}, g.beat)
+}
+
+// publishStatus shares the player's state with remote controls.
+func (g *game) publishStatus() {
+	if g.control == nil {
+		return
+	}
+	s := control.Status{
+		State:    "stopped",
+		Track:    g.playback.Track,
+		Title:    g.playback.Title,
+		Position: g.playback.Position.Seconds(),
+		Duration: g.playback.Duration.Seconds(),
+		Live:     g.playback.Live,
+		Volume:   g.playback.Gain * 100,
+		Scenes:   strings.Join(g.director.Current().Names(), "+"),
+		BPM:      g.beats.BPM(),
+		Queue:    g.queue.Tracks(),
+		Index:    g.queue.Index(),
+	}
+	if g.playback.Loaded {
+		s.State = "playing"
+		if g.playback.Paused {
+			s.State = "paused"
+		}
+	}
+	g.control.Publish(s)
+}
@@ -5,78 +5,210 @@ import (
 	"fmt"
 	"image/color"
 	"math"
-	"os"
-	"path/filepath"
+	"strings"
 	"time"
 
-	"github.com/faiface/beep"
-	"github.com/faiface/beep/flac"
-	"github.com/faiface/beep/mp3"
-	"github.com/faiface/beep/speaker"
-	"github.com/faiface/beep/wav"
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 	"github.com/ncruces/zenity"
 
+	"github.com/iburimskiy/audio-visualization/internal/analysis"
+	"github.com/iburimskiy/audio-visualization/internal/audio"
+	"github.com/iburimskiy/audio-visualization/internal/capture"
+	"github.com/iburimskiy/audio-visualization/internal/clock"
 	"github.com/iburimskiy/audio-visualization/internal/config"
+	"github.com/iburimskiy/audio-visualization/internal/control"
+	"github.com/iburimskiy/audio-visualization/internal/dmx"
+	"github.com/iburimskiy/audio-visualization/internal/event"
+	"github.com/iburimskiy/audio-visualization/internal/feed"
+	"github.com/iburimskiy/audio-visualization/internal/input"
+	"github.com/iburimskiy/audio-visualization/internal/osc"
+	"github.com/iburimskiy/audio-visualization/internal/player"
+	"github.com/iburimskiy/audio-visualization/internal/playlist"
+	"github.com/iburimskiy/audio-visualization/internal/record"
+	"github.com/iburimskiy/audio-visualization/internal/visual"
 )
 
 type game struct {
+	cfg           *config.Config
+	configUpdates <-chan config.Update
+	configErr     error
+
 	// audio
-	currentFile *os.File
-	streamer    beep.StreamSeekCloser
-	format      beep.Format
-	ctrl        *beep.Ctrl
-	tap         *visualTap
+	player    *player.Player
+	null      *player.Null // output pumped by the clock in no-audio mode; nil otherwise
+	playback  player.State // refreshed every frame and after every command
+	queue     *playlist.Queue
+	loading   string                // track last asked for
+	skip      func() (string, bool) // steps past tracks that fail to load; nil when not skipping
+	skipTries int
+	events    *event.Bus // playback and analysis events, delivered at the end of every update
+	recorder  *record.Recorder
+	control   *control.Hub      // remote control commands; nil when disabled
+	feed      *feed.Broadcaster // analysis sent to WebSocket clients; nil when disabled
+	feedBands []float32
+	osc       *osc.Sender // analysis sent over OSC; nil when disabled
+	dmx       *dmx.Output // lights driven by the analysis; nil when disabled
+
+	// capture
+	capture           *capture.Session
+	captureAudio      *record.Recorder
+	captureStart      time.Time
+	screenshotPending bool
+
+	// layout
+	layout layout
 
 	// viz
-	audioData  []float64
-	time       float64
-	rotation   float64
-	colorPhase float64
+	clock       clock.Clock
+	dt          float64
+	audioData   []float64
+	stereo      [][2]float64
+	correlation float64
+	time        float64
+	rotation    float64
+	colorPhase  float64
+	director    *visual.Director
+	scenes      string // shown last frame, to post SceneChanged
+	frame       visual.Frame
+	beats       *analysis.BeatTracker
+	beat        bool
+	rms         float64 // level of the newest samples
 
 	// progress bar
 	progressBarHovered   bool
 	progressBarDragging  bool
 	progressBarDragStart float64
-	audioDuration        time.Duration
-	audioPosition        time.Duration
-	lastSeekTime         time.Time
 
-	// input edge detection
-	prevKey map[ebiten.Key]bool
+	// input
+	keymap   *input.Keymap
+	pressed  []string // actions triggered this frame
+	showHelp bool
 
 	// button state
 	buttonHovered bool
 	buttonPressed bool
 
 	// state
-	paused   bool
-	initDone bool
-	lastErr  error
+	lastErr error
 }
 
-func NewGame() *game {
-	return &game{
-		prevKey: map[ebiten.Key]bool{},
-	}
+// Options configure a new game.
+type Options struct {
+	// Config is the validated configuration to start with.
+	Config *config.Config
+	// Clock drives animation; use clock.NewReal() for interactive use or a
+	// clock.Fixed for offline runs.
+	Clock clock.Clock
+	// ConfigUpdates, if set, delivers reloaded configs to apply while playing.
+	ConfigUpdates <-chan config.Update
+
+	// Files are played in order, starting right away.
+	Files []string
+	// Scene overrides the default scenes; names are joined with "+".
+	Scene string
+	// Volume is the playback gain in percent; 0 mutes.
+	Volume float64
+	// Start skips into the first track.
+	Start time.Duration
+	// Loop starts over from the first file after the last one.
+	Loop bool
+	// NoAudio decodes and visualizes without opening an audio device.
+	NoAudio bool
+	// Input, if set, is a live source played instead of Files.
+	Input audio.Source
+	// Control, if set, delivers commands from remote controls.
+	Control *control.Hub
+	// Feed and OSC, if set, receive the analysis of every frame.
+	Feed *feed.Broadcaster
+	OSC  *osc.Sender
+	// DMX, if set, drives lights from the analysis.
+	DMX *dmx.Output
+	// Events, if set, receives playback and analysis events. They are
+	// delivered to synchronous subscribers at the end of every update.
+	Events *event.Bus
 }
 
-func (g *game) Update() error {
+func NewGame(opts Options) (*game, error) {
+	cfg := opts.Config
+	width, height := cfg.Window.Width, cfg.Window.Height
+	g := &game{
+		cfg:           cfg,
+		configUpdates: opts.ConfigUpdates,
+		clock:         opts.Clock,
+		director:      visual.NewDirector(width, height),
+		beats:         analysis.NewBeatTracker(),
+		layout:        newLayout(width, height, 1),
+		queue:         playlist.NewQueue(opts.Files),
+		control:       opts.Control,
+		feed:          opts.Feed,
+		osc:           opts.OSC,
+		dmx:           opts.DMX,
+		events:        opts.Events,
+	}
+	if g.events == nil {
+		g.events = event.NewBus()
+	}
+	// Errors end up in the status line wherever they come from
+	event.On(g.events, func(e event.Error) { g.lastErr = e.Err })
+	event.On(g.events, g.trackLoaded)
+	event.On(g.events, g.trackFailed)
+	event.On(g.events, g.trackEnded)
+	g.queue.Loop = opts.Loop
+	if err := g.applySceneConfig(nil); err != nil {
+		return nil, err
+	}
+	if err := g.applyKeyConfig(); err != nil {
+		return nil, err
+	}
+	if err := g.director.Current().Set(cfg.Scenes.Default...); err != nil {
+		return nil, fmt.Errorf("scenes.default: %w", err)
+	}
+	if opts.Scene != "" {
+		if err := g.director.Current().Set(strings.Split(opts.Scene, "+")...); err != nil {
+			return nil, err
+		}
+	}
+	g.scenes = strings.Join(g.director.Current().Names(), "+")
 
-	justPressed := func(k ebiten.Key) bool {
-		pressed := ebiten.IsKeyPressed(k)
-		jp := pressed && !g.prevKey[k]
-		g.prevKey[k] = pressed
-		return jp
+	var out player.Output = speakerOutput{}
+	if opts.NoAudio {
+		g.null = &player.Null{}
+		out = g.null
 	}
+	g.player = player.New(out, opts.Volume/100, g.events)
+	if err := g.startPlaying(opts); err != nil {
+		_ = g.player.Close()
+		return nil, err
+	}
+	return g, nil
+}
+
+// startPlaying plays the live input, or the first queued file from
+// opts.Start.
+func (g *game) startPlaying(opts Options) error {
+	if opts.Input != nil {
+		return g.playSource(opts.Input, "")
+	}
+	path, ok := g.queue.Current()
+	if !ok {
+		return nil
+	}
+	g.skip, g.skipTries = g.queue.Next, 0
+	cmd := g.openCommand(path)
+	cmd.Position = opts.Start
+	g.loading = path
+	return g.do(cmd)
+}
+
+func (g *game) Update() error {
+	// Take the player's state for this frame
+	g.playback = g.player.State()
 
 	// Handle button interactions
 	mouseX, mouseY := ebiten.CursorPosition()
-	g.buttonHovered = mouseX >= config.ButtonX && mouseX <= config.ButtonX+config.ButtonWidth &&
-		mouseY >= config.ButtonY && mouseY <= config.ButtonY+config.ButtonHeight
+	g.buttonHovered = g.layout.button.contains(mouseX, mouseY)
 
 	// Button click detection
 	if g.buttonHovered && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
@@ -85,29 +217,22 @@ func (g *game) Update() error {
 	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
 		if g.buttonPressed && g.buttonHovered {
 			// Button was clicked
-			if err := g.openAndPlayFileDialog(); err != nil {
-				g.lastErr = err
-			}
+			g.report(g.openAndPlayFileDialog())
 		}
 		g.buttonPressed = false
 	}
 
 	// Progress bar interactions
-	barHeight := 30
-	barY := config.WindowHeight - 120
-	barWidth := config.WindowWidth - 40
-	barX := 20
-
-	g.progressBarHovered = mouseX >= barX && mouseX <= barX+barWidth &&
-		mouseY >= barY && mouseY <= barY+barHeight
+	bar := g.layout.progress
+	g.progressBarHovered = bar.contains(mouseX, mouseY)
 
 	// Progress bar click and drag (only if audio is loaded)
-	if g.progressBarHovered && g.streamer != nil && g.audioDuration > 0 {
+	if g.progressBarHovered && g.playback.Loaded && g.playback.Duration > 0 {
 		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
 			g.progressBarDragging = true
-			g.progressBarDragStart = float64(mouseX-barX) / float64(barWidth)
+			g.progressBarDragStart = (float64(mouseX) - bar.X) / bar.W
 			// Only seek on initial click, not on drag start
-			g.seekToPosition(g.progressBarDragStart)
+			g.seekToPercent(g.progressBarDragStart)
 		}
 
 		if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
@@ -118,39 +243,50 @@ func (g *game) Update() error {
 
 		// Handle dragging with debouncing to avoid too many seek calls
 		if g.progressBarDragging {
-			mouseProgress := float64(mouseX-barX) / float64(barWidth)
-			if mouseProgress < 0 {
-				mouseProgress = 0
-			}
-			if mouseProgress > 1 {
-				mouseProgress = 1
-			}
+			mouseProgress := clamp01((float64(mouseX) - bar.X) / bar.W)
 
 			// Only seek if the position changed significantly (avoid micro-seeks)
-			currentProgress := float64(g.audioPosition) / float64(g.audioDuration)
+			currentProgress := float64(g.playback.Position) / float64(g.playback.Duration)
 			if math.Abs(mouseProgress-currentProgress) > 0.01 { // 1% threshold
-				g.seekToPosition(mouseProgress)
+				g.seekToPercent(mouseProgress)
 			}
 		}
 	}
 
-	if justPressed(ebiten.KeySpace) {
-		g.togglePause()
-	}
-	if justPressed(ebiten.KeyEscape) || justPressed(ebiten.KeyQ) {
-		return ebiten.Termination
+	if err := g.handleActions(); err != nil {
+		return err
 	}
 
+	g.pollConfig()
+	g.pollControl()
+	g.pollRecording()
+
 	// Update visualization
-	g.time += 1.0 / 60.0 // Assuming 60 FPS
-	g.rotation += config.RotationSpeed
-	g.colorPhase += config.ColorShiftSpeed
+	tick := g.clock.Tick()
+	g.dt = tick.Seconds()
+	g.pumpAudio(tick)
+	g.playback = g.player.State()
+	g.time += g.dt
+	g.rotation += g.cfg.Visual.RotationSpeed * g.dt
+	g.colorPhase += g.cfg.Visual.ColorShiftSpeed * g.dt
 	g.updateAudioData()
-	g.updateAudioPosition()
+	g.updateScenes()
+	g.publishStatus()
+	g.publishFeed()
+	g.publishLights()
+	g.events.Dispatch()
 
 	return nil
 }
 
+// report shows err in the status line, and passes it on to whoever
+// subscribed to errors. A nil err is ignored.
+func (g *game) report(err error) {
+	if err != nil {
+		g.events.Post(event.Error{Err: err})
+	}
+}
+
 func (g *game) Draw(screen *ebiten.Image) {
 	// Clear background with gradient
 	g.drawBackground(screen)
@@ -158,8 +294,8 @@ func (g *game) Draw(screen *ebiten.Image) {
 	// Draw button
 	g.drawButton(screen)
 
-	// Draw complex visualization
-	g.drawComplexVisualization(screen)
+	// Draw the active scenes
+	g.director.Draw(screen)
 
 	// Draw progress bar
 	g.drawProgressBar(screen)
@@ -169,156 +305,57 @@ func (g *game) Draw(screen *ebiten.Image) {
 
 	// Draw help
 	status := ""
-	if g.ctrl == nil {
+	if !g.playback.Loaded {
 		status = "Click the button above to open an audio file"
-	} else if g.paused {
-		status = "Paused - Space to play, click button to open another"
+	} else if g.playback.Paused {
+		status = "Paused - " + g.keyHint("toggle-pause") + " to play, click button to open another"
 	} else {
-		status = "Playing - Space to pause, click button to open another"
+		status = "Playing - " + g.keyHint("toggle-pause") + " to pause, click button to open another"
 	}
-	if g.lastErr != nil {
-		status += " | Error: " + g.lastErr.Error()
+	if hint := g.keyHint("help"); hint != "" {
+		status += " | " + hint + ": keys"
 	}
-	ebitenutil.DebugPrintAt(screen, status, 12, 12)
-}
-
-func (g *game) drawBackground(screen *ebiten.Image) {
-	// Create a dynamic gradient background
-	for y := 0; y < config.WindowHeight; y++ {
-		ratio := float64(y) / float64(config.WindowHeight)
-		r := uint8(10 + 20*math.Sin(g.time*0.5+ratio*math.Pi))
-		g_val := uint8(12 + 15*math.Cos(g.time*0.3+ratio*math.Pi))
-		b := uint8(20 + 25*math.Sin(g.time*0.7+ratio*math.Pi))
-		ebitenutil.DrawLine(screen, 0, float64(y), float64(config.WindowWidth), float64(y), color.RGBA{R: r, G: g_val, B: b, A: 255})
+	status += " | Scenes: " + g.director.Current().String()
+	status += " | T: " + g.director.Kind.String()
+	if g.director.AutoPlay {
+		status += " | Auto"
 	}
-}
-
-func (g *game) drawComplexVisualization(screen *ebiten.Image) {
-	if len(g.audioData) == 0 {
-		return
+	if g.playback.Opening != "" {
+		status += " | Opening " + g.playback.Opening
 	}
-
-	centerX := float64(config.WindowWidth) / 2
-	centerY := float64(config.WindowHeight) / 2
-
-	// Draw animated circles
-	g.drawAnimatedCircles(screen, centerX, centerY)
-
-	// Draw wave patterns
-	g.drawWavePatterns(screen, centerX, centerY)
-
-	// Draw particle effects
-	g.drawParticleEffects(screen, centerX, centerY)
-
-	// Draw energy rings
-	g.drawEnergyRings(screen, centerX, centerY)
-}
-
-func (g *game) drawAnimatedCircles(screen *ebiten.Image, centerX, centerY float64) {
-	for i := 0; i < config.CircleCount; i++ {
-		angle := float64(i) * (2 * math.Pi / float64(config.CircleCount))
-		radius := 30 + float64(i)*15 + g.audioData[i%len(g.audioData)]*100
-
-		x := centerX + math.Cos(angle+g.rotation)*radius
-		y := centerY + math.Sin(angle+g.rotation)*radius
-
-		// Dynamic color based on audio and time
-		hue := (g.colorPhase + float64(i)*0.1) * 360
-		r, g_val, b := hsvToRgb(hue, 0.8, 0.9)
-
-		// Draw circle with varying opacity
-		opacity := uint8(150 + 105*g.audioData[i%len(g.audioData)])
-		circleColor := color.RGBA{R: r, G: g_val, B: b, A: opacity}
-
-		circleRadius := 8 + g.audioData[i%len(g.audioData)]*20
-		vector.DrawFilledCircle(screen, float32(x), float32(y), float32(circleRadius), circleColor, false)
+	if g.playback.Title != "" {
+		status += " | Now: " + g.playback.Title
 	}
-}
-
-func (g *game) drawWavePatterns(screen *ebiten.Image, centerX, centerY float64) {
-	for i := 0; i < config.WaveCount; i++ {
-		angle := float64(i) * (2 * math.Pi / float64(config.WaveCount))
-		waveRadius := 80 + g.audioData[i%len(g.audioData)]*150
-
-		// Create wave effect
-		for j := 0; j < 360; j += 5 {
-			waveAngle := float64(j) * math.Pi / 180
-			waveOffset := math.Sin(waveAngle*3+g.time*2) * 10
-			waveRadiusOffset := waveRadius + waveOffset + g.audioData[i%len(g.audioData)]*50
-
-			x1 := centerX + math.Cos(angle)*waveRadiusOffset
-			y1 := centerY + math.Sin(angle)*waveRadiusOffset
-
-			nextAngle := float64(j+5) * math.Pi / 180
-			nextOffset := math.Sin(nextAngle*3+g.time*2) * 10
-			nextRadiusOffset := waveRadius + nextOffset + g.audioData[i%len(g.audioData)]*50
-
-			x2 := centerX + math.Cos(angle)*nextRadiusOffset
-			y2 := centerY + math.Sin(angle)*nextRadiusOffset
-
-			// Color based on wave position and audio
-			hue := (g.colorPhase + float64(i)*0.05 + float64(j)*0.01) * 360
-			r, g_val, b := hsvToRgb(hue, 0.7, 0.8)
-
-			opacity := uint8(100 + 155*g.audioData[i%len(g.audioData)])
-			waveColor := color.RGBA{R: r, G: g_val, B: b, A: opacity}
-
-			vector.StrokeLine(screen, float32(x1), float32(y1), float32(x2), float32(y2), 2, waveColor, false)
-		}
+	if bpm := g.beats.BPM(); bpm > 0 {
+		status += fmt.Sprintf(" | %.0f BPM", bpm)
 	}
-}
-
-func (g *game) drawParticleEffects(screen *ebiten.Image, centerX, centerY float64) {
-	for i := 0; i < config.ParticleCount; i++ {
-		// Particle position based on audio and time
-		angle := g.time*0.5 + float64(i)*0.1
-		radius := 20 + g.audioData[i%len(g.audioData)]*300
-
-		x := centerX + math.Cos(angle)*radius
-		y := centerY + math.Sin(angle)*radius
+	if g.lastErr != nil {
+		status += " | Error: " + g.lastErr.Error()
+	}
+	if g.configErr != nil {
+		status += " | Config: " + strings.ReplaceAll(g.configErr.Error(), "\n", "; ")
+	}
+	visual.PrintAt(screen, status, int(g.layout.status.X), int(g.layout.status.Y), g.layout.scale)
 
-		// Particle size and color
-		size := 2 + g.audioData[i%len(g.audioData)]*8
-		hue := (g.colorPhase + float64(i)*0.02) * 360
-		r, g_val, b := hsvToRgb(hue, 1.0, 1.0)
+	// Capture the frame without the indicators drawn on top
+	g.captureFrame(screen)
 
-		opacity := uint8(200 + 55*g.audioData[i%len(g.audioData)])
-		particleColor := color.RGBA{R: r, G: g_val, B: b, A: opacity}
+	// Draw recording indicators
+	g.drawRecordingIndicators(screen)
 
-		vector.DrawFilledCircle(screen, float32(x), float32(y), float32(size), particleColor, false)
+	if g.showHelp {
+		g.drawHelp(screen)
 	}
 }
 
-func (g *game) drawEnergyRings(screen *ebiten.Image, centerX, centerY float64) {
-	for i := 0; i < 5; i++ {
-		ringRadius := float64(40+i*30) + g.audioData[i%len(g.audioData)]*100
-
-		// Draw ring segments
-		segments := 24
-		for j := 0; j < segments; j++ {
-			startAngle := float64(j) * (2 * math.Pi / float64(segments))
-			endAngle := float64(j+1) * (2 * math.Pi / float64(segments))
-
-			// Skip some segments based on audio intensity
-			if g.audioData[i%len(g.audioData)] < 0.1 {
-				continue
-			}
-
-			x1 := centerX + math.Cos(startAngle)*ringRadius
-			y1 := centerY + math.Sin(startAngle)*ringRadius
-			x2 := centerX + math.Cos(endAngle)*ringRadius
-			y2 := centerY + math.Sin(endAngle)*ringRadius
-
-			// Color based on ring and segment
-			hue := (g.colorPhase + float64(i)*0.2 + float64(j)*0.1) * 360
-			r, g_val, b := hsvToRgb(hue, 0.9, 0.8)
-
-			opacity := uint8(120 + 135*g.audioData[i%len(g.audioData)])
-			ringColor := color.RGBA{R: r, G: g_val, B: b, A: opacity}
-
-			strokeWidth := 3 + g.audioData[i%len(g.audioData)]*8
-			vector.StrokeLine(screen, float32(x1), float32(y1), float32(x2), float32(y2), float32(strokeWidth), ringColor, false)
-		}
+func (g *game) drawBackground(screen *ebiten.Image) {
+	// Create a dynamic gradient background
+	for y := 0; y < g.layout.height; y++ {
+		ratio := float64(y) / float64(g.layout.height)
+		r := uint8(10 + 20*math.Sin(g.time*0.5+ratio*math.Pi))
+		g_val := uint8(12 + 15*math.Cos(g.time*0.3+ratio*math.Pi))
+		b := uint8(20 + 25*math.Sin(g.time*0.7+ratio*math.Pi))
+		vector.StrokeLine(screen, 0, float32(y)+0.5, float32(g.layout.width), float32(y)+0.5, 1, color.RGBA{R: r, G: g_val, B: b, A: 255}, false)
 	}
 }
 
@@ -328,15 +365,12 @@ func (g *game) drawAudioBar(screen *ebiten.Image) {
 	}
 
 	// Audio bar parameters
-	barHeight := 60
-	barY := config.WindowHeight - barHeight - 20
-	barWidth := config.WindowWidth - 40
-	barX := 20
-	segmentWidth := float64(barWidth) / 64.0 // 64 frequency bands
+	bar := g.layout.audioBar
+	segmentWidth := bar.W / 64.0 // 64 frequency bands
 
 	// Draw background for audio bar
-	vector.DrawFilledRect(screen, float32(barX), float32(barY), float32(barWidth), float32(barHeight), color.RGBA{R: 20, G: 25, B: 35, A: 200}, false)
-	vector.StrokeRect(screen, float32(barX), float32(barY), float32(barWidth), float32(barHeight), 2, color.RGBA{R: 60, G: 70, B: 90, A: 255}, false)
+	vector.DrawFilledRect(screen, float32(bar.X), float32(bar.Y), float32(bar.W), float32(bar.H), color.RGBA{R: 20, G: 25, B: 35, A: 200}, false)
+	vector.StrokeRect(screen, float32(bar.X), float32(bar.Y), float32(bar.W), float32(bar.H), float32(g.layout.px(2)), color.RGBA{R: 60, G: 70, B: 90, A: 255}, false)
 
 	// Draw frequency segments
 	for i := 0; i < 64; i++ {
@@ -345,41 +379,42 @@ func (g *game) drawAudioBar(screen *ebiten.Image) {
 		}
 
 		// Calculate segment position and height
-		segmentX := float64(barX) + float64(i)*segmentWidth
-		segmentHeight := g.audioData[i] * float64(barHeight-10)
+		segmentX := bar.X + float64(i)*segmentWidth
+		segmentHeight := g.audioData[i] * (bar.H - g.layout.px(10))
 
 		// Ensure minimum height for visibility
-		if segmentHeight < 2 {
-			segmentHeight = 2
+		if segmentHeight < g.layout.px(2) {
+			segmentHeight = g.layout.px(2)
 		}
 
 		// Color based on frequency and intensity
 		freqRatio := float64(i) / 64.0
 		hue := (g.colorPhase + freqRatio*180) * 360
-		r, g_val, b := hsvToRgb(hue, 0.8, 0.9)
+		r, g_val, b := visual.HSV(&g.cfg.Visual, hue, 0.8, 0.9)
 
 		// Opacity based on audio intensity
 		opacity := uint8(100 + 155*g.audioData[i])
 		segmentColor := color.RGBA{R: r, G: g_val, B: b, A: opacity}
 
 		// Draw segment
-		segmentY := float64(barY) + float64(barHeight) - segmentHeight
-		vector.DrawFilledRect(screen, float32(segmentX), float32(segmentY), float32(segmentWidth-1), float32(segmentHeight), segmentColor, false)
+		segmentY := bar.bottom() - segmentHeight
+		vector.DrawFilledRect(screen, float32(segmentX), float32(segmentY), float32(segmentWidth-g.layout.px(1)), float32(segmentHeight), segmentColor, false)
 
 		// Add highlight effect for stronger frequencies
 		if g.audioData[i] > 0.3 {
 			highlightColor := color.RGBA{R: 255, G: 255, B: 255, A: uint8(100 * g.audioData[i])}
-			vector.StrokeRect(screen, float32(segmentX), float32(segmentY), float32(segmentWidth-1), float32(segmentHeight), 1, highlightColor, false)
+			vector.StrokeRect(screen, float32(segmentX), float32(segmentY), float32(segmentWidth-g.layout.px(1)), float32(segmentHeight), float32(g.layout.px(1)), highlightColor, false)
 		}
 	}
 
 	// Draw center line indicator
-	centerY := float64(barY) + float64(barHeight)/2
-	vector.StrokeLine(screen, float32(barX), float32(centerY), float32(barX+barWidth), float32(centerY), 1, color.RGBA{R: 100, G: 110, B: 130, A: 100}, false)
+	centerY := bar.Y + bar.H/2
+	vector.StrokeLine(screen, float32(bar.X), float32(centerY), float32(bar.X+bar.W), float32(centerY), float32(g.layout.px(1)), color.RGBA{R: 100, G: 110, B: 130, A: 100}, false)
 
 	// Draw frequency labels
-	ebitenutil.DebugPrintAt(screen, "Low", int(barX), int(barY-15))
-	ebitenutil.DebugPrintAt(screen, "High", int(barX+barWidth-25), int(barY-15))
+	labelY := int(bar.Y - g.layout.px(15))
+	visual.PrintAt(screen, "Low", int(bar.X), labelY, g.layout.scale)
+	visual.PrintAt(screen, "High", int(bar.X+bar.W)-visual.TextWidth("High", g.layout.scale), labelY, g.layout.scale)
 }
 
 func (g *game) drawButton(screen *ebiten.Image) {
@@ -394,44 +429,69 @@ func (g *game) drawButton(screen *ebiten.Image) {
 	}
 
 	// Draw filled rectangle background
-	vector.DrawFilledRect(screen, float32(config.ButtonX), float32(config.ButtonY), float32(config.ButtonWidth), float32(config.ButtonHeight), bgColor, false)
+	button := g.layout.button
+	vector.DrawFilledRect(screen, float32(button.X), float32(button.Y), float32(button.W), float32(button.H), bgColor, false)
 
 	// Button border
 	borderColor := color.RGBA{R: 150, G: 170, B: 200, A: 255}
-	vector.StrokeRect(screen, float32(config.ButtonX), float32(config.ButtonY), float32(config.ButtonWidth), float32(config.ButtonHeight), 2, borderColor, false)
+	vector.StrokeRect(screen, float32(button.X), float32(button.Y), float32(button.W), float32(button.H), float32(g.layout.px(2)), borderColor, false)
 
 	// Button text
 	text := "Open File"
-	textWidth := len(text) * 8 // Approximate character width
-	textX := config.ButtonX + (config.ButtonWidth-textWidth)/2
-	textY := config.ButtonY + (config.ButtonHeight+8)/2
-	ebitenutil.DebugPrintAt(screen, text, textX, textY)
+	textWidth := visual.TextWidth(text, g.layout.scale)
+	textX := int(button.X) + (int(button.W)-textWidth)/2
+	textY := int(button.Y + (button.H-g.layout.px(16))/2)
+	visual.PrintAt(screen, text, textX, textY, g.layout.scale)
 }
 
+// Layout renders at the full device resolution: the outside size is in
+// device-independent pixels, so it is multiplied by the monitor's scale
+// factor and the UI is laid out again whenever the result changes.
 func (g *game) Layout(outsideWidth, outsideHeight int) (int, int) {
-	return config.WindowWidth, config.WindowHeight
+	scale := ebiten.Monitor().DeviceScaleFactor()
+	width := int(math.Ceil(float64(outsideWidth) * scale))
+	height := int(math.Ceil(float64(outsideHeight) * scale))
+	if width != g.layout.width || height != g.layout.height || scale != g.layout.scale {
+		g.layout = newLayout(width, height, scale)
+		g.director.Resize(width, height)
+	}
+	return width, height
 }
 
-func (g *game) togglePause() {
-	if g.ctrl == nil {
-		return
-	}
-	speaker.Lock()
-	g.paused = !g.paused
-	g.ctrl.Paused = g.paused
-	speaker.Unlock()
+func (g *game) togglePause() error {
+	return g.do(player.Command{Kind: player.Toggle})
 }
 
 func (g *game) updateAudioData() {
-	if g.tap == nil {
-		return
-	}
-
+	g.beat = false
+	g.rms = 0
 	// Get audio samples
-	samples := g.tap.snapshot(2048)
+	samples := g.player.Samples(2048)
 	if len(samples) == 0 {
 		return
 	}
+	g.stereo = samples
+
+	// Beat detection on the newest samples
+	recent := samples
+	if len(recent) > 1024 {
+		recent = recent[len(recent)-1024:]
+	}
+	var energy float64
+	for _, smp := range recent {
+		mono := (smp[0] + smp[1]) * 0.5
+		energy += mono * mono
+	}
+	energy /= float64(len(recent))
+	g.rms = math.Sqrt(energy)
+	g.beat = g.beats.Process(energy, g.time)
+	if g.beat {
+		g.events.Post(event.Beat{Time: g.time, BPM: g.beats.BPM(), RMS: g.rms})
+	}
+
+	// Stereo correlation for the scope modes
+	corr := stereoCorrelation(samples)
+	g.correlation += smoothingAlpha(g.dt, g.cfg.Audio.CorrelationSmoothTime) * (corr - g.correlation)
 
 	// Process audio data into frequency bands
 	nBands := 64
@@ -439,6 +499,7 @@ func (g *game) updateAudioData() {
 		g.audioData = make([]float64, nBands)
 	}
 
+	alpha := smoothingAlpha(g.dt, g.cfg.Audio.SmoothingTime)
 	segmentSize := int(math.Max(1, float64(len(samples))/float64(nBands)))
 	for i := 0; i < nBands; i++ {
 		start := i * segmentSize
@@ -460,81 +521,7 @@ func (g *game) updateAudioData() {
 		mag := math.Pow(rms, 0.3) // More aggressive compression for visual effect
 
 		// Smooth with previous value
-		g.audioData[i] = config.SmoothingFactor*g.audioData[i] + (1-config.SmoothingFactor)*mag
-	}
-}
-
-func (g *game) updateAudioPosition() {
-	if g.streamer == nil || g.paused {
-		return
-	}
-
-	// Simple time-based position tracking
-	g.audioPosition += time.Second / 60 // Assuming 60 FPS
-	if g.audioPosition > g.audioDuration {
-		g.audioPosition = g.audioDuration
-	}
-}
-
-func (g *game) seekToPosition(pos float64) {
-	if g.streamer == nil {
-		return
-	}
-
-	// Add cooldown to prevent too frequent seeking
-	if time.Since(g.lastSeekTime) < 50*time.Millisecond {
-		return
-	}
-
-	// Clamp position to valid range
-	if pos < 0 {
-		pos = 0
-	}
-	if pos > 1 {
-		pos = 1
-	}
-
-	// Calculate seek position in samples
-	seekPos := int(pos * float64(g.audioDuration) * float64(g.format.SampleRate) / float64(time.Second))
-
-	// Ensure seek position is within valid bounds
-	if seekPos < 0 {
-		seekPos = 0
-	}
-
-	// Get the maximum valid position from the streamer
-	maxPos := g.streamer.Len()
-	if seekPos >= maxPos {
-		seekPos = maxPos - 1
-	}
-
-	// Perform the seek
-	err := g.streamer.Seek(seekPos)
-	if err != nil {
-		g.lastErr = err
-		return
-	}
-
-	// Update the audio position and seek time
-	g.audioPosition = time.Duration(seekPos) * time.Second / time.Duration(g.format.SampleRate.N(time.Second))
-	g.lastSeekTime = time.Now()
-}
-
-func (g *game) stopCurrent() {
-	fmt.Println("stopCurrent 1")
-	speaker.Lock()
-	fmt.Println("stopCurrent 2")
-	speaker.Clear()
-	fmt.Println("stopCurrent 3")
-	speaker.Unlock()
-	fmt.Println("stopCurrent 4")
-	if g.streamer != nil {
-		_ = g.streamer.Close()
-		g.streamer = nil
-	}
-	if g.currentFile != nil {
-		_ = g.currentFile.Close()
-		g.currentFile = nil
+		g.audioData[i] += alpha * (mag - g.audioData[i])
 	}
 }
 
@@ -554,174 +541,176 @@ func (g *game) openAndPlayFileDialog() error {
 	}
 
 	fmt.Printf("Succefully choosed file %v\n", filename)
+	g.queue.Replace(filename)
 	return g.loadAndPlay(filename)
 }
 
+// loadAndPlay has the player open path in the background; the current
+// track plays on until it is ready.
 func (g *game) loadAndPlay(path string) error {
-	// Stop and close previous if any
-	// g.stopCurrent()
+	g.skip = nil
+	g.loading = path
+	return g.do(g.openCommand(path))
+}
 
-	f, err := os.Open(path)
-	if err != nil {
-		return err
-	}
+// playSource makes src, an input that is already open, the playing track.
+func (g *game) playSource(src audio.Source, track string) error {
+	cmd := g.openCommand(track)
+	cmd.Source = src
+	return g.do(cmd)
+}
 
-	// Decode based on extension
-	ext := filepath.Ext(path)
-	var (
-		streamer beep.StreamSeekCloser
-		format   beep.Format
-	)
-	switch ext {
-	case ".wav", ".WAV":
-		streamer, format, err = wav.Decode(f)
-	case ".mp3", ".MP3":
-		streamer, format, err = mp3.Decode(f)
-	case ".flac", ".FLAC":
-		streamer, format, err = flac.Decode(f)
-	default:
-		_ = f.Close()
-		return errors.New("unsupported file type: " + ext)
+// openCommand opens track with the current settings. Live sources are only
+// visualized unless input.monitor is set, so a microphone does not feed
+// back.
+func (g *game) openCommand(track string) player.Command {
+	return player.Command{
+		Kind:     player.Open,
+		Track:    track,
+		RingSize: g.cfg.Audio.VisualRingSize,
+		Monitor:  g.cfg.Input.Monitor,
+		Waveform: waveformColumns,
 	}
-	if err != nil {
-		_ = f.Close()
-		return err
-	}
-
-	fmt.Printf("Succefully loaded file %v\n", path)
+}
 
-	// Prepare audio chain: streamer -> tap -> ctrl
-	t := newVisualTap(streamer, config.VisualRingSize)
-	ctrl := &beep.Ctrl{Streamer: t, Paused: false}
+// drawLiveIndicator takes the place of the progress bar for live input,
+// which has no length to show progress against.
+func (g *game) drawLiveIndicator(screen *ebiten.Image) {
+	bar := g.layout.progress
+	scale := g.layout.scale
 
-	// (Re)initialize speaker if needed
-	bufferSize := format.SampleRate.N(time.Second / 20)
-	if !g.initDone {
-		if err := speaker.Init(format.SampleRate, bufferSize); err != nil {
-			_ = streamer.Close()
-			_ = f.Close()
-			return err
-		}
-		g.initDone = true
-	} else if g.format.SampleRate != format.SampleRate {
-		// Re-init when sample rate changes
-		speaker.Lock()
-		speaker.Clear()
-		if err := speaker.Init(format.SampleRate, bufferSize); err != nil {
-			speaker.Unlock()
-			_ = streamer.Close()
-			_ = f.Close()
-			return err
-		}
-		speaker.Unlock()
-	} else {
-		// Stop any previous playback
-		speaker.Lock()
-		speaker.Clear()
-		speaker.Unlock()
-	}
-
-	g.currentFile = f
-	g.streamer = streamer
-	g.format = format
-	g.ctrl = ctrl
-	g.tap = t
-	g.paused = false
-
-	// Initialize progress bar
-	g.audioDuration = time.Duration(streamer.Len()) * time.Second / time.Duration(format.SampleRate.N(time.Second))
-	g.audioPosition = 0
-
-	// Start playing
-	speaker.Play(beep.Seq(ctrl, beep.Callback(func() {
-		// On end: close resources
-		_ = streamer.Close()
-		_ = f.Close()
-		g.streamer = nil
-		g.currentFile = nil
-		g.audioDuration = 0
-		g.audioPosition = 0
-	})))
+	// Draw background
+	vector.DrawFilledRect(screen, float32(bar.X), float32(bar.Y), float32(bar.W), float32(bar.H), color.RGBA{R: 25, G: 30, B: 40, A: 200}, false)
+	vector.StrokeRect(screen, float32(bar.X), float32(bar.Y), float32(bar.W), float32(bar.H), float32(g.layout.px(2)), color.RGBA{R: 70, G: 80, B: 100, A: 255}, false)
+
+	// Draw the current level as the fill
+	level := 0.0
+	for _, v := range g.audioData {
+		level = math.Max(level, v)
+	}
+	level = clamp01(level)
+	if level > 0 {
+		hue := (g.colorPhase + level*180) * 360
+		r, g_val, b := visual.HSV(&g.cfg.Visual, hue, 0.8, 0.9)
+		vector.DrawFilledRect(screen, float32(bar.X), float32(bar.Y), float32(level*bar.W), float32(bar.H), color.RGBA{R: r, G: g_val, B: b, A: 180}, false)
+	}
+
+	// Draw the live label with a dot pulsing with the level, and the time
+	// since the input was opened
+	labelY := int(bar.bottom() + g.layout.px(5))
+	radius := g.layout.px(4) * (1 + 0.5*level)
+	alpha := uint8(140 + 115*level)
+	if g.playback.Paused {
+		alpha = 80
+	}
+	vector.DrawFilledCircle(screen, float32(bar.X+g.layout.px(5)), float32(float64(labelY)+g.layout.px(8)), float32(radius), color.RGBA{R: 230, G: 40, B: 40, A: alpha}, true)
+	visual.PrintAt(screen, "LIVE", int(bar.X+g.layout.px(14)), labelY, scale)
+	elapsed := formatDuration(g.playback.Position)
+	visual.PrintAt(screen, elapsed, int(bar.X+bar.W)-visual.TextWidth(elapsed, scale), labelY, scale)
+}
 
-	return nil
+func (g *game) drawWaveform(screen *ebiten.Image, bar rect) {
+	peaks := g.playback.Peaks
+	if len(peaks) == 0 {
+		return
+	}
+	colWidth := bar.W / float64(len(peaks))
+	centerY := bar.Y + bar.H/2
+	waveColor := color.RGBA{R: 255, G: 255, B: 255, A: 70}
+	for i, p := range peaks {
+		amplitude := math.Min(1, math.Max(math.Abs(p.Min), math.Abs(p.Max)))
+		h := math.Max(amplitude*(bar.H-g.layout.px(4)), 1)
+		x := bar.X + float64(i)*colWidth
+		vector.DrawFilledRect(screen, float32(x), float32(centerY-h/2), float32(math.Max(colWidth, 1)), float32(h), waveColor, false)
+	}
 }
 
 func (g *game) drawProgressBar(screen *ebiten.Image) {
-	if g.streamer == nil || g.audioDuration == 0 {
+	if g.playback.Loaded && g.playback.Live {
+		g.drawLiveIndicator(screen)
+		return
+	}
+	if !g.playback.Loaded || g.playback.Duration == 0 {
 		return
 	}
 
 	// Progress bar parameters
-	barHeight := 30
-	barY := config.WindowHeight - 120 // Above the audio bar
-	barWidth := config.WindowWidth - 40
-	barX := 20
+	bar := g.layout.progress
+	scale := g.layout.scale
 
 	// Calculate progress
 	progress := 0.0
-	if g.audioDuration > 0 {
-		progress = float64(g.audioPosition) / float64(g.audioDuration)
+	if g.playback.Duration > 0 {
+		progress = float64(g.playback.Position) / float64(g.playback.Duration)
 	}
 
 	// Draw background
-	vector.DrawFilledRect(screen, float32(barX), float32(barY), float32(barWidth), float32(barHeight), color.RGBA{R: 25, G: 30, B: 40, A: 200}, false)
-	vector.StrokeRect(screen, float32(barX), float32(barY), float32(barWidth), float32(barHeight), 2, color.RGBA{R: 70, G: 80, B: 100, A: 255}, false)
+	vector.DrawFilledRect(screen, float32(bar.X), float32(bar.Y), float32(bar.W), float32(bar.H), color.RGBA{R: 25, G: 30, B: 40, A: 200}, false)
+	vector.StrokeRect(screen, float32(bar.X), float32(bar.Y), float32(bar.W), float32(bar.H), float32(g.layout.px(2)), color.RGBA{R: 70, G: 80, B: 100, A: 255}, false)
 
 	// Draw progress fill
 	if progress > 0 {
-		fillWidth := progress * float64(barWidth)
+		fillWidth := progress * bar.W
 		// Gradient color based on progress
 		hue := (g.colorPhase + progress*180) * 360
-		r, g_val, b := hsvToRgb(hue, 0.8, 0.9)
+		r, g_val, b := visual.HSV(&g.cfg.Visual, hue, 0.8, 0.9)
 		progressColor := color.RGBA{R: r, G: g_val, B: b, A: 180}
 
-		vector.DrawFilledRect(screen, float32(barX), float32(barY), float32(fillWidth), float32(barHeight), progressColor, false)
+		vector.DrawFilledRect(screen, float32(bar.X), float32(bar.Y), float32(fillWidth), float32(bar.H), progressColor, false)
 	}
 
+	// Draw waveform overview
+	g.drawWaveform(screen, bar)
+
 	// Draw progress indicator (current position)
-	indicatorX := float64(barX) + progress*float64(barWidth)
+	indicatorX := bar.X + progress*bar.W
+	indicatorY := bar.Y + bar.H/2
+	indicatorRadius := float32(g.layout.px(8))
 	indicatorColor := color.RGBA{R: 255, G: 255, B: 255, A: 255}
-	vector.DrawFilledCircle(screen, float32(indicatorX), float32(barY+barHeight/2), 8, indicatorColor, false)
-	vector.StrokeCircle(screen, float32(indicatorX), float32(barY+barHeight/2), 8, 2, color.RGBA{R: 100, G: 110, B: 130, A: 255}, false)
+	vector.DrawFilledCircle(screen, float32(indicatorX), float32(indicatorY), indicatorRadius, indicatorColor, true)
+	vector.StrokeCircle(screen, float32(indicatorX), float32(indicatorY), indicatorRadius, float32(g.layout.px(2)), color.RGBA{R: 100, G: 110, B: 130, A: 255}, true)
 
 	// Draw time labels
-	currentTime := formatDuration(g.audioPosition)
-	totalTime := formatDuration(g.audioDuration)
+	currentTime := formatDuration(g.playback.Position)
+	totalTime := formatDuration(g.playback.Duration)
+	labelY := int(bar.bottom() + g.layout.px(5))
 
 	// Current time (left)
-	ebitenutil.DebugPrintAt(screen, currentTime, int(barX), int(barY+barHeight+5))
+	visual.PrintAt(screen, currentTime, int(bar.X), labelY, scale)
 
 	// Total time (right)
-	totalTimeWidth := len(totalTime) * 8
-	ebitenutil.DebugPrintAt(screen, totalTime, int(barX+barWidth-totalTimeWidth), int(barY+barHeight+5))
+	totalTimeWidth := visual.TextWidth(totalTime, scale)
+	visual.PrintAt(screen, totalTime, int(bar.X+bar.W)-totalTimeWidth, labelY, scale)
 
 	// Draw hover effect
 	if g.progressBarHovered {
 		// Show time tooltip at mouse position
 		mouseX, mouseY := ebiten.CursorPosition()
-		if mouseX >= barX && mouseX <= barX+barWidth && mouseY >= barY && mouseY <= barY+barHeight {
+		if bar.contains(mouseX, mouseY) {
 			// Calculate time at mouse position
-			mouseProgress := float64(mouseX-barX) / float64(barWidth)
-			mouseTime := time.Duration(mouseProgress * float64(g.audioDuration))
+			mouseProgress := clamp01((float64(mouseX) - bar.X) / bar.W)
+			mouseTime := time.Duration(mouseProgress * float64(g.playback.Duration))
 			tooltipTime := formatDuration(mouseTime)
 
 			// Draw tooltip background
-			tooltipWidth := len(tooltipTime)*8 + 10
+			padding := int(g.layout.px(5))
+			tooltipWidth := visual.TextWidth(tooltipTime, scale) + 2*padding
+			tooltipHeight := int(g.layout.px(20))
 			tooltipX := mouseX - tooltipWidth/2
-			tooltipY := mouseY - 25
+			tooltipY := mouseY - int(g.layout.px(25))
 
 			if tooltipX < 0 {
 				tooltipX = 0
 			}
-			if tooltipX+tooltipWidth > config.WindowWidth {
-				tooltipX = config.WindowWidth - tooltipWidth
+			if tooltipX+tooltipWidth > g.layout.width {
+				tooltipX = g.layout.width - tooltipWidth
 			}
 
-			vector.DrawFilledRect(screen, float32(tooltipX), float32(tooltipY), float32(tooltipWidth), 20, color.RGBA{R: 0, G: 0, B: 0, A: 200}, false)
-			vector.StrokeRect(screen, float32(tooltipX), float32(tooltipY), float32(tooltipWidth), 20, 1, color.RGBA{R: 100, G: 110, B: 130, A: 255}, false)
+			vector.DrawFilledRect(screen, float32(tooltipX), float32(tooltipY), float32(tooltipWidth), float32(tooltipHeight), color.RGBA{R: 0, G: 0, B: 0, A: 200}, false)
+			vector.StrokeRect(screen, float32(tooltipX), float32(tooltipY), float32(tooltipWidth), float32(tooltipHeight), float32(g.layout.px(1)), color.RGBA{R: 100, G: 110, B: 130, A: 255}, false)
 
 			// Draw tooltip text
-			ebitenutil.DebugPrintAt(screen, tooltipTime, tooltipX+5, tooltipY+5)
+			visual.PrintAt(screen, tooltipTime, tooltipX+padding, tooltipY+int(g.layout.px(2)), scale)
 		}
 	}
 }
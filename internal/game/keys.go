@@ -0,0 +1,255 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+
+	"github.com/iburimskiy/audio-visualization/internal/input"
+	"github.com/iburimskiy/audio-visualization/internal/visual"
+)
+
+// action is something the user can trigger with a key, a mouse button or a
+// gamepad button. The config file can rebind it by name.
+type action struct {
+	name string
+	help string
+	keys []string // default bindings
+	run  func(g *game) error
+}
+
+// actions lists every action in the order the help overlay shows them.
+var actions = buildActions()
+
+func buildActions() []action {
+	a := []action{
+		{"toggle-pause", "Play/pause", []string{"Space", "PadA"}, func(g *game) error {
+			if !g.playback.Loaded {
+				return g.playCurrent()
+			}
+			return g.togglePause()
+		}},
+		{"open-file", "Open a file", []string{"O"}, (*game).openAndPlayFileDialog},
+		{"seek-forward-5s", "Seek forward 5 s", []string{"Right", "PadRight"}, func(g *game) error {
+			return g.seekBy(5 * time.Second)
+		}},
+		{"seek-back-5s", "Seek back 5 s", []string{"Left", "PadLeft"}, func(g *game) error {
+			return g.seekBy(-5 * time.Second)
+		}},
+		{"seek-forward-30s", "Seek forward 30 s", []string{"Shift+Right", "PadRT"}, func(g *game) error {
+			return g.seekBy(30 * time.Second)
+		}},
+		{"seek-back-30s", "Seek back 30 s", []string{"Shift+Left", "PadLT"}, func(g *game) error {
+			return g.seekBy(-30 * time.Second)
+		}},
+		{"nudge-forward", "Step forward one frame", []string{"Period"}, func(g *game) error {
+			return g.seekBy(frameDuration())
+		}},
+		{"nudge-back", "Step back one frame", []string{"Comma"}, func(g *game) error {
+			return g.seekBy(-frameDuration())
+		}},
+		{"next-track", "Next track", []string{"PageDown", "PadRB"}, func(g *game) error {
+			path, ok := g.queue.Next()
+			g.skipTo(path, ok, g.queue.Next)
+			return nil
+		}},
+		{"previous-track", "Previous track", []string{"PageUp", "PadLB"}, func(g *game) error {
+			path, ok := g.queue.Previous()
+			g.skipTo(path, ok, g.queue.Previous)
+			return nil
+		}},
+		{"volume-up", "Volume up 10%", []string{"Up", "PadUp"}, func(g *game) error {
+			return g.setGain(min(g.playback.Gain+0.1, 2))
+		}},
+		{"volume-down", "Volume down 10%", []string{"Down", "PadDown"}, func(g *game) error {
+			return g.setGain(max(g.playback.Gain-0.1, 0))
+		}},
+	}
+
+	// The keypad and Alt+0-9 jump to 0-90% of the track
+	for i := 0; i < 10; i++ {
+		a = append(a, action{fmt.Sprintf("seek-%d-percent", i*10), fmt.Sprintf("Jump to %d%%", i*10), []string{fmt.Sprintf("Numpad%d", i), fmt.Sprintf("Alt+%d", i)}, func(g *game) error {
+			return g.seekToPercent(float64(i) / 10)
+		}})
+	}
+
+	// 1-9 show a single scene and Shift+1-9 toggle it as an overlay layer
+	names := visual.Scenes()
+	for i := 0; i < 9; i++ {
+		name := fmt.Sprintf("scene %d", i+1)
+		if i < len(names) {
+			name = names[i]
+		}
+		a = append(a, action{fmt.Sprintf("scene-%d", i+1), "Show " + name, []string{fmt.Sprint(i + 1)}, func(g *game) error {
+			return g.director.SwitchTo(name)
+		}})
+	}
+	for i := 0; i < 9; i++ {
+		name := fmt.Sprintf("scene %d", i+1)
+		if i < len(names) {
+			name = names[i]
+		}
+		a = append(a, action{fmt.Sprintf("overlay-%d", i+1), "Toggle " + name + " as an overlay", []string{fmt.Sprintf("Shift+%d", i+1)}, func(g *game) error {
+			return g.director.Current().Toggle(name)
+		}})
+	}
+
+	return append(a, []action{
+		{"default-scenes", "Restore the default scenes", []string{"0"}, func(g *game) error {
+			return g.director.SwitchTo(g.cfg.Scenes.Default...)
+		}},
+		{"cycle-blend", "Cycle the blend mode of the top layer", []string{"B"}, func(g *game) error {
+			g.director.Current().CycleBlend()
+			return nil
+		}},
+		{"cycle-transition", "Cycle the transition kind", []string{"T"}, func(g *game) error {
+			g.director.Kind = (g.director.Kind + 1) % visual.TransitionKindCount
+			return nil
+		}},
+		{"toggle-playlist", "Toggle the scene playlist", []string{"P"}, func(g *game) error {
+			g.director.AutoPlay = !g.director.AutoPlay
+			return nil
+		}},
+		{"next-scene", "Next playlist entry", []string{"N", "PadY"}, func(g *game) error {
+			return g.director.Next()
+		}},
+		{"record", "Start/stop recording", []string{"R"}, (*game).toggleRecording},
+		{"capture", "Start/stop video capture", []string{"V"}, (*game).toggleCapture},
+		{"screenshot", "Save a screenshot", []string{"F12"}, func(g *game) error {
+			g.screenshotPending = true
+			return nil
+		}},
+		{"fullscreen", "Toggle fullscreen", []string{"F11"}, func(g *game) error {
+			ebiten.SetFullscreen(!ebiten.IsFullscreen())
+			return nil
+		}},
+		{"help", "Show/hide this help", []string{"F1", "?", "PadBack"}, func(g *game) error {
+			g.showHelp = !g.showHelp
+			return nil
+		}},
+		{"quit", "Quit (closes the help first)", []string{"Escape", "Q"}, func(g *game) error {
+			if g.showHelp {
+				g.showHelp = false
+				return nil
+			}
+			return ebiten.Termination
+		}},
+	}...)
+}
+
+// frameDuration is the time one update of the visuals covers, the step of
+// the nudge actions.
+func frameDuration() time.Duration {
+	tps := ebiten.TPS()
+	if tps <= 0 {
+		// Updates follow the display's refresh rate
+		tps = ebiten.DefaultTPS
+	}
+	return time.Second / time.Duration(tps)
+}
+
+// applyKeyConfig builds the keymap from the default bindings and the
+// overrides in the config. An action listed in the config loses its default
+// bindings; an empty list unbinds it.
+func (g *game) applyKeyConfig() error {
+	names := make([]string, len(actions))
+	bindings := make(map[string][]string, len(actions))
+	for i, a := range actions {
+		names[i] = a.name
+		bindings[a.name] = a.keys
+	}
+	var problems []error
+	for _, name := range slices.Sorted(maps.Keys(g.cfg.Keys)) {
+		if _, ok := bindings[name]; !ok {
+			problems = append(problems, fmt.Errorf("keys: unknown action %q", name))
+			continue
+		}
+		bindings[name] = g.cfg.Keys[name]
+	}
+	keymap, err := input.New(names, bindings)
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			problems = append(problems, fmt.Errorf("keys.%w", err))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.Join(problems...)
+	}
+	g.keymap = keymap
+	return nil
+}
+
+// handleActions runs the actions whose bindings were pressed this frame.
+// Errors end up in the status line; quitting is passed on to ebiten.
+func (g *game) handleActions() error {
+	g.pressed = g.keymap.JustPressed(g.pressed[:0])
+	for _, name := range g.pressed {
+		for _, a := range actions {
+			if a.name != name {
+				continue
+			}
+			err := a.run(g)
+			if errors.Is(err, ebiten.Termination) {
+				return err
+			}
+			g.report(err)
+		}
+	}
+	return nil
+}
+
+// keyHint returns the first binding of an action for hints, or "" if it is
+// unbound.
+func (g *game) keyHint(name string) string {
+	if b := g.keymap.Bindings(name); len(b) > 0 {
+		return b[0].String()
+	}
+	return ""
+}
+
+// drawHelp lists the active bindings over a dimmed screen, in as many
+// columns as it takes.
+func (g *game) drawHelp(screen *ebiten.Image) {
+	l := &g.layout
+	vector.DrawFilledRect(screen, 0, 0, float32(l.width), float32(l.height), color.RGBA{A: 210}, false)
+
+	type row struct{ keys, help string }
+	var rows []row
+	keysWidth := 0
+	for _, a := range actions {
+		var keys []string
+		for _, b := range g.keymap.Bindings(a.name) {
+			keys = append(keys, b.String())
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		r := row{strings.Join(keys, ", "), a.help}
+		rows = append(rows, r)
+		keysWidth = max(keysWidth, visual.TextWidth(r.keys, l.scale))
+	}
+
+	margin := l.px(24)
+	lineHeight := l.px(18)
+	title := "Keys"
+	if hint := g.keyHint("help"); hint != "" {
+		title += " (" + hint + " to close)"
+	}
+	visual.PrintAt(screen, title, int(margin), int(margin), l.scale)
+	top := margin + lineHeight*1.5
+	perColumn := max(1, int((float64(l.height)-top-margin)/lineHeight))
+	columnWidth := (float64(l.width) - 2*margin) / float64((len(rows)+perColumn-1)/perColumn)
+	for i, r := range rows {
+		x := margin + columnWidth*float64(i/perColumn)
+		y := top + lineHeight*float64(i%perColumn)
+		visual.PrintAt(screen, r.keys, int(x), int(y), l.scale)
+		visual.PrintAt(screen, r.help, int(x)+keysWidth+int(l.px(12)), int(y), l.scale)
+	}
+}
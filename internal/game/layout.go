@@ -0,0 +1,67 @@
+package game
+
+// Sizes of the open file button in logical pixels.
+const (
+	buttonWidth  = 120
+	buttonHeight = 40
+	buttonX      = 20
+	buttonY      = 50
+)
+
+// rect is an axis-aligned rectangle in screen pixels.
+type rect struct {
+	X, Y, W, H float64
+}
+
+func (r rect) contains(x, y int) bool {
+	fx, fy := float64(x), float64(y)
+	return fx >= r.X && fx <= r.X+r.W && fy >= r.Y && fy <= r.Y+r.H
+}
+
+func (r rect) bottom() float64 { return r.Y + r.H }
+
+// layout places the UI controls for the current screen size. Controls are
+// designed in logical pixels and multiplied by the device scale factor; each
+// one is anchored to an edge of the screen so that it follows window resizes.
+type layout struct {
+	width  int
+	height int
+	scale  float64
+
+	status   rect // status line, anchored top-left
+	button   rect // open file button, anchored top-left
+	progress rect // progress bar, anchored bottom, stretched horizontally
+	audioBar rect // audio analysis bar, anchored bottom, stretched horizontally
+	scene    rect // free area between the controls
+}
+
+func newLayout(width, height int, scale float64) layout {
+	if scale <= 0 {
+		scale = 1
+	}
+	w, h := float64(width), float64(height)
+	margin := 20 * scale
+
+	l := layout{width: width, height: height, scale: scale}
+	l.status = rect{X: 12 * scale, Y: 12 * scale, W: w - 24*scale, H: 16 * scale}
+	l.button = rect{
+		X: buttonX * scale,
+		Y: buttonY * scale,
+		W: buttonWidth * scale,
+		H: buttonHeight * scale,
+	}
+	l.audioBar = rect{X: margin, Y: h - 80*scale, W: w - 2*margin, H: 60 * scale}
+	l.progress = rect{X: margin, Y: h - 120*scale, W: w - 2*margin, H: 30 * scale}
+
+	top := l.button.bottom() + margin
+	l.scene = rect{X: margin, Y: top, W: w - 2*margin, H: l.progress.Y - 10*scale - top}
+	if l.scene.H < 0 {
+		l.scene.H = 0
+	}
+	return l
+}
+
+// px converts a length in logical pixels to screen pixels.
+func (l layout) px(v float64) float64 {
+	return v * l.scale
+}
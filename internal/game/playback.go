@@ -0,0 +1,119 @@
+package game
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+
+	"github.com/iburimskiy/audio-visualization/internal/event"
+	"github.com/iburimskiy/audio-visualization/internal/player"
+)
+
+// speakerOutput plays through the default audio device.
+type speakerOutput struct{}
+
+func (speakerOutput) Play(rate beep.SampleRate, s beep.Streamer) error {
+	if err := speaker.Init(rate, rate.N(time.Second/20)); err != nil {
+		return err
+	}
+	speaker.Play(s)
+	return nil
+}
+
+func (speakerOutput) Lock()   { speaker.Lock() }
+func (speakerOutput) Unlock() { speaker.Unlock() }
+
+// do carries out a player command and takes a new snapshot, so the rest of
+// the frame sees its effect.
+func (g *game) do(cmd player.Command) error {
+	err := g.player.Do(cmd)
+	g.playback = g.player.State()
+	return err
+}
+
+// trackLoaded ends skipping through the queue once a track plays.
+func (g *game) trackLoaded(e event.TrackLoaded) {
+	if e.Track != "" {
+		fmt.Printf("Succefully loaded file %v\n", e.Track)
+	}
+	g.skip = nil
+}
+
+// trackFailed reports a track that could not be opened and, when skipping
+// through the queue, moves on to the next one.
+func (g *game) trackFailed(e event.TrackFailed) {
+	g.report(e.Err)
+	if e.Track != g.loading || g.skip == nil {
+		return
+	}
+	g.skipTries++
+	path, ok := g.skip()
+	g.continueSkip(g.skip, path, ok)
+}
+
+// trackEnded moves on to the next queued track once the current one has
+// finished playing.
+func (g *game) trackEnded(e event.TrackEnded) {
+	// Ignore a track replaced in the meantime, or about to be
+	if e.Serial != g.playback.Serial || g.playback.Opening != "" {
+		return
+	}
+	path, ok := g.queue.Next()
+	g.skipTo(path, ok, g.queue.Next)
+}
+
+// skipTo plays path, moving on with step past tracks that fail to load, and
+// stops when the queue runs out.
+func (g *game) skipTo(path string, ok bool, step func() (string, bool)) {
+	g.skipTries = 0
+	g.continueSkip(step, path, ok)
+}
+
+func (g *game) continueSkip(step func() (string, bool), path string, ok bool) {
+	if ok && g.skipTries < g.queue.Len() {
+		g.report(g.loadAndPlay(path))
+		g.skip = step
+		return
+	}
+
+	// End of the queue
+	g.skip = nil
+	g.report(g.do(player.Command{Kind: player.Stop}))
+}
+
+// pumpAudio pulls one frame's worth of samples through the audio chain when
+// there is no speaker to do it, so the visuals still follow the music.
+func (g *game) pumpAudio(d time.Duration) {
+	if g.null != nil {
+		g.null.Pump(d)
+	}
+}
+
+// seekToTime moves to an absolute position in the current track. The
+// position shown changes right away; the player coalesces quick requests
+// and only seeks the decoder to the latest.
+func (g *game) seekToTime(at time.Duration) error {
+	return g.do(player.Command{Kind: player.Seek, Position: at})
+}
+
+// seekBy moves the playback position by d, counting from the target of a
+// seek still pending.
+func (g *game) seekBy(d time.Duration) error {
+	if !g.playback.Loaded {
+		return nil
+	}
+	return g.seekToTime(g.playback.Position + d)
+}
+
+// seekToPercent moves to a fraction of the track's length.
+func (g *game) seekToPercent(p float64) error {
+	if !g.playback.Loaded || g.playback.Live {
+		return nil
+	}
+	return g.seekToTime(time.Duration(clamp01(p) * float64(g.playback.Duration)))
+}
+
+// waveformColumns is the resolution of the progress bar waveform.
+const waveformColumns = 512
@@ -0,0 +1,111 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+
+	"github.com/iburimskiy/audio-visualization/internal/record"
+	"github.com/iburimskiy/audio-visualization/internal/visual"
+)
+
+func (g *game) toggleRecording() error {
+	if g.recorder != nil {
+		return g.stopRecording()
+	}
+	if g.playback.Rate == 0 {
+		return errors.New("open a file before recording")
+	}
+
+	path := g.expandTemplate(g.cfg.Record.Template)
+	rec, err := record.Start(path, g.playback.Rate)
+	if err != nil {
+		return err
+	}
+	g.recorder = rec
+	g.player.AddSink(rec)
+	fmt.Printf("Recording to %v\n", path)
+	return nil
+}
+
+func (g *game) stopRecording() error {
+	if g.recorder == nil {
+		return nil
+	}
+	g.player.RemoveSink(g.recorder)
+	rec := g.recorder
+	g.recorder = nil
+	if err := rec.Stop(); err != nil {
+		return fmt.Errorf("recording %s: %w", rec.Path(), err)
+	}
+	fmt.Printf("Saved recording %v (%v)\n", rec.Path(), formatDuration(rec.Duration()))
+	return nil
+}
+
+// expandTemplate fills in the placeholders of a recording or capture name.
+func (g *game) expandTemplate(template string) string {
+	track := "recording"
+	if g.playback.Live {
+		track = "live"
+	} else if path, ok := g.queue.Current(); ok {
+		track = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	now := time.Now()
+	return strings.NewReplacer(
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("150405"),
+		"{track}", track,
+	).Replace(template)
+}
+
+// pollRecording stops a recording or capture whose encoder failed, for
+// example because the disk is full, so the error is shown.
+func (g *game) pollRecording() {
+	if g.recorder != nil && g.recorder.Failed() {
+		g.report(g.stopRecording())
+	}
+	if g.capture != nil && g.capture.Failed() {
+		g.report(g.stopCapture())
+	}
+}
+
+// Close finishes an active recording and capture and stops playback. Call it
+// after the game loop ends.
+func (g *game) Close() error {
+	return errors.Join(g.stopRecording(), g.stopCapture(), g.player.Close())
+}
+
+func (g *game) drawRecordingIndicators(screen *ebiten.Image) {
+	var lines []string
+	if g.recorder != nil {
+		lines = append(lines, "REC "+formatDuration(g.recorder.Duration()))
+	}
+	if g.capture != nil {
+		line := fmt.Sprintf("CAP %s %d frames", formatDuration(g.captureTime()), g.capture.Written())
+		if dropped := g.capture.Dropped(); dropped > 0 {
+			line += fmt.Sprintf(", %d dropped", dropped)
+		}
+		lines = append(lines, line)
+	}
+
+	scale := g.layout.scale
+	radius := g.layout.px(6)
+	// Blink once per second
+	alpha := uint8(255)
+	if math.Mod(g.time, 1) > 0.5 {
+		alpha = 90
+	}
+	for i, text := range lines {
+		x := float64(g.layout.width) - g.layout.px(20) - float64(visual.TextWidth(text, scale))
+		y := g.layout.status.Y + g.layout.px(20)*float64(i+1)
+		vector.DrawFilledCircle(screen, float32(x-radius-g.layout.px(6)), float32(y+g.layout.px(8)), float32(radius), color.RGBA{R: 230, G: 30, B: 40, A: alpha}, true)
+		visual.PrintAt(screen, text, int(x), int(y), scale)
+	}
+}
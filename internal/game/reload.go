@@ -0,0 +1,47 @@
+package game
+
+import (
+	"github.com/iburimskiy/audio-visualization/internal/config"
+)
+
+// pollConfig applies a reloaded config if one is waiting. Errors are kept for
+// the status line and leave the current settings in place.
+func (g *game) pollConfig() {
+	if g.configUpdates == nil {
+		return
+	}
+	select {
+	case u := <-g.configUpdates:
+		if u.Err != nil {
+			g.configErr = u.Err
+			return
+		}
+		if err := g.applyConfig(u.Config); err != nil {
+			g.configErr = err
+			return
+		}
+		g.configErr = nil
+	default:
+	}
+}
+
+// applyConfig switches to cfg without interrupting playback. Visual
+// parameters, colors and smoothing take effect on the next frame because they
+// are read from g.cfg every update. The window size and the visual ring size
+// only apply at startup and when the next track is opened respectively.
+func (g *game) applyConfig(cfg *config.Config) error {
+	prev := g.cfg
+	g.cfg = cfg
+	// A failed check changes nothing, so only the key check needs the
+	// scene settings put back
+	if err := g.applySceneConfig(&prev.Scenes); err != nil {
+		g.cfg = prev
+		return err
+	}
+	if err := g.applyKeyConfig(); err != nil {
+		g.cfg = prev
+		_ = g.applySceneConfig(&cfg.Scenes)
+		return err
+	}
+	return nil
+}
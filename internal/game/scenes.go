@@ -0,0 +1,77 @@
+package game
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/iburimskiy/audio-visualization/internal/config"
+	"github.com/iburimskiy/audio-visualization/internal/event"
+	"github.com/iburimskiy/audio-visualization/internal/visual"
+)
+
+// applySceneConfig copies the transition and playlist settings to the director
+// and checks that every scene named in the config exists. The transition kind
+// and autoplay can also be switched from the keyboard, so on a reload they are
+// only copied when they differ from prev, the settings applied before.
+func (g *game) applySceneConfig(prev *config.Scenes) error {
+	sc := g.cfg.Scenes
+	kind, err := visual.ParseTransition(sc.Transition)
+	if err != nil {
+		return fmt.Errorf("scenes.transition: %w", err)
+	}
+	known := map[string]bool{}
+	for _, name := range visual.Scenes() {
+		known[name] = true
+	}
+	for _, name := range sc.Default {
+		if !known[name] {
+			return fmt.Errorf("scenes.default: unknown scene %q", name)
+		}
+	}
+	for i, entry := range sc.Playlist {
+		for _, name := range strings.Split(entry, "+") {
+			if !known[name] {
+				return fmt.Errorf("scenes.playlist[%d]: unknown scene %q", i, name)
+			}
+		}
+	}
+
+	if prev == nil || sc.Transition != prev.Transition {
+		g.director.Kind = kind
+	}
+	if prev == nil || sc.AutoPlay != prev.AutoPlay {
+		g.director.AutoPlay = sc.AutoPlay
+	}
+	g.director.Duration = sc.TransitionDuration
+	g.director.Playlist = sc.Playlist
+	g.director.BarsPerScene = sc.PlaylistBars
+	g.director.BeatsPerBar = sc.BeatsPerBar
+	return nil
+}
+
+// updateScenes hands the latest analysis to every active scene.
+func (g *game) updateScenes() {
+	g.frame = visual.Frame{
+		Bands:       g.audioData,
+		Samples:     g.stereo,
+		Correlation: g.correlation,
+		Beat:        g.beat,
+		BPM:         g.beats.BPM(),
+		Time:        g.time,
+		Delta:       g.dt,
+		Rotation:    g.rotation,
+		ColorPhase:  g.colorPhase,
+		Area: image.Rect(
+			int(g.layout.scene.X), int(g.layout.scene.Y),
+			int(g.layout.scene.X+g.layout.scene.W), int(g.layout.scene.bottom()),
+		),
+		Scale:    g.layout.scale,
+		Settings: &g.cfg.Visual,
+	}
+	g.report(g.director.Update(&g.frame))
+	if scenes := strings.Join(g.director.Current().Names(), "+"); scenes != g.scenes {
+		g.scenes = scenes
+		g.events.Post(event.SceneChanged{Scenes: scenes})
+	}
+}
@@ -6,30 +6,28 @@ import (
 	"time"
 )
 
-// hsvToRgb converts HSV to RGB (hue: 0-360, saturation: 0-1, value: 0-1)
-func hsvToRgb(h, s, v float64) (uint8, uint8, uint8) {
-	h = math.Mod(h, 360)
-	c := v * s
-	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
-	m := v - c
-
-	var r, g, b float64
-	switch {
-	case h < 60:
-		r, g, b = c, x, 0
-	case h < 120:
-		r, g, b = x, c, 0
-	case h < 180:
-		r, g, b = 0, c, x
-	case h < 240:
-		r, g, b = 0, x, c
-	case h < 300:
-		r, g, b = x, 0, c
-	default:
-		r, g, b = c, 0, x
+// stereoCorrelation returns the Pearson correlation between the left and right
+// channels: +1 for mono, 0 for unrelated channels and -1 for out-of-phase audio.
+func stereoCorrelation(samples [][2]float64) float64 {
+	var lr, ll, rr float64
+	for _, s := range samples {
+		lr += s[0] * s[1]
+		ll += s[0] * s[0]
+		rr += s[1] * s[1]
+	}
+	if ll == 0 || rr == 0 {
+		return 0
 	}
+	return lr / math.Sqrt(ll*rr)
+}
 
-	return uint8((r + m) * 255), uint8((g + m) * 255), uint8((b + m) * 255)
+// smoothingAlpha returns how far an exponential smoother with the given time
+// constant (in seconds) moves towards its target over dt seconds.
+func smoothingAlpha(dt, timeConstant float64) float64 {
+	if timeConstant <= 0 {
+		return 1
+	}
+	return 1 - math.Exp(-dt/timeConstant)
 }
 
 func clamp01(v float64) float64 {
@@ -0,0 +1,268 @@
+// Package input maps keys, mouse buttons and gamepad buttons to named
+// actions. Bindings are written as text, such as "Space", "Shift+Right",
+// "MouseBack" or "PadA", so they can be set in the config file.
+package input
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Mod is a set of modifier keys.
+type Mod uint8
+
+const (
+	Shift Mod = 1 << iota
+	Ctrl
+	Alt
+	Meta
+)
+
+type modKey struct {
+	mod  Mod
+	name string
+	key  ebiten.Key
+}
+
+// modKeys lists the modifiers in the order they are written.
+var modKeys = []modKey{
+	{Ctrl, "Ctrl", ebiten.KeyControl},
+	{Alt, "Alt", ebiten.KeyAlt},
+	{Meta, "Meta", ebiten.KeyMeta},
+	{Shift, "Shift", ebiten.KeyShift},
+}
+
+// Device is what a binding is pressed on.
+type Device uint8
+
+const (
+	Keyboard Device = iota
+	Mouse
+	Gamepad
+)
+
+// Binding is one key, mouse button or gamepad button. Keyboard and mouse
+// bindings fire only when exactly their modifiers are held; gamepad
+// bindings ignore modifiers.
+type Binding struct {
+	Device Device
+	Mods   Mod
+	Key    ebiten.Key
+	Button ebiten.MouseButton
+	Pad    ebiten.StandardGamepadButton
+}
+
+var mouseNames = map[string]ebiten.MouseButton{
+	"MouseMiddle":  ebiten.MouseButtonMiddle,
+	"MouseRight":   ebiten.MouseButtonRight,
+	"MouseBack":    ebiten.MouseButton3,
+	"MouseForward": ebiten.MouseButton4,
+}
+
+// Gamepad buttons are named after an Xbox controller in the standard layout.
+var padNames = map[string]ebiten.StandardGamepadButton{
+	"PadA":     ebiten.StandardGamepadButtonRightBottom,
+	"PadB":     ebiten.StandardGamepadButtonRightRight,
+	"PadX":     ebiten.StandardGamepadButtonRightLeft,
+	"PadY":     ebiten.StandardGamepadButtonRightTop,
+	"PadLB":    ebiten.StandardGamepadButtonFrontTopLeft,
+	"PadRB":    ebiten.StandardGamepadButtonFrontTopRight,
+	"PadLT":    ebiten.StandardGamepadButtonFrontBottomLeft,
+	"PadRT":    ebiten.StandardGamepadButtonFrontBottomRight,
+	"PadBack":  ebiten.StandardGamepadButtonCenterLeft,
+	"PadStart": ebiten.StandardGamepadButtonCenterRight,
+	"PadGuide": ebiten.StandardGamepadButtonCenterCenter,
+	"PadLS":    ebiten.StandardGamepadButtonLeftStick,
+	"PadRS":    ebiten.StandardGamepadButtonRightStick,
+	"PadUp":    ebiten.StandardGamepadButtonLeftTop,
+	"PadDown":  ebiten.StandardGamepadButtonLeftBottom,
+	"PadLeft":  ebiten.StandardGamepadButtonLeftLeft,
+	"PadRight": ebiten.StandardGamepadButtonLeftRight,
+}
+
+// keyAliases are shorter or more familiar names for keys.
+var keyAliases = map[string]string{
+	"esc":   "Escape",
+	"ctrl":  "Control",
+	"left":  "ArrowLeft",
+	"right": "ArrowRight",
+	"up":    "ArrowUp",
+	"down":  "ArrowDown",
+	"del":   "Delete",
+	"pgup":  "PageUp",
+	"pgdn":  "PageDown",
+}
+
+// Parse reads a binding: modifiers joined with "+" followed by a key name
+// as ebiten spells it ("A", "Digit1", "ArrowRight", "F1", "Numpad5"), a
+// mouse button (MouseMiddle, MouseRight, MouseBack, MouseForward) or a
+// gamepad button (PadA, PadB, PadX, PadY, PadLB, PadRB, PadLT, PadRT,
+// PadBack, PadStart, PadGuide, PadLS, PadRS, PadUp, PadDown, PadLeft,
+// PadRight). Names are case-insensitive; "?" is short for "Shift+Slash".
+func Parse(s string) (Binding, error) {
+	var b Binding
+	parts := strings.Split(strings.TrimSpace(s), "+")
+	name := parts[len(parts)-1]
+	if name == "" && len(parts) > 1 {
+		return b, fmt.Errorf("%q: missing key after +", s)
+	}
+	for _, p := range parts[:len(parts)-1] {
+		if strings.EqualFold(p, "Control") {
+			p = "Ctrl"
+		}
+		i := slices.IndexFunc(modKeys, func(m modKey) bool { return strings.EqualFold(m.name, p) })
+		if i < 0 {
+			return b, fmt.Errorf("%q: unknown modifier %q", s, p)
+		}
+		b.Mods |= modKeys[i].mod
+	}
+
+	if name == "?" {
+		b.Mods |= Shift
+		name = "Slash"
+	}
+	for n, button := range mouseNames {
+		if strings.EqualFold(n, name) {
+			b.Device, b.Button = Mouse, button
+			return b, nil
+		}
+	}
+	if strings.EqualFold(name, "MouseLeft") {
+		return b, fmt.Errorf("%q: the left mouse button is reserved for the buttons and the progress bar", s)
+	}
+	for n, pad := range padNames {
+		if strings.EqualFold(n, name) {
+			if b.Mods != 0 {
+				return b, fmt.Errorf("%q: gamepad buttons take no modifiers", s)
+			}
+			b.Device, b.Pad = Gamepad, pad
+			return b, nil
+		}
+	}
+	if alias, ok := keyAliases[strings.ToLower(name)]; ok {
+		name = alias
+	}
+	if err := b.Key.UnmarshalText([]byte(name)); err != nil {
+		return b, fmt.Errorf("%q: unknown key %q", s, name)
+	}
+	return b, nil
+}
+
+// String returns the binding in the form Parse reads.
+func (b Binding) String() string {
+	if b.Device == Keyboard && b.Key == ebiten.KeySlash && b.Mods == Shift {
+		return "?"
+	}
+	var sb strings.Builder
+	if b.Device != Gamepad {
+		for _, m := range modKeys {
+			if b.Mods&m.mod != 0 {
+				sb.WriteString(m.name + "+")
+			}
+		}
+	}
+	switch b.Device {
+	case Mouse:
+		for n, button := range mouseNames {
+			if button == b.Button {
+				sb.WriteString(n)
+			}
+		}
+	case Gamepad:
+		for n, pad := range padNames {
+			if pad == b.Pad {
+				sb.WriteString(n)
+			}
+		}
+	default:
+		name := b.Key.String()
+		for _, prefix := range []string{"Arrow", "Digit"} {
+			name = strings.TrimPrefix(name, prefix)
+		}
+		sb.WriteString(name)
+	}
+	return sb.String()
+}
+
+// Keymap binds actions, identified by name, to inputs.
+type Keymap struct {
+	actions  []string
+	bindings map[string][]Binding
+}
+
+// New builds a keymap from the bindings of each action, in the order given
+// by actions. Unparsable bindings and bindings used by two actions are
+// reported together.
+func New(actions []string, bindings map[string][]string) (*Keymap, error) {
+	k := &Keymap{actions: actions, bindings: make(map[string][]Binding)}
+	var problems []error
+	owner := make(map[Binding]string)
+	for _, action := range actions {
+		for _, s := range bindings[action] {
+			b, err := Parse(s)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("%s: %w", action, err))
+				continue
+			}
+			if other, ok := owner[b]; ok && other != action {
+				problems = append(problems, fmt.Errorf("%s: %s is also bound to %s", action, b, other))
+				continue
+			}
+			owner[b] = action
+			k.bindings[action] = append(k.bindings[action], b)
+		}
+	}
+	return k, errors.Join(problems...)
+}
+
+// Actions returns the action names in order.
+func (k *Keymap) Actions() []string {
+	return k.actions
+}
+
+// Bindings returns the inputs bound to action.
+func (k *Keymap) Bindings(action string) []Binding {
+	return k.bindings[action]
+}
+
+// JustPressed appends the actions whose input was pressed this frame to
+// dst, in keymap order.
+func (k *Keymap) JustPressed(dst []string) []string {
+	var mods Mod
+	for _, m := range modKeys {
+		if ebiten.IsKeyPressed(m.key) {
+			mods |= m.mod
+		}
+	}
+	pads := ebiten.AppendGamepadIDs(nil)
+
+	for _, action := range k.actions {
+		for _, b := range k.bindings[action] {
+			if b.justPressed(mods, pads) {
+				dst = append(dst, action)
+				break
+			}
+		}
+	}
+	return dst
+}
+
+func (b Binding) justPressed(mods Mod, pads []ebiten.GamepadID) bool {
+	switch b.Device {
+	case Mouse:
+		return mods == b.Mods && inpututil.IsMouseButtonJustPressed(b.Button)
+	case Gamepad:
+		for _, id := range pads {
+			if ebiten.IsStandardGamepadLayoutAvailable(id) && inpututil.IsStandardGamepadButtonJustPressed(id, b.Pad) {
+				return true
+			}
+		}
+		return false
+	}
+	return mods == b.Mods && inpututil.IsKeyJustPressed(b.Key)
+}
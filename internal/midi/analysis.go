@@ -0,0 +1,137 @@
+package midi
+
+import (
+	"math"
+	"sort"
+
+	"github.com/iburimskiy/audio-visualization/internal/analysis"
+)
+
+// Division is the resolution of files made by FromReport, in ticks per
+// quarter note.
+const Division = 480
+
+// Notes used by FromReport. Beats sit on the General MIDI bass drum of the
+// drum channel so they play back as a click; bands count up from BandNote.
+const (
+	BeatChannel = 9
+	BeatNote    = 36
+	BandChannel = 0
+	BandNote    = 48
+)
+
+// Onsets are detected when a band rises by at least onsetRise dB from one
+// frame to the next to above onsetFloor dBFS, at most once per onsetGap
+// seconds. Onset velocities span onsetFloor to 0 dBFS.
+const (
+	onsetRise  = 6
+	onsetFloor = -60
+	onsetGap   = 0.1
+)
+
+// FromReport converts an analysis into a type 1 file with three tracks: the
+// tempo map, a note on every beat, and a note on every onset of every band.
+//
+// The tempo map places the detected beats on quarter notes, so the notes
+// line up with the grid when the file is imported into a DAW. Intervals that
+// span several beats of the overall tempo (missed beats) count as that many
+// quarter notes. Without a tempo the file runs at 120 BPM.
+func FromReport(r *analysis.Report) *File {
+	tm := newTempoMap(r.Beats, r.BPM)
+
+	beats := Track{TrackName("Beats")}
+	for _, t := range r.Beats {
+		tick := tm.tick(t)
+		beats = append(beats,
+			Note(tick, true, BeatChannel, BeatNote, 100),
+			Note(tick+Division/8, false, BeatChannel, BeatNote, 0))
+	}
+
+	bands := Track{TrackName("Bands")}
+	nBands := max(len(r.Frames.Edges)-1, 0)
+	last := make([]float64, nBands)
+	for i := range last {
+		last[i] = math.Inf(-1)
+	}
+	for i := 1; i < len(r.Frames.Levels); i++ {
+		t := float64(i) * r.Frames.HopSeconds
+		prev, cur := r.Frames.Levels[i-1], r.Frames.Levels[i]
+		for b := 0; b < nBands && BandNote+b < 128; b++ {
+			if cur[b] < onsetFloor || cur[b]-prev[b] < onsetRise || t-last[b] < onsetGap {
+				continue
+			}
+			last[b] = t
+			velocity := byte(math.Round(1 + 126*min(1, (cur[b]-onsetFloor)/-onsetFloor)))
+			tick := tm.tick(t)
+			bands = append(bands,
+				Note(tick, true, BandChannel, byte(BandNote+b), velocity),
+				Note(tick+Division/8, false, BandChannel, byte(BandNote+b), 0))
+		}
+	}
+
+	return &File{Format: 1, Division: Division, Tracks: []Track{tm.track(), beats, bands}}
+}
+
+// tempoMap converts seconds to ticks. Each segment starts at a time and
+// tick and runs at a constant tempo.
+type tempoMap struct {
+	segments []segment
+}
+
+type segment struct {
+	time float64
+	beat float64 // in quarter notes
+	bpm  float64
+}
+
+func newTempoMap(beats []float64, bpm float64) *tempoMap {
+	if bpm <= 0 || len(beats) < 2 {
+		return &tempoMap{segments: []segment{{bpm: 120}}}
+	}
+	period := 60 / bpm
+	// The first beat falls on the nearest whole quarter note, and the lead-in
+	// is stretched to fit. A first beat within half a quarter of the start
+	// becomes tick 0, and anything before it is moved there.
+	m := &tempoMap{}
+	first := math.Round(beats[0] / period)
+	if first > 0 {
+		m.segments = append(m.segments, segment{bpm: 60 * first / beats[0]})
+	}
+
+	beat := first
+	for i := 1; i < len(beats); i++ {
+		d := beats[i] - beats[i-1]
+		quarters := max(1, math.Round(d/period))
+		m.segments = append(m.segments, segment{time: beats[i-1], beat: beat, bpm: 60 * quarters / d})
+		beat += quarters
+	}
+	m.segments = append(m.segments, segment{time: beats[len(beats)-1], beat: beat, bpm: bpm})
+	return m
+}
+
+// tick returns the tick at t seconds.
+func (m *tempoMap) tick(t float64) uint32 {
+	i := sort.Search(len(m.segments), func(i int) bool { return m.segments[i].time > t }) - 1
+	s := m.segments[max(i, 0)]
+	beat := s.beat + (t-s.time)*s.bpm/60
+	return uint32(math.Round(max(beat, 0) * Division))
+}
+
+// track returns the tempo map as the conductor track, in 4/4.
+func (m *tempoMap) track() Track {
+	t := Track{
+		TrackName("Tempo"),
+		{Status: Meta, Type: MetaTimeSignature, Data: []byte{4, 2, 24, 8}},
+	}
+	var last uint32
+	for i, s := range m.segments {
+		tick := uint32(math.Round(s.beat * Division))
+		if i > 0 && tick == last {
+			// A later tempo at the same tick replaces the earlier one
+			t = t[:len(t)-1]
+		}
+		t = append(t, Tempo(tick, s.bpm))
+		last = tick
+	}
+	return t
+}
@@ -0,0 +1,260 @@
+// Package midi turns the analysis of a track into MIDI: a tempo map that
+// follows the detected beats and notes on beats and band onsets, written as a
+// Standard MIDI File.
+package midi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Status bytes
+const (
+	NoteOff = 0x80
+	NoteOn  = 0x90
+	Meta    = 0xff
+)
+
+// Meta event types
+const (
+	MetaTrackName     = 0x03
+	MetaEndOfTrack    = 0x2f
+	MetaTempo         = 0x51
+	MetaTimeSignature = 0x58
+)
+
+// File is a Standard MIDI File.
+type File struct {
+	Format   int    // 0, 1 or 2; Write uses 1 for more than one track
+	Division uint16 // ticks per quarter note
+	Tracks   []Track
+}
+
+// Track is a sequence of events. The end-of-track event is implied: Write
+// adds it and Read drops it.
+type Track []Event
+
+// Event is a channel message or a meta event at an absolute time.
+type Event struct {
+	Tick   uint32
+	Status byte // channel message with its channel, or Meta
+	Type   byte // meta event type when Status is Meta
+	Data   []byte
+}
+
+// Note returns a note-on or note-off event.
+func Note(tick uint32, on bool, channel, key, velocity byte) Event {
+	status := byte(NoteOff)
+	if on {
+		status = NoteOn
+	}
+	return Event{Tick: tick, Status: status | channel&0x0f, Data: []byte{key & 0x7f, velocity & 0x7f}}
+}
+
+// Tempo returns a tempo event of bpm quarter notes per minute.
+func Tempo(tick uint32, bpm float64) Event {
+	us := uint32(60e6/bpm + 0.5)
+	return Event{Tick: tick, Status: Meta, Type: MetaTempo, Data: []byte{byte(us >> 16), byte(us >> 8), byte(us)}}
+}
+
+// TrackName returns a track name event.
+func TrackName(name string) Event {
+	return Event{Status: Meta, Type: MetaTrackName, Data: []byte(name)}
+}
+
+// Write encodes f. The events of each track are sorted by tick, keeping the
+// order of events at the same tick.
+func (f *File) Write(w io.Writer) error {
+	format := f.Format
+	if len(f.Tracks) > 1 && format == 0 {
+		format = 1
+	}
+	bw := bufio.NewWriter(w)
+	header := [14]byte{'M', 'T', 'h', 'd', 0, 0, 0, 6}
+	binary.BigEndian.PutUint16(header[8:], uint16(format))
+	binary.BigEndian.PutUint16(header[10:], uint16(len(f.Tracks)))
+	binary.BigEndian.PutUint16(header[12:], f.Division)
+	bw.Write(header[:])
+
+	var chunk bytes.Buffer
+	for _, t := range f.Tracks {
+		events := append(Track(nil), t...)
+		sort.SliceStable(events, func(i, j int) bool { return events[i].Tick < events[j].Tick })
+
+		chunk.Reset()
+		var tick uint32
+		for _, e := range events {
+			chunk.Write(varint(e.Tick - tick))
+			tick = e.Tick
+			chunk.WriteByte(e.Status)
+			if e.Status == Meta {
+				chunk.WriteByte(e.Type)
+				chunk.Write(varint(uint32(len(e.Data))))
+			}
+			chunk.Write(e.Data)
+		}
+		chunk.Write([]byte{0, Meta, MetaEndOfTrack, 0})
+
+		var head [8]byte
+		copy(head[:], "MTrk")
+		binary.BigEndian.PutUint32(head[4:], uint32(chunk.Len()))
+		bw.Write(head[:])
+		bw.Write(chunk.Bytes())
+	}
+	return bw.Flush()
+}
+
+// varint encodes v as a variable-length quantity.
+func varint(v uint32) []byte {
+	b := []byte{byte(v & 0x7f)}
+	for v >>= 7; v > 0; v >>= 7 {
+		b = append([]byte{byte(v&0x7f) | 0x80}, b...)
+	}
+	return b
+}
+
+// ErrFormat is returned for data that is not a Standard MIDI File.
+var ErrFormat = errors.New("not a standard MIDI file")
+
+// Read decodes a Standard MIDI File. System exclusive events are skipped;
+// unknown chunks are ignored.
+func Read(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 14 || string(data[:4]) != "MThd" {
+		return nil, ErrFormat
+	}
+	size := int(binary.BigEndian.Uint32(data[4:]))
+	if size < 6 || 8+size > len(data) {
+		return nil, ErrFormat
+	}
+	f := &File{
+		Format:   int(binary.BigEndian.Uint16(data[8:])),
+		Division: binary.BigEndian.Uint16(data[12:]),
+	}
+	if f.Division&0x8000 != 0 {
+		return nil, fmt.Errorf("midi: SMPTE time division is not supported")
+	}
+
+	for rest := data[8+size:]; len(rest) >= 8; {
+		id := string(rest[:4])
+		size := int(binary.BigEndian.Uint32(rest[4:]))
+		if size > len(rest)-8 {
+			return nil, fmt.Errorf("midi: %s chunk is truncated", id)
+		}
+		chunk := rest[8 : 8+size]
+		rest = rest[8+size:]
+		if id != "MTrk" {
+			continue
+		}
+		t, err := readTrack(chunk)
+		if err != nil {
+			return nil, fmt.Errorf("midi: track %d: %w", len(f.Tracks), err)
+		}
+		f.Tracks = append(f.Tracks, t)
+	}
+	return f, nil
+}
+
+func readTrack(b []byte) (Track, error) {
+	var (
+		t       Track
+		tick    uint32
+		running byte
+		p       int
+	)
+	next := func() (uint32, error) {
+		var v uint32
+		for i := 0; i < 4; i++ {
+			if p >= len(b) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			c := b[p]
+			p++
+			v = v<<7 | uint32(c&0x7f)
+			if c&0x80 == 0 {
+				return v, nil
+			}
+		}
+		return 0, errors.New("variable-length quantity is too long")
+	}
+	take := func(n int) ([]byte, error) {
+		if n > len(b)-p {
+			return nil, io.ErrUnexpectedEOF
+		}
+		d := append([]byte(nil), b[p:p+n]...)
+		p += n
+		return d, nil
+	}
+
+	for p < len(b) {
+		delta, err := next()
+		if err != nil {
+			return nil, err
+		}
+		tick += delta
+		if p >= len(b) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		status := b[p]
+		if status < 0x80 {
+			// Running status reuses the last channel message status
+			if running == 0 {
+				return nil, errors.New("data byte without a status")
+			}
+			status = running
+		} else {
+			p++
+		}
+
+		switch {
+		case status == Meta:
+			if p >= len(b) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			typ := b[p]
+			p++
+			n, err := next()
+			if err != nil {
+				return nil, err
+			}
+			d, err := take(int(n))
+			if err != nil {
+				return nil, err
+			}
+			if typ == MetaEndOfTrack {
+				return t, nil
+			}
+			t = append(t, Event{Tick: tick, Status: Meta, Type: typ, Data: d})
+		case status == 0xf0 || status == 0xf7:
+			n, err := next()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := take(int(n)); err != nil {
+				return nil, err
+			}
+		case status >= 0xf0:
+			return nil, fmt.Errorf("unexpected status %#x", status)
+		default:
+			running = status
+			n := 2
+			if kind := status & 0xf0; kind == 0xc0 || kind == 0xd0 {
+				n = 1
+			}
+			d, err := take(n)
+			if err != nil {
+				return nil, err
+			}
+			t = append(t, Event{Tick: tick, Status: status, Data: d})
+		}
+	}
+	return nil, errors.New("missing end of track")
+}
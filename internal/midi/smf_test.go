@@ -0,0 +1,170 @@
+package midi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/iburimskiy/audio-visualization/internal/analysis"
+)
+
+func TestFromReportRoundTrip(t *testing.T) {
+	r := &analysis.Report{
+		BPM:   120,
+		Beats: []float64{0.5, 1.0, 1.5, 2.1},
+		Frames: analysis.Frames{
+			HopSeconds: 0.25,
+			Edges:      []float64{20, 200, 2000},
+			Levels:     [][]float64{{-80, -80}, {-10, -80}, {-10, -30}},
+		},
+	}
+	want := FromReport(r)
+
+	var buf bytes.Buffer
+	if err := want.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Format != 1 || got.Division != Division || len(got.Tracks) != 3 {
+		t.Fatalf("header: format %d, division %d, %d tracks", got.Format, got.Division, len(got.Tracks))
+	}
+	for i, track := range want.Tracks {
+		// Write puts the events in tick order
+		sorted := append(Track(nil), track...)
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Tick < sorted[j].Tick })
+		if !reflect.DeepEqual(got.Tracks[i], sorted) {
+			t.Errorf("track %d:\n got %v\nwant %v", i, got.Tracks[i], sorted)
+		}
+	}
+
+	// The first beat falls on quarter note 1 and each beat on the next one;
+	// the long interval before the last beat slows the tempo to 100 BPM
+	var tempos []Event
+	for _, e := range got.Tracks[0] {
+		if e.Type == MetaTempo {
+			tempos = append(tempos, e)
+		}
+	}
+	wantTempos := []Event{Tempo(0, 120), Tempo(480, 120), Tempo(960, 120), Tempo(1440, 100), Tempo(1920, 120)}
+	if !reflect.DeepEqual(tempos, wantTempos) {
+		t.Errorf("tempo map:\n got %v\nwant %v", tempos, wantTempos)
+	}
+	if us := tempos[3].Data; !bytes.Equal(us, []byte{0x09, 0x27, 0xc0}) {
+		t.Errorf("100 BPM encoded as %x", us)
+	}
+
+	var beats []uint32
+	for _, e := range got.Tracks[1] {
+		if e.Status == NoteOn|BeatChannel {
+			beats = append(beats, e.Tick)
+		}
+	}
+	if want := []uint32{480, 960, 1440, 1920}; !reflect.DeepEqual(beats, want) {
+		t.Errorf("beat ticks %v, want %v", beats, want)
+	}
+
+	wantBands := Track{
+		TrackName("Bands"),
+		Note(240, true, BandChannel, BandNote, 106),
+		Note(300, false, BandChannel, BandNote, 0),
+		Note(480, true, BandChannel, BandNote+1, 64),
+		Note(540, false, BandChannel, BandNote+1, 0),
+	}
+	if !reflect.DeepEqual(got.Tracks[2], wantBands) {
+		t.Errorf("band onsets:\n got %v\nwant %v", got.Tracks[2], wantBands)
+	}
+}
+
+func TestFromReportWithoutTempo(t *testing.T) {
+	f := FromReport(&analysis.Report{Beats: []float64{1}})
+	if got := f.Tracks[0][2:]; !reflect.DeepEqual(got, Track{Tempo(0, 120)}) {
+		t.Errorf("tempo map %v", got)
+	}
+	// One second at 120 BPM is two quarter notes
+	if got := f.Tracks[1][1].Tick; got != 2*Division {
+		t.Errorf("beat at tick %d", got)
+	}
+}
+
+// handBuilt is a type 0 file as another program might write it, with
+// running status, a system exclusive event and a chunk of an unknown type.
+var handBuilt = []byte{
+	'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 0, 0, 1, 0, 96,
+	'X', 'y', 'z', 'w', 0, 0, 0, 2, 0xaa, 0xbb,
+	'M', 'T', 'r', 'k', 0, 0, 0, 35,
+	0x00, 0xff, 0x51, 0x03, 0x07, 0xa1, 0x20, // tempo, 120 BPM
+	0x00, 0x90, 0x3c, 0x40, // note on
+	0x60, 0x3c, 0x00, // running status: note on at velocity 0
+	0x00, 0xf0, 0x03, 0x7e, 0x7f, 0xf7, // sysex, skipped
+	0x00, 0xc0, 0x05, // program change, one data byte
+	0x81, 0x00, 0xb0, 0x07, 0x64, // controller after 128 ticks
+	0x00, 0x0a, 0x40, // running status: another controller
+	0x00, 0xff, 0x2f, 0x00, // end of track
+}
+
+func TestReadRunningStatus(t *testing.T) {
+	f, err := Read(bytes.NewReader(handBuilt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &File{Format: 0, Division: 96, Tracks: []Track{{
+		Tempo(0, 120),
+		{Tick: 0, Status: 0x90, Data: []byte{0x3c, 0x40}},
+		{Tick: 96, Status: 0x90, Data: []byte{0x3c, 0x00}},
+		{Tick: 96, Status: 0xc0, Data: []byte{0x05}},
+		{Tick: 224, Status: 0xb0, Data: []byte{0x07, 0x64}},
+		{Tick: 224, Status: 0xb0, Data: []byte{0x0a, 0x40}},
+	}}}
+	if !reflect.DeepEqual(f, want) {
+		t.Fatalf("read:\n got %+v\nwant %+v", f, want)
+	}
+
+	// Writing it back keeps the events, spelled out without running status
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	again, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, want) {
+		t.Errorf("after writing:\n got %+v\nwant %+v", again, want)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	track := func(b ...byte) []byte {
+		data := append([]byte(nil), handBuilt[:14]...)
+		data = append(data, 'M', 'T', 'r', 'k', 0, 0, 0, byte(len(b)))
+		return append(data, b...)
+	}
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrFormat},
+		{"not midi", []byte("RIFF\x00\x00\x00\x06\x00\x00\x00\x01\x00\x60"), ErrFormat},
+		{"short header", handBuilt[:10], ErrFormat},
+		{"truncated chunk", handBuilt[:len(handBuilt)-1], nil},
+		{"truncated event", track(0x00, 0x90, 0x3c), io.ErrUnexpectedEOF},
+		{"no end of track", track(0x00, 0x90, 0x3c, 0x40), nil},
+		{"no status", track(0x00, 0x3c, 0x40), nil},
+		{"long delta", track(0x81, 0x81, 0x81, 0x81, 0x01), nil},
+		{"smpte", []byte{'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 0, 0, 1, 0xe7, 0x28}, nil},
+	} {
+		_, err := Read(bytes.NewReader(tc.data))
+		if err == nil {
+			t.Errorf("%s: no error", tc.name)
+		} else if tc.want != nil && !errors.Is(err, tc.want) {
+			t.Errorf("%s: %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+// Package mpris publishes the player on the D-Bus session bus as an MPRIS2
+// media player, so desktop media keys and status widgets on Linux can
+// control it. Commands go through the control hub like those of the remote
+// control, and seeks are reported as they happen from the event bus.
+package mpris
@@ -0,0 +1,338 @@
+//go:build linux
+
+package mpris
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/iburimskiy/audio-visualization/internal/control"
+	"github.com/iburimskiy/audio-visualization/internal/event"
+)
+
+const (
+	busName     = "org.mpris.MediaPlayer2.audio_visualization"
+	objectPath  = "/org/mpris/MediaPlayer2"
+	rootIface   = "org.mpris.MediaPlayer2"
+	playerIface = "org.mpris.MediaPlayer2.Player"
+	noTrack     = dbus.ObjectPath("/org/mpris/MediaPlayer2/TrackList/NoTrack")
+)
+
+// commandTimeout is how long a method call waits for the game.
+const commandTimeout = 2 * time.Second
+
+// pollInterval is how often property changes are looked for.
+const pollInterval = 200 * time.Millisecond
+
+// Server is the MPRIS service on the session bus.
+type Server struct {
+	hub         *control.Hub
+	conn        *dbus.Conn
+	props       *prop.Properties
+	unsubscribe func()
+
+	last control.Status
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Start connects to the session bus and publishes the player. If another
+// instance owns the well-known name, a per-process name is used as MPRIS
+// recommends. Seeks posted to events are signaled to clients.
+func Start(hub *control.Hub, events *event.Bus) (*Server, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{hub: hub, conn: conn, stop: make(chan struct{}), done: make(chan struct{})}
+	if err := s.export(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reply, err := conn.RequestName(busName, dbus.NameFlagDoNotQueue)
+	if err == nil && reply != dbus.RequestNameReplyPrimaryOwner {
+		reply, err = conn.RequestName(fmt.Sprintf("%s.instance%d", busName, os.Getpid()), dbus.NameFlagDoNotQueue)
+	}
+	if err == nil && reply != dbus.RequestNameReplyPrimaryOwner {
+		err = fmt.Errorf("bus name %s is taken", busName)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	s.unsubscribe = events.SubscribeAsync(16, s.handle)
+	go s.run()
+	return s, nil
+}
+
+func (s *Server) export() error {
+	st := s.hub.Status()
+	s.last = st
+	var err error
+	s.props, err = prop.Export(s.conn, objectPath, prop.Map{
+		rootIface: {
+			"CanQuit":             {Value: false, Emit: prop.EmitConst},
+			"CanRaise":            {Value: false, Emit: prop.EmitConst},
+			"HasTrackList":        {Value: false, Emit: prop.EmitConst},
+			"Identity":            {Value: "Audio Visualization", Emit: prop.EmitConst},
+			"SupportedUriSchemes": {Value: []string{"file", "http", "https"}, Emit: prop.EmitConst},
+			"SupportedMimeTypes": {Value: []string{
+				"audio/mpeg", "audio/flac", "audio/x-wav", "audio/ogg", "audio/x-mpegurl", "audio/x-scpls",
+			}, Emit: prop.EmitConst},
+		},
+		playerIface: {
+			"PlaybackStatus": {Value: playbackStatus(st), Emit: prop.EmitTrue},
+			"Rate":           {Value: 1.0, Emit: prop.EmitConst},
+			"MinimumRate":    {Value: 1.0, Emit: prop.EmitConst},
+			"MaximumRate":    {Value: 1.0, Emit: prop.EmitConst},
+			"Metadata":       {Value: metadata(st), Emit: prop.EmitTrue},
+			"Volume":         {Value: st.Volume / 100, Writable: true, Emit: prop.EmitTrue, Callback: s.setVolume},
+			"Position":       {Value: micros(st.Position), Emit: prop.EmitFalse},
+			"CanGoNext":      {Value: canGoNext(st), Emit: prop.EmitTrue},
+			"CanGoPrevious":  {Value: canGoPrevious(st), Emit: prop.EmitTrue},
+			"CanPlay":        {Value: canPlay(st), Emit: prop.EmitTrue},
+			"CanPause":       {Value: true, Emit: prop.EmitConst},
+			"CanSeek":        {Value: canSeek(st), Emit: prop.EmitTrue},
+			"CanControl":     {Value: true, Emit: prop.EmitConst},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.conn.Export(root{}, objectPath, rootIface); err != nil {
+		return err
+	}
+	if err := s.conn.ExportWithMap(player{s}, playerMethods, objectPath, playerIface); err != nil {
+		return err
+	}
+	methods := introspect.Methods(player{s})
+	for i, m := range methods {
+		if name, ok := playerMethods[m.Name]; ok {
+			methods[i].Name = name
+		}
+	}
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{Name: rootIface, Methods: introspect.Methods(root{}), Properties: s.props.Introspection(rootIface)},
+			{
+				Name:       playerIface,
+				Methods:    methods,
+				Properties: s.props.Introspection(playerIface),
+				Signals: []introspect.Signal{{
+					Name: "Seeked",
+					Args: []introspect.Arg{{Name: "Position", Type: "x"}},
+				}},
+			},
+		},
+	}
+	return s.conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable")
+}
+
+// run mirrors the published status into the properties.
+func (s *Server) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.update(s.hub.Status())
+		}
+	}
+}
+
+// handle reports seeks to clients, which otherwise assume the position
+// advances steadily while playing.
+func (s *Server) handle(e event.Event) {
+	if e, ok := e.(event.Seeked); ok {
+		s.conn.Emit(objectPath, playerIface+".Seeked", micros(e.Position.Seconds()))
+	}
+}
+
+// update sets the properties that changed since the last poll.
+func (s *Server) update(st control.Status) {
+	last := s.last
+	s.last = st
+
+	set := func(name string, changed bool, v any) {
+		if changed {
+			s.props.SetMust(playerIface, name, v)
+		}
+	}
+	set("PlaybackStatus", st.State != last.State, playbackStatus(st))
+	set("Metadata", st.Track != last.Track || st.Title != last.Title || st.Duration != last.Duration || st.Index != last.Index, metadata(st))
+	set("Volume", st.Volume != last.Volume, st.Volume/100)
+	set("CanGoNext", canGoNext(st) != canGoNext(last), canGoNext(st))
+	set("CanGoPrevious", canGoPrevious(st) != canGoPrevious(last), canGoPrevious(st))
+	set("CanPlay", canPlay(st) != canPlay(last), canPlay(st))
+	set("CanSeek", canSeek(st) != canSeek(last), canSeek(st))
+	set("Position", st.Position != last.Position, micros(st.Position))
+}
+
+func (s *Server) do(cmd control.Command) *dbus.Error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	if err := s.hub.Do(ctx, cmd); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+func (s *Server) setVolume(c *prop.Change) *dbus.Error {
+	v, _ := c.Value.(float64)
+	return s.do(control.Command{Kind: control.Volume, Value: math.Min(math.Max(v, 0), 2) * 100})
+}
+
+// seek jumps to pos seconds; handle reports it with Seeked. Positions past
+// the end skip to the next track, as MPRIS asks.
+func (s *Server) seek(pos float64) *dbus.Error {
+	st := s.hub.Status()
+	if !canSeek(st) {
+		return nil
+	}
+	if pos >= st.Duration {
+		return s.do(control.Command{Kind: control.Next})
+	}
+	return s.do(control.Command{Kind: control.Seek, Value: math.Max(pos, 0)})
+}
+
+// Close stops publishing and leaves the bus.
+func (s *Server) Close() error {
+	s.unsubscribe()
+	close(s.stop)
+	<-s.done
+	return s.conn.Close()
+}
+
+// root implements org.mpris.MediaPlayer2. The window cannot be raised and
+// the player is closed with its window, so both methods do nothing.
+type root struct{}
+
+func (root) Raise() *dbus.Error { return nil }
+func (root) Quit() *dbus.Error  { return nil }
+
+// player implements org.mpris.MediaPlayer2.Player.
+type player struct {
+	s *Server
+}
+
+// playerMethods renames methods whose D-Bus names would clash with Go
+// conventions.
+var playerMethods = map[string]string{"SeekBy": "Seek"}
+
+func (p player) Next() *dbus.Error {
+	return p.s.do(control.Command{Kind: control.Next})
+}
+
+func (p player) Previous() *dbus.Error {
+	return p.s.do(control.Command{Kind: control.Previous})
+}
+
+func (p player) Pause() *dbus.Error {
+	return p.s.do(control.Command{Kind: control.Pause})
+}
+
+func (p player) PlayPause() *dbus.Error {
+	return p.s.do(control.Command{Kind: control.Toggle})
+}
+
+// Stop pauses; the player has no stopped state it can return to.
+func (p player) Stop() *dbus.Error {
+	return p.s.do(control.Command{Kind: control.Pause})
+}
+
+func (p player) Play() *dbus.Error {
+	return p.s.do(control.Command{Kind: control.Play})
+}
+
+// SeekBy moves by offset microseconds.
+func (p player) SeekBy(offset int64) *dbus.Error {
+	return p.s.seek(p.s.hub.Status().Position + float64(offset)/1e6)
+}
+
+// SetPosition jumps to position microseconds if track is still playing.
+func (p player) SetPosition(track dbus.ObjectPath, position int64) *dbus.Error {
+	st := p.s.hub.Status()
+	if track != trackID(st) || position < 0 || float64(position)/1e6 > st.Duration {
+		return nil
+	}
+	return p.s.seek(float64(position) / 1e6)
+}
+
+// OpenUri queues a file, playlist or stream and plays it.
+func (p player) OpenUri(uri string) *dbus.Error {
+	path := uri
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		path = u.Path
+	}
+	return p.s.do(control.Command{Kind: control.Enqueue, Text: path, Value: 1})
+}
+
+func playbackStatus(st control.Status) string {
+	switch st.State {
+	case "playing":
+		return "Playing"
+	case "paused":
+		return "Paused"
+	}
+	return "Stopped"
+}
+
+// trackID names the current track by its place in the queue.
+func trackID(st control.Status) dbus.ObjectPath {
+	if st.Track == "" && !st.Live {
+		return noTrack
+	}
+	return dbus.ObjectPath(fmt.Sprintf("%s/track/%d", objectPath, st.Index))
+}
+
+func metadata(st control.Status) map[string]dbus.Variant {
+	m := map[string]dbus.Variant{"mpris:trackid": dbus.MakeVariant(trackID(st))}
+	if st.Track == "" {
+		if st.Title != "" {
+			m["xesam:title"] = dbus.MakeVariant(st.Title)
+		}
+		return m
+	}
+	title := st.Title
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(st.Track), filepath.Ext(st.Track))
+	}
+	m["xesam:title"] = dbus.MakeVariant(title)
+	if st.Duration > 0 {
+		m["mpris:length"] = dbus.MakeVariant(micros(st.Duration))
+	}
+	if strings.Contains(st.Track, "://") {
+		m["xesam:url"] = dbus.MakeVariant(st.Track)
+	} else if abs, err := filepath.Abs(st.Track); err == nil {
+		m["xesam:url"] = dbus.MakeVariant((&url.URL{Scheme: "file", Path: abs}).String())
+	}
+	return m
+}
+
+func canGoNext(st control.Status) bool     { return st.Index+1 < len(st.Queue) }
+func canGoPrevious(st control.Status) bool { return st.Index > 0 }
+func canPlay(st control.Status) bool       { return st.Track != "" || st.Live || len(st.Queue) > 0 }
+func canSeek(st control.Status) bool       { return !st.Live && st.Duration > 0 }
+
+func micros(seconds float64) int64 {
+	return int64(math.Round(seconds * 1e6))
+}
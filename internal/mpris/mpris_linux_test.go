@@ -0,0 +1,206 @@
+//go:build linux
+
+package mpris
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/iburimskiy/audio-visualization/internal/control"
+	"github.com/iburimskiy/audio-visualization/internal/event"
+)
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// privateBus starts a session bus of its own for the test and points
+// DBUS_SESSION_BUS_ADDRESS at it.
+func privateBus(t *testing.T) {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+	dir := t.TempDir()
+	config := filepath.Join(dir, "session.conf")
+	if err := os.WriteFile(config, []byte(strings.Replace(busConfig, "%s", dir, 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(daemon, "--config-file="+config, "--nofork", "--print-address")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	address, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Fatalf("dbus-daemon: %v", err)
+	}
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", strings.TrimSpace(address))
+}
+
+// fakeGame carries out the hub's commands like the game does, posting a
+// Seeked event for every seek.
+type fakeGame struct {
+	hub    *control.Hub
+	events *event.Bus
+
+	mu       sync.Mutex
+	status   control.Status
+	commands []control.Command
+}
+
+func (g *fakeGame) run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(time.Millisecond):
+		}
+		for cmd := g.hub.Pending(); cmd != nil; cmd = g.hub.Pending() {
+			g.mu.Lock()
+			g.commands = append(g.commands, control.Command{Kind: cmd.Kind, Value: cmd.Value, Text: cmd.Text})
+			switch cmd.Kind {
+			case control.Toggle:
+				if g.status.State == "playing" {
+					g.status.State = "paused"
+				} else {
+					g.status.State = "playing"
+				}
+			case control.Seek:
+				g.status.Position = cmd.Value
+				g.events.Post(event.Seeked{Position: time.Duration(cmd.Value * float64(time.Second))})
+			}
+			g.hub.Publish(g.status)
+			g.mu.Unlock()
+			cmd.Reply(nil)
+		}
+		g.events.Dispatch()
+	}
+}
+
+func (g *fakeGame) received() []control.Command {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]control.Command(nil), g.commands...)
+}
+
+func TestServer(t *testing.T) {
+	privateBus(t)
+
+	g := &fakeGame{
+		hub:    control.NewHub(),
+		events: event.NewBus(),
+		status: control.Status{
+			State:    "playing",
+			Track:    "/music/song.flac",
+			Position: 10,
+			Duration: 100,
+			Volume:   100,
+			Queue:    []string{"/music/song.flac", "/music/next.flac"},
+		},
+	}
+	g.hub.Publish(g.status)
+	stop := make(chan struct{})
+	defer close(stop)
+	go g.run(stop)
+
+	s, err := Start(g.hub, g.events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	client, err := dbus.ConnectSessionBus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.AddMatchSignal(dbus.WithMatchInterface(playerIface), dbus.WithMatchMember("Seeked")); err != nil {
+		t.Fatal(err)
+	}
+	signals := make(chan *dbus.Signal, 8)
+	client.Signal(signals)
+	obj := client.Object(busName, objectPath)
+
+	v, err := obj.GetProperty(playerIface + ".Metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, _ := v.Value().(map[string]dbus.Variant)
+	if meta["xesam:title"].Value() != "song" || meta["mpris:length"].Value() != int64(100e6) {
+		t.Errorf("metadata %v", meta)
+	}
+
+	if call := obj.Call(playerIface+".PlayPause", 0); call.Err != nil {
+		t.Fatal(call.Err)
+	}
+	// The properties follow the published status
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		v, err := obj.GetProperty(playerIface + ".PlaybackStatus")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.Value() == "Paused" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("playback status %v", v.Value())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Seek moves relative to the position, in microseconds
+	if call := obj.Call(playerIface+".Seek", 0, int64(5e6)); call.Err != nil {
+		t.Fatal(call.Err)
+	}
+	select {
+	case sig := <-signals:
+		if len(sig.Body) != 1 || sig.Body[0] != int64(15e6) {
+			t.Errorf("Seeked %v", sig.Body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no Seeked signal")
+	}
+	// Seeking past the end skips to the next track
+	if call := obj.Call(playerIface+".Seek", 0, int64(200e6)); call.Err != nil {
+		t.Fatal(call.Err)
+	}
+
+	want := []control.Command{{Kind: control.Toggle}, {Kind: control.Seek, Value: 15}, {Kind: control.Next}}
+	got := g.received()
+	if len(got) != len(want) {
+		t.Fatalf("commands %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("command %d: %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
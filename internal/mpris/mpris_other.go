@@ -0,0 +1,22 @@
+//go:build !linux
+
+package mpris
+
+import (
+	"errors"
+
+	"github.com/iburimskiy/audio-visualization/internal/control"
+	"github.com/iburimskiy/audio-visualization/internal/event"
+)
+
+// Server is the MPRIS service. It is only available on Linux.
+type Server struct{}
+
+// Start returns errors.ErrUnsupported outside Linux.
+func Start(hub *control.Hub, events *event.Bus) (*Server, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (s *Server) Close() error {
+	return nil
+}
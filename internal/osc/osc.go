@@ -0,0 +1,286 @@
+// Package osc implements Open Sound Control 1.0 messages and bundles over
+// UDP, for VJ tools like Resolume and TouchDesigner.
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Message is an OSC message. Args hold int32, float32, string, []byte
+// (blob), int64, float64, bool, nil and Impulse values.
+type Message struct {
+	Address string
+	Args    []any
+}
+
+// Impulse is the argument-less "I" type, used as a trigger.
+type Impulse struct{}
+
+// Immediately is the time tag of bundles that apply on receipt.
+const Immediately uint64 = 1
+
+// Bundle groups messages that apply at the same time.
+type Bundle struct {
+	Time     uint64 // NTP time tag
+	Messages []Message
+}
+
+// Float returns argument i as a number, accepting every numeric type.
+func (m *Message) Float(i int) (float64, error) {
+	if i >= len(m.Args) {
+		return 0, fmt.Errorf("%s: missing argument %d", m.Address, i+1)
+	}
+	switch v := m.Args[i].(type) {
+	case int32:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	}
+	return 0, fmt.Errorf("%s: argument %d is not a number", m.Address, i+1)
+}
+
+// String returns argument i as a string.
+func (m *Message) String(i int) (string, error) {
+	if i >= len(m.Args) {
+		return "", fmt.Errorf("%s: missing argument %d", m.Address, i+1)
+	}
+	if s, ok := m.Args[i].(string); ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("%s: argument %d is not a string", m.Address, i+1)
+}
+
+// MarshalBinary encodes m as an OSC packet.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if err := m.encode(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (m *Message) encode(b *bytes.Buffer) error {
+	if !strings.HasPrefix(m.Address, "/") {
+		return fmt.Errorf("address %q does not start with /", m.Address)
+	}
+	tags := []byte{','}
+	var args bytes.Buffer
+	for _, arg := range m.Args {
+		switch v := arg.(type) {
+		case int32:
+			tags = append(tags, 'i')
+			args.Write(binary.BigEndian.AppendUint32(nil, uint32(v)))
+		case float32:
+			tags = append(tags, 'f')
+			args.Write(binary.BigEndian.AppendUint32(nil, math.Float32bits(v)))
+		case string:
+			tags = append(tags, 's')
+			writeString(&args, v)
+		case []byte:
+			tags = append(tags, 'b')
+			args.Write(binary.BigEndian.AppendUint32(nil, uint32(len(v))))
+			args.Write(v)
+			pad(&args, len(v))
+		case int64:
+			tags = append(tags, 'h')
+			args.Write(binary.BigEndian.AppendUint64(nil, uint64(v)))
+		case float64:
+			tags = append(tags, 'd')
+			args.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+		case bool:
+			if v {
+				tags = append(tags, 'T')
+			} else {
+				tags = append(tags, 'F')
+			}
+		case nil:
+			tags = append(tags, 'N')
+		case Impulse:
+			tags = append(tags, 'I')
+		default:
+			return fmt.Errorf("%s: unsupported argument type %T", m.Address, arg)
+		}
+	}
+	writeString(b, m.Address)
+	writeString(b, string(tags))
+	b.Write(args.Bytes())
+	return nil
+}
+
+// MarshalBinary encodes the bundle as an OSC packet.
+func (bd *Bundle) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	writeString(&b, "#bundle")
+	b.Write(binary.BigEndian.AppendUint64(nil, bd.Time))
+	var elem bytes.Buffer
+	for i := range bd.Messages {
+		elem.Reset()
+		if err := bd.Messages[i].encode(&elem); err != nil {
+			return nil, err
+		}
+		b.Write(binary.BigEndian.AppendUint32(nil, uint32(elem.Len())))
+		b.Write(elem.Bytes())
+	}
+	return b.Bytes(), nil
+}
+
+// writeString writes s NUL terminated and padded to a multiple of 4 bytes.
+func writeString(b *bytes.Buffer, s string) {
+	b.WriteString(s)
+	b.WriteByte(0)
+	pad(b, len(s)+1)
+}
+
+func pad(b *bytes.Buffer, n int) {
+	for ; n%4 != 0; n++ {
+		b.WriteByte(0)
+	}
+}
+
+var errShort = errors.New("packet too short")
+
+// Parse decodes a packet into its messages, flattening nested bundles. Time
+// tags are ignored and everything applies immediately.
+func Parse(data []byte) ([]Message, error) {
+	return parse(data, 0)
+}
+
+func parse(data []byte, depth int) ([]Message, error) {
+	if len(data) == 0 || len(data)%4 != 0 {
+		return nil, errors.New("packet size is not a multiple of 4")
+	}
+	if data[0] == '/' {
+		m, err := parseMessage(data)
+		if err != nil {
+			return nil, err
+		}
+		return []Message{m}, nil
+	}
+	if !bytes.HasPrefix(data, []byte("#bundle\x00")) {
+		return nil, errors.New("packet is neither a message nor a bundle")
+	}
+	if depth > 8 {
+		return nil, errors.New("bundles nested too deeply")
+	}
+	if len(data) < 16 {
+		return nil, errShort
+	}
+	var msgs []Message
+	for rest := data[16:]; len(rest) > 0; {
+		if len(rest) < 4 {
+			return nil, errShort
+		}
+		size := int(binary.BigEndian.Uint32(rest))
+		rest = rest[4:]
+		if size > len(rest) {
+			return nil, errShort
+		}
+		inner, err := parse(rest[:size], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, inner...)
+		rest = rest[size:]
+	}
+	return msgs, nil
+}
+
+func parseMessage(data []byte) (Message, error) {
+	address, rest, err := readString(data)
+	if err != nil {
+		return Message{}, err
+	}
+	m := Message{Address: address}
+	// Old implementations may leave out the type tags of messages without
+	// arguments
+	if len(rest) == 0 {
+		return m, nil
+	}
+	tags, rest, err := readString(rest)
+	if err != nil {
+		return Message{}, err
+	}
+	if !strings.HasPrefix(tags, ",") {
+		return Message{}, fmt.Errorf("%s: type tags do not start with ','", address)
+	}
+	for _, tag := range tags[1:] {
+		var arg any
+		switch tag {
+		case 'i', 'f', 'c', 'r', 'm':
+			if len(rest) < 4 {
+				return Message{}, errShort
+			}
+			v := binary.BigEndian.Uint32(rest)
+			rest = rest[4:]
+			switch tag {
+			case 'f':
+				arg = math.Float32frombits(v)
+			default:
+				// Characters, colors and MIDI messages are passed on as their
+				// 32 bits
+				arg = int32(v)
+			}
+		case 'h', 'd', 't':
+			if len(rest) < 8 {
+				return Message{}, errShort
+			}
+			v := binary.BigEndian.Uint64(rest)
+			rest = rest[8:]
+			if tag == 'd' {
+				arg = math.Float64frombits(v)
+			} else {
+				arg = int64(v)
+			}
+		case 's', 'S':
+			arg, rest, err = readString(rest)
+			if err != nil {
+				return Message{}, err
+			}
+		case 'b':
+			if len(rest) < 4 {
+				return Message{}, errShort
+			}
+			size := int(binary.BigEndian.Uint32(rest))
+			padded := (size + 3) &^ 3
+			if size < 0 || padded > len(rest)-4 {
+				return Message{}, errShort
+			}
+			arg = append([]byte(nil), rest[4:4+size]...)
+			rest = rest[4+padded:]
+		case 'T':
+			arg = true
+		case 'F':
+			arg = false
+		case 'N':
+			arg = nil
+		case 'I':
+			arg = Impulse{}
+		default:
+			return Message{}, fmt.Errorf("%s: unsupported type tag %q", address, tag)
+		}
+		m.Args = append(m.Args, arg)
+	}
+	return m, nil
+}
+
+// readString reads a padded OSC string and returns the rest of data.
+func readString(data []byte) (string, []byte, error) {
+	end := bytes.IndexByte(data, 0)
+	if end < 0 {
+		return "", nil, errors.New("string is not terminated")
+	}
+	next := (end + 4) &^ 3
+	if next > len(data) {
+		return "", nil, errShort
+	}
+	return string(data[:end]), data[next:], nil
+}
@@ -0,0 +1,202 @@
+package osc
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iburimskiy/audio-visualization/internal/control"
+)
+
+// The examples of the OSC 1.0 specification.
+var (
+	frequency = []byte{
+		'/', 'o', 's', 'c', 'i', 'l', 'l', 'a', 't', 'o', 'r', '/', '4', '/', 'f', 'r',
+		'e', 'q', 'u', 'e', 'n', 'c', 'y', 0,
+		',', 'f', 0, 0,
+		0x43, 0xdc, 0x00, 0x00,
+	}
+	foo = []byte{
+		'/', 'f', 'o', 'o', 0, 0, 0, 0,
+		',', 'i', 'i', 's', 'f', 'f', 0, 0,
+		0x00, 0x00, 0x03, 0xe8,
+		0xff, 0xff, 0xff, 0xff,
+		'h', 'e', 'l', 'l', 'o', 0, 0, 0,
+		0x3f, 0x9d, 0xf3, 0xb6,
+		0x40, 0xb5, 0xb2, 0x2d,
+	}
+)
+
+func TestSpecExamples(t *testing.T) {
+	for _, tc := range []struct {
+		data []byte
+		msg  Message
+	}{
+		{frequency, Message{Address: "/oscillator/4/frequency", Args: []any{float32(440)}}},
+		{foo, Message{Address: "/foo", Args: []any{int32(1000), int32(-1), "hello", float32(1.234), float32(5.678)}}},
+	} {
+		got, err := tc.msg.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, tc.data) {
+			t.Errorf("%s encoded as\n% x\nwant\n% x", tc.msg.Address, got, tc.data)
+		}
+		msgs, err := Parse(tc.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(msgs, []Message{tc.msg}) {
+			t.Errorf("parsed %+v, want %+v", msgs, tc.msg)
+		}
+	}
+}
+
+func TestBlob(t *testing.T) {
+	data := []byte{
+		'/', 'b', 'l', 'o', 'b', 0, 0, 0,
+		',', 'b', 0, 0,
+		0, 0, 0, 5, 1, 2, 3, 4, 5, 0, 0, 0,
+	}
+	m := Message{Address: "/blob", Args: []any{[]byte{1, 2, 3, 4, 5}}}
+	got, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("encoded as\n% x\nwant\n% x", got, data)
+	}
+	msgs, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(msgs, []Message{m}) {
+		t.Errorf("parsed %+v", msgs)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	a := []byte{'/', 'a', 0, 0, ',', 'i', 0, 0, 0, 0, 0, 1}
+	b := []byte{'/', 'b', 0, 0, ',', 's', 0, 0, 'h', 'i', 0, 0}
+	header := []byte{'#', 'b', 'u', 'n', 'd', 'l', 'e', 0, 0, 0, 0, 0, 0, 0, 0, 1}
+
+	flat := append(append(append([]byte(nil), header...), 0, 0, 0, 12), a...)
+	flat = append(append(flat, 0, 0, 0, 12), b...)
+	bd := Bundle{Time: Immediately, Messages: []Message{
+		{Address: "/a", Args: []any{int32(1)}},
+		{Address: "/b", Args: []any{"hi"}},
+	}}
+	got, err := bd.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, flat) {
+		t.Errorf("encoded as\n% x\nwant\n% x", got, flat)
+	}
+
+	// A bundle holding a message and a bundle with another message
+	inner := append(append(append([]byte(nil), header...), 0, 0, 0, 12), b...)
+	nested := append(append(append([]byte(nil), header...), 0, 0, 0, 12), a...)
+	nested = append(append(nested, 0, 0, 0, byte(len(inner))), inner...)
+	for _, data := range [][]byte{flat, nested} {
+		msgs, err := Parse(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(msgs, bd.Messages) {
+			t.Errorf("parsed %+v", msgs)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	bundle := []byte{'#', 'b', 'u', 'n', 'd', 'l', 'e', 0, 0, 0, 0, 0, 0, 0, 0, 1}
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"misaligned", frequency[:len(frequency)-1]},
+		{"truncated argument", frequency[:len(frequency)-4]},
+		{"truncated arguments", foo[:len(foo)-8]},
+		{"unterminated address", []byte{'/', 'a', 'b', 'c'}},
+		{"no comma", []byte{'/', 'a', 0, 0, 'f', 0, 0, 0}},
+		{"unknown tag", []byte{'/', 'a', 0, 0, ',', 'x', 0, 0, 0, 0, 0, 0}},
+		{"blob too long", []byte{'/', 'a', 0, 0, ',', 'b', 0, 0, 0, 0, 0, 8, 1, 2, 3, 4}},
+		{"neither", []byte{'x', 0, 0, 0}},
+		{"short bundle", bundle[:12]},
+		{"element too long", append(append([]byte(nil), bundle...), 0, 0, 0, 16, '/', 'a', 0, 0)},
+		{"misaligned element", append(append([]byte(nil), bundle...), 0, 0, 0, 3, '/', 'a', 0, 0)},
+		{"element size cut", append(append([]byte(nil), bundle...), 0, 0, 0, 0, 0, 0)},
+	} {
+		if msgs, err := Parse(tc.data); err == nil {
+			t.Errorf("%s: parsed %+v", tc.name, msgs)
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	hub := control.NewHub()
+	s, err := Listen("127.0.0.1:0", hub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error)
+	go func() { served <- s.Serve() }()
+
+	conn, err := net.Dial("udp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	send := func(m Message) {
+		t.Helper()
+		data, err := m.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send(Message{Address: "/next", Args: []any{int32(0)}}) // button release
+	send(Message{Address: "/volume", Args: []any{int32(500)}})
+	send(Message{Address: "/nowhere"})
+	send(Message{Address: "/volume", Args: []any{float32(80)}})
+	send(Message{Address: "/param/saturation", Args: []any{float64(0.5)}})
+
+	want := []control.Command{
+		{Kind: control.Volume, Value: 80},
+		{Kind: control.Param, Text: "saturation", Value: 0.5},
+	}
+	for _, w := range want {
+		deadline := time.Now().Add(2 * time.Second)
+		cmd := hub.Pending()
+		for ; cmd == nil && time.Now().Before(deadline); cmd = hub.Pending() {
+			time.Sleep(time.Millisecond)
+		}
+		if cmd == nil {
+			t.Fatalf("no command, want %+v", w)
+		}
+		if cmd.Kind != w.Kind || cmd.Value != w.Value || cmd.Text != w.Text || !cmd.Posted() {
+			t.Errorf("got %+v, want %+v", *cmd, w)
+		}
+		cmd.Reply(nil)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+	if cmd := hub.Pending(); cmd != nil {
+		t.Errorf("unexpected command %+v", *cmd)
+	}
+	// Both bad messages came from the same sender, which is logged once
+	if len(s.warned) != 1 {
+		t.Errorf("warned %v", s.warned)
+	}
+}
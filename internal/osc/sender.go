@@ -0,0 +1,78 @@
+package osc
+
+import (
+	"errors"
+	"net"
+
+	"github.com/iburimskiy/audio-visualization/internal/feed"
+)
+
+// Sender sends the analysis of every frame to a list of destinations as one
+// bundle of:
+//
+//	<prefix>/bands     one float per band, 0 to 1
+//	<prefix>/rms       float, 0 to 1
+//	<prefix>/bpm       float, 0 if unknown
+//	<prefix>/position  float, seconds into the track
+//	<prefix>/beat      int 1, only in frames with a beat
+type Sender struct {
+	prefix string
+	conn   net.PacketConn
+	dests  []net.Addr
+	args   []any
+}
+
+// NewSender resolves the host:port destinations.
+func NewSender(prefix string, dests []string) (*Sender, error) {
+	s := &Sender{prefix: prefix}
+	for _, d := range dests {
+		addr, err := net.ResolveUDPAddr("udp", d)
+		if err != nil {
+			return nil, err
+		}
+		s.dests = append(s.dests, addr)
+	}
+	conn, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return s, nil
+}
+
+// Send sends f to every destination. UDP does not wait for the receiver, so
+// this never blocks for long; errors such as unreachable ports are returned
+// but may be ignored.
+func (s *Sender) Send(f feed.Frame) error {
+	s.args = s.args[:0]
+	for _, v := range f.Bands {
+		s.args = append(s.args, v)
+	}
+	b := Bundle{
+		Time: Immediately,
+		Messages: []Message{
+			{Address: s.prefix + "/bands", Args: s.args},
+			{Address: s.prefix + "/rms", Args: []any{float32(f.RMS)}},
+			{Address: s.prefix + "/bpm", Args: []any{float32(f.BPM)}},
+			{Address: s.prefix + "/position", Args: []any{float32(f.Position)}},
+		},
+	}
+	if f.Beat {
+		b.Messages = append(b.Messages, Message{Address: s.prefix + "/beat", Args: []any{int32(1)}})
+	}
+	packet, err := b.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, d := range s.dests {
+		if _, err := s.conn.WriteTo(packet, d); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Sender) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,130 @@
+package osc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/iburimskiy/audio-visualization/internal/control"
+)
+
+// maxWarned bounds the senders remembered for logging, so a flood of
+// packets from many addresses cannot grow the server without end.
+const maxWarned = 256
+
+// Server receives control messages over UDP:
+//
+//	/play, /pause, /toggle, /next, /previous
+//	/seek <seconds>
+//	/volume <percent>
+//	/scene <names joined with "+">
+//	/param/<name> <value>    e.g. /param/rotation_speed 2.5
+//
+// Buttons that send 1 on press and 0 on release only act on the press.
+// Commands are queued for the game without waiting for it, so a controller
+// streaming fader moves is never held up by a busy frame.
+type Server struct {
+	conn   net.PacketConn
+	hub    *control.Hub
+	warned map[string]bool // senders whose bad packets were logged
+}
+
+// Listen opens the UDP port at addr.
+func Listen(addr string, hub *control.Hub) (*Server, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{conn: conn, hub: hub, warned: make(map[string]bool)}, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Serve handles packets until the server is closed. Invalid messages are
+// skipped, and the first one from every sender is logged; commands the game
+// fails to carry out are shown in its status line.
+func (s *Server) Serve() error {
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := s.conn.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		msgs, err := Parse(buf[:n])
+		if err != nil {
+			s.warn(from, err)
+			continue
+		}
+		for i := range msgs {
+			if err := s.handle(&msgs[i]); err != nil {
+				s.warn(from, fmt.Errorf("%s: %w", msgs[i].Address, err))
+			}
+		}
+	}
+}
+
+func (s *Server) Close() error {
+	return s.conn.Close()
+}
+
+func (s *Server) handle(m *Message) error {
+	cmd, ok, err := command(m)
+	if err != nil || !ok {
+		return err
+	}
+	return s.hub.Post(cmd)
+}
+
+// warn logs the first error of every sender. A misconfigured controller
+// sends the same bad message many times a second.
+func (s *Server) warn(from net.Addr, err error) {
+	key := from.String()
+	if s.warned[key] || len(s.warned) >= maxWarned {
+		return
+	}
+	s.warned[key] = true
+	fmt.Printf("OSC from %v: %v (further errors from it are not logged)\n", from, err)
+}
+
+// command translates m. It reports false for messages to ignore, like button
+// releases.
+func command(m *Message) (control.Command, bool, error) {
+	if name, ok := strings.CutPrefix(m.Address, "/param/"); ok {
+		v, err := m.Float(0)
+		return control.Command{Kind: control.Param, Text: name, Value: v}, err == nil, err
+	}
+	switch m.Address {
+	case "/play", "/pause", "/toggle", "/next", "/previous":
+		if v, err := m.Float(0); err == nil && v == 0 {
+			return control.Command{}, false, nil
+		}
+		kind := map[string]control.Kind{
+			"/play": control.Play, "/pause": control.Pause, "/toggle": control.Toggle,
+			"/next": control.Next, "/previous": control.Previous,
+		}[m.Address]
+		return control.Command{Kind: kind}, true, nil
+	case "/seek":
+		v, err := m.Float(0)
+		if err == nil && v < 0 {
+			err = errors.New("position is negative")
+		}
+		return control.Command{Kind: control.Seek, Value: v}, err == nil, err
+	case "/volume":
+		v, err := m.Float(0)
+		if err == nil && (v < 0 || v > 200) {
+			err = fmt.Errorf("volume %g is out of range (0 to 200)", v)
+		}
+		return control.Command{Kind: control.Volume, Value: v}, err == nil, err
+	case "/scene":
+		names, err := m.String(0)
+		return control.Command{Kind: control.Scene, Text: names}, err == nil, err
+	}
+	return control.Command{}, false, errors.New("unknown address")
+}
@@ -0,0 +1,26 @@
+package palette
+
+import "testing"
+
+func TestHSVToRGB(t *testing.T) {
+	for _, tc := range []struct {
+		h       float64
+		r, g, b uint8
+	}{
+		{0, 255, 0, 0},
+		{120, 0, 255, 0},
+		{240, 0, 0, 255},
+		{360, 255, 0, 0},
+		{480, 0, 255, 0},
+		// Negative hues, as a negative color_shift_speed produces
+		{-120, 0, 0, 255},
+		{-240, 0, 255, 0},
+		{-360, 255, 0, 0},
+		{-60, 255, 0, 255},
+		{-1e-12, 255, 0, 0},
+	} {
+		if r, g, b := HSVToRGB(tc.h, 1, 1); r != tc.r || g != tc.g || b != tc.b {
+			t.Errorf("hue %g: %d %d %d, want %d %d %d", tc.h, r, g, b, tc.r, tc.g, tc.b)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package player
+
+import (
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// Output pulls the mixed audio of a player, such as the speaker.
+type Output interface {
+	// Play starts pulling from s at rate. The player calls it once, when the
+	// first source is opened.
+	Play(rate beep.SampleRate, s beep.Streamer) error
+	// Lock and Unlock keep the output from pulling while the chain changes.
+	Lock()
+	Unlock()
+}
+
+// Null is an output without a device. Nothing is pulled until Pump is
+// called, so the visuals can follow the music at the pace of a clock
+// without playing sound.
+type Null struct {
+	mu    sync.Mutex
+	s     beep.Streamer
+	rate  beep.SampleRate
+	carry float64 // fraction of a sample left over between pumps
+}
+
+func (n *Null) Play(rate beep.SampleRate, s beep.Streamer) error {
+	n.mu.Lock()
+	n.s, n.rate = s, rate
+	n.mu.Unlock()
+	return nil
+}
+
+func (n *Null) Lock()   { n.mu.Lock() }
+func (n *Null) Unlock() { n.mu.Unlock() }
+
+// Pump pulls and discards d worth of samples.
+func (n *Null) Pump(d time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.s == nil {
+		return
+	}
+	want := d.Seconds()*float64(n.rate) + n.carry
+	count := int(want)
+	n.carry = want - float64(count)
+
+	var buf [512][2]float64
+	for count > 0 {
+		chunk := buf[:min(count, len(buf))]
+		got, ok := n.s.Stream(chunk)
+		count -= got
+		if !ok {
+			return
+		}
+	}
+}
@@ -0,0 +1,513 @@
+// Package player plays audio sources. A Player owns the decoder, the beep
+// chain from the source to the output and the playback state, and changes
+// them only on its own goroutine: the game loop, remote controls and the
+// audio device send commands and read immutable State snapshots, so none of
+// them share mutable state. What happens is also posted as events.
+package player
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/effects"
+
+	"github.com/iburimskiy/audio-visualization/internal/analysis"
+	"github.com/iburimskiy/audio-visualization/internal/audio"
+	"github.com/iburimskiy/audio-visualization/internal/event"
+)
+
+// Kind selects what a command does.
+type Kind int
+
+const (
+	Open   Kind = iota // play Track or Source, replacing the current one
+	Stop               // close the current source
+	Pause              // pause; Resume continues
+	Resume             // continue after Pause
+	Toggle             // pause or resume
+	Seek               // move to Position
+	Gain               // set the volume to Gain; 0 mutes
+)
+
+// Command is one request for the player.
+type Command struct {
+	Kind Kind
+
+	// Track is the file, playlist entry or URL Open plays. It is opened in
+	// the background, so a slow server does not hold up the caller; the
+	// current source plays until it is ready, and TrackLoaded or TrackFailed
+	// is posted. A Source that is already open, such as live input, is
+	// played right away instead, and closed on failure.
+	Track  string
+	Source audio.Source
+	// RingSize is how many of the latest samples of the opened source
+	// Samples can return.
+	RingSize int
+	// Monitor plays a live source; otherwise live input is only visualized,
+	// so a microphone does not feed back.
+	Monitor bool
+	// Waveform is the number of columns of the overview computed for files,
+	// or 0 for none.
+	Waveform int
+
+	// Position is where Seek goes, and where Open starts.
+	Position time.Duration
+	Gain     float64
+
+	done chan error
+}
+
+// State is a snapshot of the player.
+type State struct {
+	Serial   int    // counts opened sources, so a state can be told from one of an earlier source
+	Track    string // path or URL of the source
+	Opening  string // track being opened while the source plays on
+	Title    string // from stream metadata
+	Loaded   bool   // a source is open
+	Live     bool   // the source has no length and cannot seek
+	Paused   bool
+	Ended    bool            // the source played to its end
+	Format   beep.Format     // of the source
+	Rate     beep.SampleRate // of the output; 0 until the first source is opened
+	Duration time.Duration   // 0 for live input
+	Position time.Duration   // the target of a seek still pending
+	Gain     float64
+	Peaks    []analysis.Peak // waveform overview of the track, once computed
+
+	start   time.Duration // position at the last seek
+	target  time.Duration
+	seeking bool
+	played  *atomic.Int64 // output samples since the last seek
+	titler  audio.Titler
+	tap     *visualTap
+}
+
+// seekInterval is the shortest time between two seeks of the decoder.
+// Requests in between are coalesced, so dragging the progress bar or holding
+// an arrow key does not stall the audio with back-to-back seeks.
+const seekInterval = 50 * time.Millisecond
+
+// ErrClosed is returned for commands sent after Close.
+var ErrClosed = errors.New("player is closed")
+
+// Player plays one source at a time through an Output. Its methods are safe
+// for concurrent use.
+type Player struct {
+	out      Output
+	events   *event.Bus
+	mixer    *beep.Mixer // sources currently playing
+	output   *recordTap  // everything heard, pulled by out
+	commands chan *Command
+	ended    chan int // serial of a source that finished, sent from the output
+	opened   chan opened
+	peaks    chan trackPeaks
+	state    atomic.Pointer[State]
+	quit     chan struct{}
+	done     chan struct{}
+	closeErr error
+
+	// Owned by run
+	cur      State
+	opening  int // counts Open commands, so a superseded open is dropped
+	src      audio.Source
+	ctrl     *beep.Ctrl
+	volume   *effects.Volume
+	monitor  bool
+	lastSeek time.Time
+	seekAt   <-chan time.Time
+}
+
+// New starts a player that plays through out at gain and posts what
+// happens to events, which may be nil.
+func New(out Output, gain float64, events *event.Bus) *Player {
+	p := &Player{
+		out:      out,
+		events:   events,
+		mixer:    &beep.Mixer{},
+		commands: make(chan *Command),
+		ended:    make(chan int, 1),
+		opened:   make(chan opened),
+		peaks:    make(chan trackPeaks),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	p.output = &recordTap{Source: p.mixer}
+	p.cur.Gain = gain
+	p.publish()
+	go p.run()
+	return p
+}
+
+// Do sends cmd to the player and waits until it is carried out.
+func (p *Player) Do(cmd Command) error {
+	cmd.done = make(chan error, 1)
+	select {
+	case p.commands <- &cmd:
+	case <-p.done:
+		if cmd.Kind == Open && cmd.Source != nil {
+			_ = cmd.Source.Close()
+		}
+		return ErrClosed
+	}
+	return <-cmd.done
+}
+
+// State returns a snapshot of the player.
+func (p *Player) State() State {
+	s := *p.state.Load()
+	s.Position = s.position()
+	if s.titler != nil {
+		s.Title = s.titler.Title()
+	}
+	return s
+}
+
+// position returns where playback is: the target of a pending seek, or
+// where the last seek went plus what has been played since.
+func (s *State) position() time.Duration {
+	switch {
+	case s.seeking:
+		return s.target
+	case s.played != nil:
+		pos := s.start + s.Rate.D(int(s.played.Load()))
+		if !s.Live {
+			pos = min(pos, s.Duration)
+		}
+		return pos
+	}
+	return s.Position
+}
+
+// Samples returns up to the last n samples played from the current source,
+// oldest first.
+func (p *Player) Samples(n int) [][2]float64 {
+	if t := p.state.Load().tap; t != nil {
+		return t.snapshot(n)
+	}
+	return nil
+}
+
+// AddSink hands everything played from now on, after volume and mixing, to
+// s. The output keeps running across sources, so a sink spans track changes.
+func (p *Player) AddSink(s Sink) {
+	p.output.add(s)
+}
+
+// RemoveSink stops writing to s.
+func (p *Player) RemoveSink(s Sink) {
+	p.output.remove(s)
+}
+
+// Close stops playback and closes the current source.
+func (p *Player) Close() error {
+	select {
+	case <-p.quit:
+	default:
+		close(p.quit)
+	}
+	<-p.done
+	return p.closeErr
+}
+
+func (p *Player) run() {
+	defer close(p.done)
+	for {
+		select {
+		case cmd := <-p.commands:
+			// The sender sees the new state as soon as Do returns
+			err := p.handle(cmd)
+			p.publish()
+			cmd.done <- err
+			continue
+		case serial := <-p.ended:
+			p.finish(serial)
+		case o := <-p.opened:
+			p.finishOpen(o)
+		case pk := <-p.peaks:
+			if pk.serial == p.cur.Serial {
+				p.cur.Peaks = pk.peaks
+			}
+		case <-p.seekAt:
+			p.seekAt = nil
+			if err := p.applySeek(); err != nil {
+				p.events.Post(event.Error{Err: err})
+			}
+		case <-p.quit:
+			p.closeErr = p.stop()
+			p.publish()
+			return
+		}
+		p.publish()
+	}
+}
+
+// publish replaces the snapshot returned by State.
+func (p *Player) publish() {
+	s := p.cur
+	p.state.Store(&s)
+}
+
+func (p *Player) handle(cmd *Command) error {
+	switch cmd.Kind {
+	case Open:
+		p.opening++
+		p.cur.Opening = ""
+		if cmd.Source != nil {
+			return p.open(cmd, cmd.Source)
+		}
+		p.cur.Opening = cmd.Track
+		go p.load(p.opening, *cmd)
+	case Stop:
+		p.opening++
+		err := p.stop()
+		p.cur = State{Serial: p.cur.Serial, Rate: p.cur.Rate, Gain: p.cur.Gain}
+		return err
+	case Pause, Resume, Toggle:
+		if p.ctrl == nil {
+			return nil
+		}
+		paused := cmd.Kind == Pause || (cmd.Kind == Toggle && !p.cur.Paused)
+		if paused == p.cur.Paused {
+			return nil
+		}
+		p.out.Lock()
+		p.ctrl.Paused = paused
+		p.out.Unlock()
+		p.cur.Paused = paused
+		if paused {
+			p.events.Post(event.Paused{Position: p.cur.position()})
+		} else {
+			p.events.Post(event.Resumed{Position: p.cur.position()})
+		}
+	case Seek:
+		return p.seek(cmd.Position)
+	case Gain:
+		p.cur.Gain = cmd.Gain
+		p.applyGain()
+	default:
+		return errors.New("unknown command")
+	}
+	return nil
+}
+
+// opened is the outcome of opening a track in the background.
+type opened struct {
+	id  int // of the Open command
+	cmd Command
+	src audio.Source
+	err error
+}
+
+// load opens cmd.Track and hands the result to run.
+func (p *Player) load(id int, cmd Command) {
+	o := opened{id: id, cmd: cmd}
+	o.src, o.err = audio.OpenSource(cmd.Track)
+	select {
+	case p.opened <- o:
+	case <-p.quit:
+		if o.src != nil {
+			_ = o.src.Close()
+		}
+	}
+}
+
+// finishOpen plays a track opened in the background, unless another Open
+// or a Stop came in meanwhile.
+func (p *Player) finishOpen(o opened) {
+	if o.id != p.opening {
+		if o.src != nil {
+			_ = o.src.Close()
+		}
+		return
+	}
+	p.cur.Opening = ""
+	err := o.err
+	if err == nil {
+		err = p.open(&o.cmd, o.src)
+	}
+	if err != nil {
+		p.events.Post(event.TrackFailed{Track: o.cmd.Track, Err: err})
+	}
+}
+
+// open makes src the playing source. The output is started with the first
+// source; later sources are resampled to its rate and mixed into the same
+// output, so sinks span track changes.
+func (p *Player) open(cmd *Command, src audio.Source) error {
+	format := src.Format()
+	if p.cur.Rate == 0 {
+		if err := p.out.Play(format.SampleRate, p.output); err != nil {
+			_ = src.Close()
+			return err
+		}
+		p.cur.Rate = format.SampleRate
+	}
+	_ = p.stop()
+
+	// Prepare audio chain: source -> resample -> tap -> ctrl -> volume
+	var s beep.Streamer = src
+	if format.SampleRate != p.cur.Rate {
+		s = beep.Resample(4, format.SampleRate, p.cur.Rate, src)
+	}
+	played := new(atomic.Int64)
+	tap := newVisualTap(s, cmd.RingSize, played)
+	titler, _ := src.(audio.Titler)
+	p.src = src
+	p.ctrl = &beep.Ctrl{Streamer: tap}
+	p.monitor = cmd.Monitor
+	p.lastSeek = time.Time{}
+	p.cur = State{
+		Serial:   p.cur.Serial + 1,
+		Track:    cmd.Track,
+		Loaded:   true,
+		Live:     src.Live(),
+		Format:   format,
+		Rate:     p.cur.Rate,
+		Duration: format.SampleRate.D(src.Len()),
+		Gain:     p.cur.Gain,
+		played:   played,
+		titler:   titler,
+		tap:      tap,
+	}
+	p.volume = &effects.Volume{Streamer: p.ctrl, Base: 2}
+	p.applyGain()
+
+	// The callback runs on the output's goroutine, so it only reports the
+	// end and run updates the state
+	serial := p.cur.Serial
+	p.out.Lock()
+	p.mixer.Add(beep.Seq(p.volume, beep.Callback(func() {
+		select {
+		case p.ended <- serial:
+		default:
+		}
+	})))
+	p.out.Unlock()
+	p.events.Post(event.TrackLoaded{Serial: serial, Track: cmd.Track, Live: p.cur.Live, Duration: p.cur.Duration})
+
+	if cmd.Waveform > 0 && !p.cur.Live {
+		go p.loadPeaks(serial, cmd.Track, cmd.Waveform)
+	}
+	if cmd.Position > 0 && !p.cur.Live {
+		if err := p.seek(cmd.Position); err != nil {
+			p.events.Post(event.Error{Err: err})
+		}
+	}
+	return nil
+}
+
+// trackPeaks is a waveform overview computed in the background.
+type trackPeaks struct {
+	serial int // of the source the peaks belong to
+	peaks  []analysis.Peak
+}
+
+// loadPeaks decodes the track a second time to build its waveform overview,
+// without holding up playback.
+func (p *Player) loadPeaks(serial int, path string, columns int) {
+	s, _, err := audio.Open(path)
+	if err != nil {
+		return
+	}
+	defer s.Close()
+	peaks, err := analysis.Peaks(s, s.Len(), columns)
+	if err != nil {
+		return
+	}
+	select {
+	case p.peaks <- trackPeaks{serial: serial, peaks: peaks}:
+	case <-p.quit:
+	}
+}
+
+// stop removes the source from the mixer and closes it; the output keeps
+// running.
+func (p *Player) stop() error {
+	p.out.Lock()
+	p.mixer.Clear()
+	p.out.Unlock()
+	p.ctrl, p.volume, p.seekAt = nil, nil, nil
+	if p.src == nil {
+		return nil
+	}
+	err := p.src.Close()
+	p.src = nil
+	return err
+}
+
+// finish marks the source that sent serial as ended, unless it was replaced
+// before the end was picked up.
+func (p *Player) finish(serial int) {
+	if serial != p.cur.Serial || p.src == nil {
+		return
+	}
+	p.cur.Ended = true
+	// A seek still waiting for its turn would hold the position at its target
+	p.cur.seeking, p.seekAt = false, nil
+	p.events.Post(event.TrackEnded{Serial: serial, Track: p.cur.Track})
+	if err := p.src.Err(); err != nil {
+		p.events.Post(event.Error{Err: err})
+	}
+}
+
+// seek moves the position shown right away. The decoder follows at most
+// every seekInterval, so only the latest of several quick requests is
+// carried out.
+func (p *Player) seek(at time.Duration) error {
+	if p.src == nil {
+		return nil
+	}
+	if p.cur.Live {
+		return errors.New("cannot seek a live stream")
+	}
+	last := p.cur.Format.SampleRate.D(max(p.src.Len()-1, 0))
+	p.cur.target = min(max(at, 0), last)
+	p.cur.seeking = true
+	if wait := seekInterval - time.Since(p.lastSeek); wait > 0 {
+		if p.seekAt == nil {
+			p.seekAt = time.After(wait)
+		}
+		return nil
+	}
+	return p.applySeek()
+}
+
+// applySeek seeks the decoder to the pending target.
+func (p *Player) applySeek() error {
+	if !p.cur.seeking || p.src == nil {
+		return nil
+	}
+	p.lastSeek = time.Now()
+	// Show the target until the decoder is there
+	p.publish()
+
+	pos := max(min(p.cur.Format.SampleRate.N(p.cur.target), p.src.Len()-1), 0)
+	p.out.Lock()
+	err := p.src.Seek(pos)
+	if err == nil {
+		p.cur.played.Store(0)
+	}
+	p.out.Unlock()
+	p.cur.seeking = false
+	if err != nil {
+		return err
+	}
+	p.cur.start = p.cur.Format.SampleRate.D(pos)
+	p.events.Post(event.Seeked{Position: p.cur.start})
+	return nil
+}
+
+// applyGain updates the volume effect. It is silent at zero gain and for
+// live input that is only visualized.
+func (p *Player) applyGain() {
+	if p.volume == nil {
+		return
+	}
+	p.out.Lock()
+	p.volume.Volume = math.Log2(p.cur.Gain)
+	p.volume.Silent = p.cur.Gain <= 0 || (p.cur.Live && !p.monitor)
+	p.out.Unlock()
+}
@@ -0,0 +1,387 @@
+package player
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/wav"
+
+	"github.com/iburimskiy/audio-visualization/internal/event"
+)
+
+// testRate is the sample rate of fake sources, so one sample is a
+// millisecond.
+const testRate = 1000
+
+// fakeSource plays n samples of a constant level.
+type fakeSource struct {
+	mu     sync.Mutex
+	n      int
+	pos    int
+	closed bool
+}
+
+func (f *fakeSource) Stream(samples [][2]float64) (int, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.pos >= f.n {
+		return 0, false
+	}
+	k := min(len(samples), f.n-f.pos)
+	for i := range k {
+		samples[i] = [2]float64{0.5, -0.5}
+	}
+	f.pos += k
+	return k, true
+}
+
+func (f *fakeSource) Err() error { return nil }
+func (f *fakeSource) Len() int   { return f.n }
+func (f *fakeSource) Live() bool { return false }
+
+func (f *fakeSource) Format() beep.Format {
+	return beep.Format{SampleRate: testRate, NumChannels: 2, Precision: 2}
+}
+
+func (f *fakeSource) Position() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pos
+}
+
+func (f *fakeSource) Seek(p int) error {
+	f.mu.Lock()
+	f.pos = p
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeSource) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeSource) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+// newTestPlayer starts a player on a Null output that the test pumps, with
+// readers polling the state concurrently so the race detector sees them.
+func newTestPlayer(t *testing.T) (*Player, *Null, *event.Bus) {
+	t.Helper()
+	out := &Null{}
+	bus := event.NewBus()
+	p := New(out, 1, bus)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					_ = p.State()
+					_ = p.Samples(256)
+				}
+			}
+		}()
+	}
+	t.Cleanup(func() {
+		close(stop)
+		wg.Wait()
+		if err := p.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	return p, out, bus
+}
+
+func open(t *testing.T, p *Player, src *fakeSource) {
+	t.Helper()
+	if err := p.Do(Command{Kind: Open, Source: src, Track: "test.wav", RingSize: 256}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitFor polls the state until ok accepts it.
+func waitFor(t *testing.T, p *Player, what string, ok func(State) bool) State {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s := p.State()
+		if ok(s) {
+			return s
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s: %+v", what, s)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// events returns the events posted so far.
+func events(bus *event.Bus) []event.Event {
+	var got []event.Event
+	cancel := bus.Subscribe(func(e event.Event) { got = append(got, e) })
+	defer cancel()
+	bus.Dispatch()
+	return got
+}
+
+func TestOpen(t *testing.T) {
+	p, out, bus := newTestPlayer(t)
+	src := &fakeSource{n: 2000}
+	open(t, p, src)
+
+	s := p.State()
+	if !s.Loaded || s.Serial != 1 || s.Track != "test.wav" || s.Duration != 2*time.Second || s.Rate != testRate {
+		t.Fatalf("state after open: %+v", s)
+	}
+	out.Pump(300 * time.Millisecond)
+	if s := p.State(); s.Position != 300*time.Millisecond {
+		t.Errorf("position after 300ms: %v", s.Position)
+	}
+	if got := p.Samples(100); len(got) != 100 || got[99] != [2]float64{0.5, -0.5} {
+		t.Errorf("samples: %v", got)
+	}
+
+	next := &fakeSource{n: 500}
+	open(t, p, next)
+	if !src.isClosed() {
+		t.Error("replaced source not closed")
+	}
+	if s := p.State(); s.Serial != 2 || s.Position != 0 {
+		t.Errorf("state after second open: %+v", s)
+	}
+	if err := p.Do(Command{Kind: Stop}); err != nil {
+		t.Fatal(err)
+	}
+	if s := p.State(); s.Loaded || !next.isClosed() {
+		t.Errorf("state after stop: %+v", s)
+	}
+
+	got := events(bus)
+	if len(got) != 2 || got[0] != (event.TrackLoaded{Serial: 1, Track: "test.wav", Duration: 2 * time.Second}) {
+		t.Errorf("events: %v", got)
+	}
+}
+
+// writeWAV writes n samples of a fake source to a WAV file.
+func writeWAV(t *testing.T, n int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "track.wav")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	src := &fakeSource{n: n}
+	if err := wav.Encode(f, src, src.Format()); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpenTrack(t *testing.T) {
+	p, out, bus := newTestPlayer(t)
+	path := writeWAV(t, 3000)
+	if err := p.Do(Command{Kind: Open, Track: path, RingSize: 256, Waveform: 10, Position: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	s := waitFor(t, p, "open", func(s State) bool { return s.Loaded && len(s.Peaks) > 0 })
+	if s.Track != path || s.Opening != "" || s.Duration != 3*time.Second || s.Position != time.Second || len(s.Peaks) != 10 {
+		t.Fatalf("state after open: %+v", s)
+	}
+	out.Pump(500 * time.Millisecond)
+	if s := p.State(); s.Position != 1500*time.Millisecond {
+		t.Errorf("position: %v", s.Position)
+	}
+
+	// A track that fails to open leaves the current one playing. The server
+	// holds the request so the track is seen being opened.
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+	missing := srv.URL + "/missing.flac"
+	if err := p.Do(Command{Kind: Open, Track: missing}); err != nil {
+		t.Fatal(err)
+	}
+	if s := p.State(); s.Opening != missing || s.Track != path {
+		t.Errorf("opening: %q, playing %q", s.Opening, s.Track)
+	}
+	close(release)
+	waitFor(t, p, "failure", func(s State) bool { return s.Opening == "" })
+	if s := p.State(); !s.Loaded || s.Track != path {
+		t.Errorf("state after failed open: %+v", s)
+	}
+	var failed []event.TrackFailed
+	for _, e := range events(bus) {
+		if e, ok := e.(event.TrackFailed); ok {
+			failed = append(failed, e)
+		}
+	}
+	if len(failed) != 1 || failed[0].Track != missing || failed[0].Err == nil {
+		t.Errorf("failures: %v", failed)
+	}
+
+	// Stop drops a track still being opened
+	if err := p.Do(Command{Kind: Open, Track: path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Do(Command{Kind: Stop}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if s := p.State(); s.Loaded || s.Opening != "" {
+		t.Errorf("state after stop: %+v", s)
+	}
+}
+
+func TestSeek(t *testing.T) {
+	p, out, bus := newTestPlayer(t)
+	src := &fakeSource{n: 10000}
+	open(t, p, src)
+
+	// The first seek goes through right away
+	if err := p.Do(Command{Kind: Seek, Position: 5 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if pos := src.Position(); pos != 5000 {
+		t.Fatalf("decoder at %d after seek", pos)
+	}
+
+	// Quick seeks are coalesced, showing the target meanwhile
+	for _, at := range []time.Duration{2 * time.Second, 3 * time.Second} {
+		if err := p.Do(Command{Kind: Seek, Position: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := p.State(); s.Position != 3*time.Second || src.Position() != 5000 {
+		t.Fatalf("pending seek: position %v, decoder at %d", s.Position, src.Position())
+	}
+	waitFor(t, p, "coalesced seek", func(State) bool { return src.Position() == 3000 })
+	out.Pump(100 * time.Millisecond)
+	if s := p.State(); s.Position != 3100*time.Millisecond {
+		t.Errorf("position after seek and play: %v", s.Position)
+	}
+
+	var seeks []time.Duration
+	for _, e := range events(bus) {
+		if e, ok := e.(event.Seeked); ok {
+			seeks = append(seeks, e.Position)
+		}
+	}
+	if len(seeks) != 2 || seeks[0] != 5*time.Second || seeks[1] != 3*time.Second {
+		t.Errorf("seeked events: %v", seeks)
+	}
+}
+
+func TestPause(t *testing.T) {
+	p, out, bus := newTestPlayer(t)
+	src := &fakeSource{n: 10000}
+	open(t, p, src)
+	out.Pump(100 * time.Millisecond)
+
+	if err := p.Do(Command{Kind: Toggle}); err != nil {
+		t.Fatal(err)
+	}
+	out.Pump(100 * time.Millisecond)
+	if s := p.State(); !s.Paused || s.Position != 100*time.Millisecond || src.Position() != 100 {
+		t.Fatalf("paused: %+v, decoder at %d", s, src.Position())
+	}
+	// Pausing again changes nothing
+	if err := p.Do(Command{Kind: Pause}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Do(Command{Kind: Resume}); err != nil {
+		t.Fatal(err)
+	}
+	out.Pump(100 * time.Millisecond)
+	if s := p.State(); s.Paused || s.Position != 200*time.Millisecond {
+		t.Errorf("resumed: %+v", s)
+	}
+
+	var got []event.Event
+	for _, e := range events(bus) {
+		if _, ok := e.(event.TrackLoaded); !ok {
+			got = append(got, e)
+		}
+	}
+	want := []event.Event{event.Paused{Position: 100 * time.Millisecond}, event.Resumed{Position: 100 * time.Millisecond}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("events: %v, want %v", got, want)
+	}
+}
+
+func TestEnd(t *testing.T) {
+	p, out, bus := newTestPlayer(t)
+	open(t, p, &fakeSource{n: 1000})
+	out.Pump(2 * time.Second)
+
+	s := waitFor(t, p, "end", func(s State) bool { return s.Ended })
+	if s.Position != time.Second {
+		t.Errorf("position at end: %v", s.Position)
+	}
+	got := events(bus)
+	if len(got) != 2 || got[1] != (event.TrackEnded{Serial: 1, Track: "test.wav"}) {
+		t.Errorf("events: %v", got)
+	}
+
+	// The end of a replaced source is not reported
+	open(t, p, &fakeSource{n: 1000})
+	if s := p.State(); s.Ended || s.Serial != 2 {
+		t.Errorf("state after reopening: %+v", s)
+	}
+}
+
+func TestEndWithSeekPending(t *testing.T) {
+	p, out, _ := newTestPlayer(t)
+	open(t, p, &fakeSource{n: 1000})
+	for _, at := range []time.Duration{980 * time.Millisecond, 490 * time.Millisecond} {
+		if err := p.Do(Command{Kind: Seek, Position: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The track ends before the second seek gets its turn
+	out.Pump(100 * time.Millisecond)
+
+	s := waitFor(t, p, "end", func(s State) bool { return s.Ended })
+	if s.Position != time.Second {
+		t.Errorf("position at end: %v", s.Position)
+	}
+	time.Sleep(2 * seekInterval)
+	if s := p.State(); s.Position != time.Second {
+		t.Errorf("position after the seek interval: %v", s.Position)
+	}
+}
+
+func TestClosed(t *testing.T) {
+	p := New(&Null{}, 1, nil)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	src := &fakeSource{n: 10}
+	if err := p.Do(Command{Kind: Open, Source: src}); err != ErrClosed {
+		t.Errorf("open after close: %v", err)
+	}
+	if !src.isClosed() {
+		t.Error("source not closed")
+	}
+}
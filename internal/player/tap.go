@@ -1,24 +1,29 @@
-package game
+package player
 
 import (
+	"slices"
 	"sync"
+	"sync/atomic"
 
 	"github.com/faiface/beep"
 )
 
 // visualTap wraps a beep.Streamer and records the last N samples into a ring buffer
-// so the renderer can draw a visualization from recently played audio.
+// so the renderer can draw a visualization from recently played audio. It
+// also counts the samples passed through, which gives the position.
 type visualTap struct {
 	Source    beep.Streamer
 	buffer    [][2]float64
 	nextIndex int
+	played    *atomic.Int64
 	mu        sync.RWMutex
 }
 
-func newVisualTap(src beep.Streamer, ringSize int) *visualTap {
+func newVisualTap(src beep.Streamer, ringSize int, played *atomic.Int64) *visualTap {
 	return &visualTap{
 		Source: src,
-		buffer: make([][2]float64, ringSize),
+		buffer: make([][2]float64, max(ringSize, 1)),
+		played: played,
 	}
 }
 
@@ -34,6 +39,7 @@ func (t *visualTap) Stream(samples [][2]float64) (int, bool) {
 			}
 		}
 		t.mu.Unlock()
+		t.played.Add(int64(n))
 	}
 	return n, ok
 }
@@ -67,3 +73,44 @@ func (t *visualTap) snapshot(n int) [][2]float64 {
 	}
 	return out
 }
+
+// Sink receives the audio as it is played, such as a recorder.
+type Sink interface {
+	Write(samples [][2]float64)
+}
+
+// recordTap sits at the end of the audio chain and hands everything that is
+// played, after volume and mixing, to the sinks.
+type recordTap struct {
+	Source beep.Streamer
+	mu     sync.Mutex
+	sinks  []Sink
+}
+
+func (t *recordTap) Stream(samples [][2]float64) (int, bool) {
+	n, ok := t.Source.Stream(samples)
+	t.mu.Lock()
+	if n > 0 {
+		for _, s := range t.sinks {
+			s.Write(samples[:n])
+		}
+	}
+	t.mu.Unlock()
+	return n, ok
+}
+
+func (t *recordTap) Err() error {
+	return t.Source.Err()
+}
+
+func (t *recordTap) add(s Sink) {
+	t.mu.Lock()
+	t.sinks = append(t.sinks, s)
+	t.mu.Unlock()
+}
+
+func (t *recordTap) remove(s Sink) {
+	t.mu.Lock()
+	t.sinks = slices.DeleteFunc(t.sinks, func(other Sink) bool { return other == s })
+	t.mu.Unlock()
+}
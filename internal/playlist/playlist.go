@@ -0,0 +1,131 @@
+package playlist
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/iburimskiy/audio-visualization/internal/audio"
+)
+
+// IsAudio reports whether path has a playable extension.
+func IsAudio(path string) bool {
+	return audio.IsSupported(path)
+}
+
+// IsPlaylist reports whether path is an M3U or PLS playlist.
+func IsPlaylist(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".m3u", ".m3u8", ".pls":
+		return true
+	}
+	return false
+}
+
+// Expand turns files, folders and playlists into a flat list of tracks.
+// Folders are searched recursively and sorted by path; playlist entries are
+// resolved relative to the playlist's folder. http(s) URLs are kept as they
+// are.
+func Expand(paths []string) ([]string, error) {
+	var tracks []string
+	for _, p := range paths {
+		found, err := expand(p, 0)
+		if err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, found...)
+	}
+	return tracks, nil
+}
+
+func expand(path string, depth int) ([]string, error) {
+	// Guard against playlists that include each other
+	if depth > 8 {
+		return nil, fmt.Errorf("%s: playlists nested too deeply", path)
+	}
+	if audio.IsURL(path) {
+		return []string{path}, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case info.IsDir():
+		return expandDir(path)
+	case IsPlaylist(path):
+		entries, err := readPlaylist(path)
+		if err != nil {
+			return nil, err
+		}
+		var tracks []string
+		for _, e := range entries {
+			found, err := expand(e, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			tracks = append(tracks, found...)
+		}
+		return tracks, nil
+	case IsAudio(path):
+		return []string{path}, nil
+	default:
+		return nil, fmt.Errorf("%s: unsupported file type", path)
+	}
+}
+
+func expandDir(dir string) ([]string, error) {
+	var tracks []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && IsAudio(p) {
+			tracks = append(tracks, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(tracks)
+	return tracks, nil
+}
+
+// readPlaylist returns the entries of an M3U (one path per line, # comments)
+// or PLS (FileN=path) playlist.
+func readPlaylist(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pls := strings.EqualFold(filepath.Ext(path), ".pls")
+	dir := filepath.Dir(path)
+	var entries []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+		if pls {
+			key, value, ok := strings.Cut(line, "=")
+			if !ok || !strings.HasPrefix(strings.ToLower(key), "file") {
+				continue
+			}
+			line = strings.TrimSpace(value)
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) && !audio.IsURL(line) {
+			line = filepath.Join(dir, filepath.FromSlash(line))
+		}
+		entries = append(entries, line)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,91 @@
+package playlist
+
+// Queue is an ordered list of tracks with a current position.
+type Queue struct {
+	// Loop starts over from the first track after the last one.
+	Loop bool
+
+	tracks []string
+	index  int
+}
+
+func NewQueue(tracks []string) *Queue {
+	return &Queue{tracks: tracks}
+}
+
+// Current returns the track at the current position.
+func (q *Queue) Current() (string, bool) {
+	if q.index < 0 || q.index >= len(q.tracks) {
+		return "", false
+	}
+	return q.tracks[q.index], true
+}
+
+// Next moves to the following track. At the end it wraps around when Loop is
+// set and otherwise reports false.
+func (q *Queue) Next() (string, bool) {
+	if len(q.tracks) == 0 {
+		return "", false
+	}
+	if q.index+1 >= len(q.tracks) {
+		if !q.Loop {
+			q.index = len(q.tracks)
+			return "", false
+		}
+		q.index = -1
+	}
+	q.index++
+	return q.Current()
+}
+
+// Previous moves to the track before the current one, staying on the first
+// track unless Loop is set.
+func (q *Queue) Previous() (string, bool) {
+	if len(q.tracks) == 0 {
+		return "", false
+	}
+	q.index--
+	if q.index < 0 {
+		if q.Loop {
+			q.index = len(q.tracks) - 1
+		} else {
+			q.index = 0
+		}
+	}
+	return q.Current()
+}
+
+// Jump moves to track i.
+func (q *Queue) Jump(i int) (string, bool) {
+	if i < 0 || i >= len(q.tracks) {
+		return "", false
+	}
+	q.index = i
+	return q.Current()
+}
+
+// Add appends tracks to the end of the queue.
+func (q *Queue) Add(tracks ...string) {
+	q.tracks = append(q.tracks, tracks...)
+}
+
+// Replace discards the queue and starts over with tracks.
+func (q *Queue) Replace(tracks ...string) {
+	q.tracks = append([]string(nil), tracks...)
+	q.index = 0
+}
+
+// Len returns the number of queued tracks.
+func (q *Queue) Len() int {
+	return len(q.tracks)
+}
+
+// Tracks returns a copy of the queued tracks.
+func (q *Queue) Tracks() []string {
+	return append([]string(nil), q.tracks...)
+}
+
+// Index returns the zero-based position of the current track.
+func (q *Queue) Index() int {
+	return q.index
+}
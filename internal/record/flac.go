@@ -0,0 +1,409 @@
+package record
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"hash"
+	"io"
+	"math"
+
+	"github.com/faiface/beep"
+)
+
+// flacBlockSize is the number of samples per channel in each FLAC frame.
+const flacBlockSize = 4096
+
+// encodeFLAC writes s to w as 16-bit FLAC, mirroring wav.Encode. Each
+// channel is coded with the best of the fixed linear predictors and Rice
+// coded residuals; stereo frames also try left/side decorrelation. The
+// stream header is completed when s ends, so w must be seekable.
+func encodeFLAC(w io.WriteSeeker, s beep.Streamer, format beep.Format) error {
+	e := &flacEncoder{
+		w:        w,
+		format:   format,
+		md5:      md5.New(),
+		minFrame: math.MaxUint32,
+	}
+	if _, err := w.Write([]byte("fLaC")); err != nil {
+		return err
+	}
+	if err := e.writeStreamInfo(); err != nil {
+		return err
+	}
+
+	block := make([][2]float64, flacBlockSize)
+	for {
+		// Fill a whole block unless the stream ends
+		n := 0
+		for n < len(block) {
+			k, ok := s.Stream(block[n:])
+			n += k
+			if !ok {
+				break
+			}
+		}
+		if n == 0 {
+			break
+		}
+		if err := e.writeFrame(block[:n]); err != nil {
+			return err
+		}
+		if n < len(block) {
+			break
+		}
+	}
+
+	// Rewrite the stream header now that the totals are known
+	if _, err := w.Seek(4, io.SeekStart); err != nil {
+		return err
+	}
+	if err := e.writeStreamInfo(); err != nil {
+		return err
+	}
+	_, err := w.Seek(0, io.SeekEnd)
+	return err
+}
+
+type flacEncoder struct {
+	w        io.Writer
+	format   beep.Format
+	md5      hash.Hash
+	samples  uint64
+	frames   uint64
+	minFrame uint32
+	maxFrame uint32
+	bits     bitWriter
+}
+
+func (e *flacEncoder) writeStreamInfo() error {
+	var b bitWriter
+	b.write(1, 1)   // last metadata block
+	b.write(0, 7)   // STREAMINFO
+	b.write(34, 24) // length
+	minFrame := e.minFrame
+	if minFrame == math.MaxUint32 {
+		minFrame = 0
+	}
+	// Every block but the last is flacBlockSize long, and the last one does
+	// not count towards the minimum, so both sizes are that even when the
+	// stream is a single shorter block
+	b.write(flacBlockSize, 16)
+	b.write(flacBlockSize, 16)
+	b.write(uint64(minFrame), 24)
+	b.write(uint64(e.maxFrame), 24)
+	b.write(uint64(e.format.SampleRate), 20)
+	b.write(uint64(e.format.NumChannels-1), 3)
+	b.write(15, 5) // 16 bits per sample
+	b.write(e.samples, 36)
+	for _, c := range e.md5.Sum(nil) {
+		b.write(uint64(c), 8)
+	}
+	_, err := e.w.Write(b.bytes())
+	return err
+}
+
+func (e *flacEncoder) writeFrame(samples [][2]float64) error {
+	n := len(samples)
+	channels := e.format.NumChannels
+	pcm := make([][]int64, channels)
+	for ch := range pcm {
+		pcm[ch] = make([]int64, n)
+	}
+	var raw [4]byte
+	for i, smp := range samples {
+		for ch := 0; ch < channels; ch++ {
+			v := toInt16(smp[ch])
+			pcm[ch][i] = int64(v)
+			binary.LittleEndian.PutUint16(raw[2*ch:], uint16(v))
+		}
+		e.md5.Write(raw[:2*channels])
+	}
+
+	// Pick independent or left/side stereo, whichever codes smaller
+	assignment := uint64(channels - 1)
+	subframes := make([]subframe, channels)
+	for ch := range subframes {
+		subframes[ch] = bestSubframe(pcm[ch], 16)
+	}
+	if channels == 2 {
+		side := make([]int64, n)
+		for i := range side {
+			side[i] = pcm[0][i] - pcm[1][i]
+		}
+		sideFrame := bestSubframe(side, 17)
+		if subframes[0].bits+sideFrame.bits < subframes[0].bits+subframes[1].bits {
+			assignment = 0b1000
+			subframes[1] = sideFrame
+		}
+	}
+
+	b := &e.bits
+	b.reset()
+	b.write(0x3FFE, 14)      // sync code
+	b.write(0, 1)            // reserved
+	b.write(0, 1)            // fixed block size
+	b.write(0b0111, 4)       // block size in 16 bits after the header
+	b.write(0, 4)            // sample rate from STREAMINFO
+	b.write(assignment, 4)   // channel assignment
+	b.write(0b100, 3)        // 16 bits per sample
+	b.write(0, 1)            // reserved
+	b.writeUTF8(e.frames)    // frame number
+	b.write(uint64(n-1), 16) // block size - 1
+	b.write(uint64(crc8(b.bytes())), 8)
+
+	for _, sf := range subframes {
+		sf.write(b)
+	}
+	b.align()
+	b.write(uint64(crc16(b.bytes())), 16)
+
+	frame := b.bytes()
+	if _, err := e.w.Write(frame); err != nil {
+		return err
+	}
+
+	e.frames++
+	e.samples += uint64(n)
+	e.minFrame = min(e.minFrame, uint32(len(frame)))
+	e.maxFrame = max(e.maxFrame, uint32(len(frame)))
+	return nil
+}
+
+// subframe is one channel of a frame, coded as a constant, verbatim or with
+// a fixed predictor of the given order.
+type subframe struct {
+	samples  []int64
+	bps      int
+	kind     int // 0 constant, 1 verbatim, 2 fixed
+	order    int
+	residual []int64
+	params   []int // Rice parameter per partition
+	bits     int   // coded size
+}
+
+// Fixed predictors of order 0 to 4 as residual functions
+var fixedPredictors = [5]func(x []int64, i int) int64{
+	func(x []int64, i int) int64 { return x[i] },
+	func(x []int64, i int) int64 { return x[i] - x[i-1] },
+	func(x []int64, i int) int64 { return x[i] - 2*x[i-1] + x[i-2] },
+	func(x []int64, i int) int64 { return x[i] - 3*x[i-1] + 3*x[i-2] - x[i-3] },
+	func(x []int64, i int) int64 { return x[i] - 4*x[i-1] + 6*x[i-2] - 4*x[i-3] + x[i-4] },
+}
+
+func bestSubframe(x []int64, bps int) subframe {
+	constant := true
+	for _, v := range x {
+		if v != x[0] {
+			constant = false
+			break
+		}
+	}
+	if constant {
+		return subframe{samples: x, bps: bps, kind: 0, bits: 8 + bps}
+	}
+
+	best := subframe{samples: x, bps: bps, kind: 1, bits: 8 + bps*len(x)}
+	for order := 0; order < len(fixedPredictors) && order < len(x); order++ {
+		residual := make([]int64, len(x)-order)
+		for i := order; i < len(x); i++ {
+			residual[i-order] = fixedPredictors[order](x, i)
+		}
+		params, bits, ok := riceParams(residual, len(x), order)
+		if !ok {
+			continue
+		}
+		bits += 8 + order*bps
+		if bits < best.bits {
+			best = subframe{samples: x, bps: bps, kind: 2, order: order, residual: residual, params: params, bits: bits}
+		}
+	}
+	return best
+}
+
+// riceParams picks the partition order and Rice parameters that code the
+// residual of a block of n samples in the fewest bits.
+func riceParams(residual []int64, n, order int) ([]int, int, bool) {
+	var bestParams []int
+	bestBits := -1
+	for p := 0; p <= 8; p++ {
+		parts := 1 << p
+		if n%parts != 0 || n/parts <= order {
+			break
+		}
+		size := n / parts
+		params := make([]int, parts)
+		bits := 6 // coding method and partition order
+		start := 0
+		for i := range params {
+			end := (i+1)*size - order
+			k, cost := riceCost(residual[start:end])
+			params[i] = k
+			bits += 4 + cost
+			start = end
+		}
+		if bestBits < 0 || bits < bestBits {
+			bestParams, bestBits = params, bits
+		}
+	}
+	return bestParams, bestBits, bestBits >= 0
+}
+
+// riceCost returns the best Rice parameter for values and the bits they take.
+func riceCost(values []int64) (int, int) {
+	bestK, bestBits := 0, math.MaxInt
+	for k := 0; k <= 14; k++ {
+		bits := len(values) * (k + 1)
+		for _, v := range values {
+			bits += int(zigzag(v) >> k)
+		}
+		if bits < bestBits {
+			bestK, bestBits = k, bits
+		}
+	}
+	return bestK, bestBits
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func (sf subframe) write(b *bitWriter) {
+	switch sf.kind {
+	case 0:
+		b.write(0, 8) // padding, type 000000, no wasted bits
+		b.writeSigned(sf.samples[0], sf.bps)
+	case 1:
+		b.write(0b00000010, 8)
+		for _, v := range sf.samples {
+			b.writeSigned(v, sf.bps)
+		}
+	case 2:
+		b.write(uint64(0b00010000|sf.order<<1), 8)
+		for _, v := range sf.samples[:sf.order] {
+			b.writeSigned(v, sf.bps)
+		}
+		b.write(0, 2) // Rice coding with 4-bit parameters
+		partitionOrder := 0
+		for 1<<partitionOrder < len(sf.params) {
+			partitionOrder++
+		}
+		b.write(uint64(partitionOrder), 4)
+		size := len(sf.samples) >> partitionOrder
+		start := 0
+		for i, k := range sf.params {
+			end := (i+1)*size - sf.order
+			b.write(uint64(k), 4)
+			for _, v := range sf.residual[start:end] {
+				u := zigzag(v)
+				b.writeUnary(u >> k)
+				b.write(u&(1<<k-1), k)
+			}
+			start = end
+		}
+	}
+}
+
+// bitWriter collects a big-endian bit stream in memory.
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits int
+}
+
+func (b *bitWriter) reset() {
+	b.buf = b.buf[:0]
+	b.acc, b.nbits = 0, 0
+}
+
+func (b *bitWriter) write(v uint64, n int) {
+	for n > 0 {
+		take := min(n, 56-b.nbits)
+		n -= take
+		b.acc = b.acc<<take | (v>>n)&(1<<take-1)
+		b.nbits += take
+		for b.nbits >= 8 {
+			b.nbits -= 8
+			b.buf = append(b.buf, byte(b.acc>>b.nbits))
+		}
+		b.acc &= 1<<b.nbits - 1
+	}
+}
+
+func (b *bitWriter) writeSigned(v int64, n int) {
+	b.write(uint64(v)&(1<<n-1), n)
+}
+
+func (b *bitWriter) writeUnary(q uint64) {
+	for q >= 32 {
+		b.write(0, 32)
+		q -= 32
+	}
+	b.write(1, int(q)+1)
+}
+
+// writeUTF8 writes v with the UTF-8 style coding FLAC uses for frame numbers.
+func (b *bitWriter) writeUTF8(v uint64) {
+	if v < 0x80 {
+		b.write(v, 8)
+		return
+	}
+	n := 2
+	for v >= 1<<(5*n+1) {
+		n++
+	}
+	b.write(uint64(0xFF00>>n)&0xFF|v>>(6*(n-1)), 8)
+	for i := n - 2; i >= 0; i-- {
+		b.write(0x80|(v>>(6*i))&0x3F, 8)
+	}
+}
+
+func (b *bitWriter) align() {
+	if b.nbits > 0 {
+		b.write(0, 8-b.nbits)
+	}
+}
+
+// bytes returns the complete bytes written so far.
+func (b *bitWriter) bytes() []byte {
+	return b.buf
+}
+
+func crc8(data []byte) uint8 {
+	var crc uint8
+	for _, c := range data {
+		crc ^= c
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, c := range data {
+		crc ^= uint16(c) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func toInt16(v float64) int16 {
+	if v < -1 {
+		v = -1
+	}
+	if v > 1 {
+		v = 1
+	}
+	return int16(v * (1<<15 - 1))
+}
@@ -0,0 +1,98 @@
+package record
+
+import (
+	"crypto/md5"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/faiface/beep"
+	"github.com/mewkiz/flac"
+)
+
+// testSamples returns n samples of a chirp on the left and noise-like steps on
+// the right, which exercise the predictors and the Rice coding.
+func testSamples(n int) [][2]float64 {
+	s := make([][2]float64, n)
+	for i := range s {
+		phase := float64(i) * float64(i) / 2e5
+		s[i] = [2]float64{0.8 * math.Sin(phase), float64((i*7919)%2001-1000) / 1000}
+	}
+	return s
+}
+
+func TestFLACRoundTrip(t *testing.T) {
+	for _, channels := range []int{1, 2} {
+		for _, n := range []int{1, 4095, 4096, 4100, 100000} {
+			in := testSamples(n)
+			path := filepath.Join(t.TempDir(), "test.flac")
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			format := beep.Format{SampleRate: 44100, NumChannels: channels, Precision: 2}
+			if err := encodeFLAC(f, &sliceStreamer{s: in}, format); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+
+			stream, err := flac.Open(path)
+			if err != nil {
+				t.Fatalf("%d channels, %d samples: %v", channels, n, err)
+			}
+			info := stream.Info
+			if info.BlockSizeMin != flacBlockSize || info.BlockSizeMax != flacBlockSize ||
+				info.SampleRate != 44100 || int(info.NChannels) != channels ||
+				info.BitsPerSample != 16 || info.NSamples != uint64(n) {
+				t.Errorf("%d channels, %d samples: stream info %+v", channels, n, *info)
+			}
+
+			sum := md5.New()
+			got := 0
+			for {
+				frame, err := stream.ParseNext()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("%d channels, %d samples: frame at %d: %v", channels, n, got, err)
+				}
+				frame.Hash(sum)
+				for i := 0; i < int(frame.BlockSize); i++ {
+					for ch := 0; ch < channels; ch++ {
+						want := int32(toInt16(in[got+i][ch]))
+						if v := frame.Subframes[ch].Samples[i]; v != want {
+							t.Fatalf("%d channels, %d samples: sample %d of channel %d is %d, want %d", channels, n, got+i, ch, v, want)
+						}
+					}
+				}
+				got += int(frame.BlockSize)
+			}
+			stream.Close()
+			if got != n {
+				t.Errorf("%d channels, %d samples: decoded %d", channels, n, got)
+			}
+			if string(sum.Sum(nil)) != string(info.MD5sum[:]) {
+				t.Errorf("%d channels, %d samples: MD5 mismatch", channels, n)
+			}
+		}
+	}
+}
+
+// sliceStreamer plays s once.
+type sliceStreamer struct {
+	s [][2]float64
+}
+
+func (s *sliceStreamer) Stream(samples [][2]float64) (int, bool) {
+	if len(s.s) == 0 {
+		return 0, false
+	}
+	n := copy(samples, s.s)
+	s.s = s.s[n:]
+	return n, true
+}
+
+func (s *sliceStreamer) Err() error { return nil }
@@ -0,0 +1,163 @@
+// Package record writes audio pushed from the playback goroutine to WAV or
+// FLAC files without blocking it.
+package record
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/wav"
+)
+
+// Formats lists the supported file extensions.
+var Formats = []string{".wav", ".flac"}
+
+// Recorder encodes samples to a file on its own goroutine.
+type Recorder struct {
+	path    string
+	rate    beep.SampleRate
+	pipe    *pipe
+	written atomic.Int64
+	done    chan struct{}
+	err     error
+}
+
+// Start creates the file at path, along with missing folders, and starts
+// encoding. The format follows the extension: .wav or .flac, both 16-bit
+// stereo.
+func Start(path string, rate beep.SampleRate) (*Recorder, error) {
+	var encode func(io.WriteSeeker, beep.Streamer, beep.Format) error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav":
+		encode = wav.Encode
+	case ".flac":
+		encode = encodeFLAC
+	default:
+		return nil, errors.New("unsupported recording format: " + filepath.Ext(path))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{
+		path: path,
+		rate: rate,
+		pipe: newPipe(),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(r.done)
+		format := beep.Format{SampleRate: rate, NumChannels: 2, Precision: 2}
+		err := encode(f, r.pipe, format)
+		// Stop queueing samples if the encoder gave up early
+		r.pipe.close()
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		r.err = err
+	}()
+	return r, nil
+}
+
+// Write queues samples for encoding. It never blocks on the disk, so it can
+// be called from the audio goroutine.
+func (r *Recorder) Write(samples [][2]float64) {
+	if r.pipe.write(samples) {
+		r.written.Add(int64(len(samples)))
+	}
+}
+
+// Duration returns the length of the audio written so far.
+func (r *Recorder) Duration() time.Duration {
+	return r.rate.D(int(r.written.Load()))
+}
+
+// Path returns the file being written.
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Failed reports whether encoding stopped because of an error, which Stop
+// returns.
+func (r *Recorder) Failed() bool {
+	select {
+	case <-r.done:
+		return r.err != nil
+	default:
+		return false
+	}
+}
+
+// Stop finishes the file once every queued sample is written.
+func (r *Recorder) Stop() error {
+	r.pipe.close()
+	<-r.done
+	return r.err
+}
+
+// pipe is an unbounded queue of samples that reads as a beep.Streamer,
+// blocking until samples arrive or the pipe is closed.
+type pipe struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending [][2]float64
+	closed  bool
+}
+
+func newPipe() *pipe {
+	p := &pipe{}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+func (p *pipe) write(samples [][2]float64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	p.pending = append(p.pending, samples...)
+	p.cond.Signal()
+	return true
+}
+
+func (p *pipe) close() {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Signal()
+	p.mu.Unlock()
+}
+
+func (p *pipe) Stream(samples [][2]float64) (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.pending) == 0 && !p.closed {
+		p.cond.Wait()
+	}
+	if len(p.pending) == 0 {
+		return 0, false
+	}
+	n := copy(samples, p.pending)
+	p.pending = p.pending[n:]
+	if len(p.pending) == 0 {
+		// Reuse the backing array instead of growing it forever
+		p.pending = p.pending[:0:0]
+	}
+	return n, true
+}
+
+func (p *pipe) Err() error {
+	return nil
+}
@@ -0,0 +1,62 @@
+// Package thumbnail renders overview images of whole tracks as PNG or SVG.
+package thumbnail
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strings"
+
+	"github.com/iburimskiy/audio-visualization/internal/palette"
+)
+
+// Theme picks the colors of a thumbnail. Colors run through the hue range
+// from left to right for waveforms and from bass to treble for spectra.
+type Theme struct {
+	Background color.RGBA
+	Hue        float64 // start hue, degrees
+	HueSpan    float64 // degrees covered across the image
+	Saturation float64
+	Value      float64
+}
+
+var themes = map[string]Theme{
+	"aurora": {Background: color.RGBA{R: 10, G: 12, B: 20, A: 255}, Hue: 180, HueSpan: 160, Saturation: 0.8, Value: 0.95},
+	"fire":   {Background: color.RGBA{R: 18, G: 8, B: 6, A: 255}, Hue: 0, HueSpan: 55, Saturation: 0.9, Value: 1},
+	"ocean":  {Background: color.RGBA{R: 4, G: 14, B: 24, A: 255}, Hue: 170, HueSpan: 60, Saturation: 0.75, Value: 0.9},
+	"mono":   {Background: color.RGBA{R: 16, G: 16, B: 16, A: 255}, Saturation: 0, Value: 0.9},
+	"light":  {Background: color.RGBA{R: 250, G: 250, B: 250, A: 255}, Hue: 210, HueSpan: 120, Saturation: 0.7, Value: 0.55},
+}
+
+// DefaultTheme is used when no theme is given.
+const DefaultTheme = "aurora"
+
+// Themes returns the theme names in alphabetical order.
+func Themes() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupTheme returns the theme called name.
+func LookupTheme(name string) (Theme, error) {
+	t, ok := themes[name]
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown theme %q (available: %s)", name, strings.Join(Themes(), ", "))
+	}
+	return t, nil
+}
+
+// color returns the color at position t (0 to 1) across the image, mixed
+// with the background by opacity (0 to 1). The result is opaque so PNG and
+// SVG output look the same without relying on blending.
+func (th Theme) color(t, opacity float64) color.RGBA {
+	r, g, b := palette.HSVToRGB(th.Hue+t*th.HueSpan, th.Saturation, th.Value)
+	mix := func(bg, fg uint8) uint8 {
+		return uint8(float64(bg) + (float64(fg)-float64(bg))*opacity + 0.5)
+	}
+	return color.RGBA{R: mix(th.Background.R, r), G: mix(th.Background.G, g), B: mix(th.Background.B, b), A: 255}
+}
@@ -0,0 +1,192 @@
+package thumbnail
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/faiface/beep"
+
+	"github.com/iburimskiy/audio-visualization/internal/analysis"
+)
+
+// Kind selects what a thumbnail shows.
+type Kind int
+
+const (
+	Waveform Kind = iota // min/max and RMS of the signal over time
+	Spectrum             // band levels over time
+)
+
+func (k Kind) String() string {
+	if k == Spectrum {
+		return "spectrum"
+	}
+	return "waveform"
+}
+
+// ParseKind returns the kind called name.
+func ParseKind(name string) (Kind, error) {
+	switch name {
+	case "waveform":
+		return Waveform, nil
+	case "spectrum":
+		return Spectrum, nil
+	}
+	return 0, fmt.Errorf("unknown kind %q (available: waveform, spectrum)", name)
+}
+
+// DefaultBands is the number of spectrum rows used when none is given.
+const DefaultBands = 64
+
+// Options describe the image to render.
+type Options struct {
+	Width, Height int
+	Kind          Kind
+	Theme         Theme
+	// Bands is the number of frequency rows of a spectrum.
+	Bands int
+}
+
+// Validate reports options that cannot be rendered.
+func (o Options) Validate() error {
+	switch {
+	case o.Width < 1 || o.Width > 16384 || o.Height < 1 || o.Height > 16384:
+		return fmt.Errorf("size: %dx%d is out of range (1 to 16384)", o.Width, o.Height)
+	case o.Kind == Spectrum && (o.Bands < 1 || o.Bands > o.Height):
+		return fmt.Errorf("bands: %d is out of range (1 to the image height)", o.Bands)
+	}
+	return nil
+}
+
+// Thumbnail is a rendered overview, kept as rectangles so it can be written
+// both as a bitmap and as vector graphics.
+type Thumbnail struct {
+	width, height int
+	background    color.RGBA
+	rects         []rect
+}
+
+type rect struct {
+	x, y, w, h int
+	color      color.RGBA
+}
+
+// Generate reads s to the end and renders it. length is the expected number
+// of samples, usually the streamer's Len.
+func Generate(s beep.Streamer, format beep.Format, length int, opts Options) (*Thumbnail, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	t := &Thumbnail{width: opts.Width, height: opts.Height, background: opts.Theme.Background}
+	switch opts.Kind {
+	case Spectrum:
+		levels, _, err := analysis.Spectrum(s, format, length, opts.Width, opts.Bands)
+		if err != nil {
+			return nil, err
+		}
+		t.rects = spectrumRects(levels, opts)
+	default:
+		peaks, err := analysis.Peaks(s, length, opts.Width)
+		if err != nil {
+			return nil, err
+		}
+		t.rects = waveformRects(peaks, opts)
+	}
+	return t, nil
+}
+
+// waveformRects draws one column per pixel: the min/max range dimmed and the
+// RMS range on top at full strength.
+func waveformRects(peaks []analysis.Peak, opts Options) []rect {
+	rects := make([]rect, 0, 2*len(peaks)+1)
+	mid := float64(opts.Height) / 2
+	span := func(top, bottom float64) (int, int) {
+		y0 := int(math.Floor(mid - top*mid))
+		y1 := int(math.Ceil(mid - bottom*mid))
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		return max(y0, 0), min(y1, opts.Height) - max(y0, 0)
+	}
+
+	// Center line
+	rects = append(rects, rect{0, int(mid), opts.Width, 1, opts.Theme.color(0, 0.25)})
+	for x, p := range peaks {
+		t := float64(x) / float64(max(len(peaks)-1, 1))
+		y, h := span(clampSigned(p.Max), clampSigned(p.Min))
+		rects = append(rects, rect{x, y, 1, h, opts.Theme.color(t, 0.55)})
+		if p.RMS > 0 {
+			y, h = span(clampSigned(p.RMS), -clampSigned(p.RMS))
+			rects = append(rects, rect{x, y, 1, h, opts.Theme.color(t, 1)})
+		}
+	}
+	return rects
+}
+
+// spectrumRects draws one cell per column and band, bass at the bottom, with
+// levels from -90 to 0 dBFS mapped to opacity.
+func spectrumRects(levels [][]float64, opts Options) []rect {
+	const floor = -90.0
+	var rects []rect
+	for x, row := range levels {
+		for b, level := range row {
+			strength := clamp((level - floor) / -floor)
+			if strength <= 0 {
+				continue
+			}
+			top := opts.Height - (b+1)*opts.Height/len(row)
+			bottom := opts.Height - b*opts.Height/len(row)
+			t := float64(b) / float64(max(len(row)-1, 1))
+			rects = append(rects, rect{x, top, 1, bottom - top, opts.Theme.color(t, strength)})
+		}
+	}
+	return rects
+}
+
+// Image draws the thumbnail into a new bitmap.
+func (t *Thumbnail) Image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, t.width, t.height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(t.background), image.Point{}, draw.Src)
+	for _, r := range t.rects {
+		area := image.Rect(r.x, r.y, r.x+r.w, r.y+r.h)
+		draw.Draw(img, area, image.NewUniform(r.color), image.Point{}, draw.Src)
+	}
+	return img
+}
+
+// WritePNG encodes the thumbnail as PNG.
+func (t *Thumbnail) WritePNG(w io.Writer) error {
+	return png.Encode(w, t.Image())
+}
+
+// WriteSVG writes the thumbnail as an SVG document. Shapes snap to whole
+// pixels, so crispEdges rendering keeps it identical to the PNG.
+func (t *Thumbnail) WriteSVG(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		t.width, t.height, t.width, t.height)
+	fmt.Fprintf(bw, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", hexColor(t.background))
+	for _, r := range t.rects {
+		fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", r.x, r.y, r.w, r.h, hexColor(r.color))
+	}
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+func clampSigned(v float64) float64 {
+	return math.Max(-1, math.Min(1, v))
+}
@@ -0,0 +1,163 @@
+package visual
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+func init() {
+	Register("circles", func() Visualizer { return &circles{} })
+	Register("waves", func() Visualizer { return &waves{} })
+	Register("particles", func() Visualizer { return &particles{} })
+	Register("rings", func() Visualizer { return &rings{} })
+}
+
+// circles draws dots orbiting the center that pulse with the audio.
+type circles struct{ base }
+
+func (c *circles) Draw(dst *ebiten.Image) {
+	if !c.ready() {
+		return
+	}
+	centerX, centerY := c.center()
+	u := c.unit()
+
+	count := c.frame.Settings.CircleCount
+	for i := 0; i < count; i++ {
+		angle := float64(i) * (2 * math.Pi / float64(count))
+		radius := (30 + float64(i)*15 + c.band(i)*100) * u
+
+		x := centerX + math.Cos(angle+c.frame.Rotation)*radius
+		y := centerY + math.Sin(angle+c.frame.Rotation)*radius
+
+		// Dynamic color based on audio and time
+		hue := (c.frame.ColorPhase + float64(i)*0.1) * 360
+		r, g, b := c.hsv(hue, 0.8, 0.9)
+
+		// Draw circle with varying opacity
+		opacity := uint8(150 + 105*c.band(i))
+		circleColor := color.RGBA{R: r, G: g, B: b, A: opacity}
+
+		circleRadius := (8 + c.band(i)*20) * u
+		vector.DrawFilledCircle(dst, float32(x), float32(y), float32(circleRadius), circleColor, false)
+	}
+}
+
+// waves draws rippling spokes whose length follows the audio.
+type waves struct{ base }
+
+func (w *waves) Draw(dst *ebiten.Image) {
+	if !w.ready() {
+		return
+	}
+	centerX, centerY := w.center()
+	t := w.frame.Time
+	u := w.unit()
+
+	count := w.frame.Settings.WaveCount
+	for i := 0; i < count; i++ {
+		angle := float64(i) * (2 * math.Pi / float64(count))
+		waveRadius := 80 + w.band(i)*150
+
+		// Create wave effect
+		for j := 0; j < 360; j += 5 {
+			waveAngle := float64(j) * math.Pi / 180
+			waveOffset := math.Sin(waveAngle*3+t*2) * 10
+			waveRadiusOffset := (waveRadius + waveOffset + w.band(i)*50) * u
+
+			x1 := centerX + math.Cos(angle)*waveRadiusOffset
+			y1 := centerY + math.Sin(angle)*waveRadiusOffset
+
+			nextAngle := float64(j+5) * math.Pi / 180
+			nextOffset := math.Sin(nextAngle*3+t*2) * 10
+			nextRadiusOffset := (waveRadius + nextOffset + w.band(i)*50) * u
+
+			x2 := centerX + math.Cos(angle)*nextRadiusOffset
+			y2 := centerY + math.Sin(angle)*nextRadiusOffset
+
+			// Color based on wave position and audio
+			hue := (w.frame.ColorPhase + float64(i)*0.05 + float64(j)*0.01) * 360
+			r, g, b := w.hsv(hue, 0.7, 0.8)
+
+			opacity := uint8(100 + 155*w.band(i))
+			waveColor := color.RGBA{R: r, G: g, B: b, A: opacity}
+
+			vector.StrokeLine(dst, float32(x1), float32(y1), float32(x2), float32(y2), float32(2*u), waveColor, false)
+		}
+	}
+}
+
+// particles draws a swirl of dots thrown outwards by the audio.
+type particles struct{ base }
+
+func (p *particles) Draw(dst *ebiten.Image) {
+	if !p.ready() {
+		return
+	}
+	centerX, centerY := p.center()
+	u := p.unit()
+
+	for i := 0; i < p.frame.Settings.ParticleCount; i++ {
+		// Particle position based on audio and time
+		angle := p.frame.Time*0.5 + float64(i)*0.1
+		radius := (20 + p.band(i)*300) * u
+
+		x := centerX + math.Cos(angle)*radius
+		y := centerY + math.Sin(angle)*radius
+
+		// Particle size and color
+		size := (2 + p.band(i)*8) * u
+		hue := (p.frame.ColorPhase + float64(i)*0.02) * 360
+		r, g, b := p.hsv(hue, 1.0, 1.0)
+
+		opacity := uint8(200 + 55*p.band(i))
+		particleColor := color.RGBA{R: r, G: g, B: b, A: opacity}
+
+		vector.DrawFilledCircle(dst, float32(x), float32(y), float32(size), particleColor, false)
+	}
+}
+
+// rings draws segmented energy rings that expand with the audio.
+type rings struct{ base }
+
+func (rg *rings) Draw(dst *ebiten.Image) {
+	if !rg.ready() {
+		return
+	}
+	centerX, centerY := rg.center()
+	u := rg.unit()
+
+	for i := 0; i < 5; i++ {
+		ringRadius := (float64(40+i*30) + rg.band(i)*100) * u
+
+		// Skip quiet rings entirely
+		if rg.band(i) < 0.1 {
+			continue
+		}
+
+		// Draw ring segments
+		segments := 24
+		for j := 0; j < segments; j++ {
+			startAngle := float64(j) * (2 * math.Pi / float64(segments))
+			endAngle := float64(j+1) * (2 * math.Pi / float64(segments))
+
+			x1 := centerX + math.Cos(startAngle)*ringRadius
+			y1 := centerY + math.Sin(startAngle)*ringRadius
+			x2 := centerX + math.Cos(endAngle)*ringRadius
+			y2 := centerY + math.Sin(endAngle)*ringRadius
+
+			// Color based on ring and segment
+			hue := (rg.frame.ColorPhase + float64(i)*0.2 + float64(j)*0.1) * 360
+			r, g, b := rg.hsv(hue, 0.9, 0.8)
+
+			opacity := uint8(120 + 135*rg.band(i))
+			ringColor := color.RGBA{R: r, G: g, B: b, A: opacity}
+
+			strokeWidth := (3 + rg.band(i)*8) * u
+			vector.StrokeLine(dst, float32(x1), float32(y1), float32(x2), float32(y2), float32(strokeWidth), ringColor, false)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package visual
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Factory creates a new, uninitialized instance of a scene.
+type Factory func() Visualizer
+
+type entry struct {
+	name    string
+	factory Factory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []entry
+)
+
+// Register makes a scene available by name. Scenes keep their registration
+// order, which is also the order used for number-key selection. Register is
+// meant to be called from init functions and panics on duplicate names.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("visual: Register factory is nil for " + name)
+	}
+	for _, e := range registry {
+		if e.name == name {
+			panic("visual: Register called twice for " + name)
+		}
+	}
+	registry = append(registry, entry{name: name, factory: factory})
+}
+
+// Scenes returns the names of all registered scenes in registration order.
+func Scenes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, len(registry))
+	for i, e := range registry {
+		names[i] = e.name
+	}
+	return names
+}
+
+// New creates a scene by name.
+func New(name string) (Visualizer, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for _, e := range registry {
+		if e.name == name {
+			return e.factory(), nil
+		}
+	}
+	return nil, fmt.Errorf("visual: unknown scene %q", name)
+}
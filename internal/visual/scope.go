@@ -0,0 +1,261 @@
+package visual
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+func init() {
+	Register("oscilloscope", func() Visualizer { return &oscilloscope{} })
+	Register("vectorscope", func() Visualizer { return &vectorscope{} })
+	Register("goniometer", func() Visualizer { return &vectorscope{midSide: true} })
+}
+
+// phosphorFade is a blend that subtracts the source from the destination, so
+// drawing a faint solid color over the phosphor image dims it towards black.
+var phosphorFade = ebiten.Blend{
+	BlendFactorSourceRGB:        ebiten.BlendFactorOne,
+	BlendFactorSourceAlpha:      ebiten.BlendFactorOne,
+	BlendFactorDestinationRGB:   ebiten.BlendFactorOne,
+	BlendFactorDestinationAlpha: ebiten.BlendFactorOne,
+	BlendOperationRGB:           ebiten.BlendOperationReverseSubtract,
+	BlendOperationAlpha:         ebiten.BlendOperationReverseSubtract,
+}
+
+var whitePixel = func() *ebiten.Image {
+	img := ebiten.NewImage(1, 1)
+	img.Fill(color.White)
+	return img
+}()
+
+// scopeArea returns the region of the screen used by the scope scenes: the
+// area left free by the UI controls, or the whole image if none is given.
+func (b *base) scopeArea() (x, y, w, h float64) {
+	area := b.frame.Area
+	if area.Empty() {
+		return 0, 0, float64(b.width), float64(b.height)
+	}
+	return float64(area.Min.X), float64(area.Min.Y), float64(area.Dx()), float64(area.Dy())
+}
+
+// scale returns the device scale factor of the current frame.
+func (b *base) scale() float64 {
+	if b.frame.Scale <= 0 {
+		return 1
+	}
+	return b.frame.Scale
+}
+
+// triggerIndex finds a rising zero crossing of the mono signal so that
+// consecutive frames start the waveform at the same phase. It searches
+// for the newest crossing that still leaves a full window to draw.
+func triggerIndex(samples [][2]float64, window int) int {
+	last := len(samples) - window
+	if last <= 0 {
+		return 0
+	}
+	const hysteresis = 0.01
+	mono := func(i int) float64 { return (samples[i][0] + samples[i][1]) * 0.5 }
+	trigger := -1
+	armed := false
+	for i := 1; i <= last; i++ {
+		cur := mono(i)
+		if cur < -hysteresis {
+			armed = true
+		}
+		if armed && mono(i-1) < 0 && cur >= 0 {
+			trigger = i
+			armed = false
+		}
+	}
+	if trigger >= 0 {
+		return trigger
+	}
+	// No trigger found (silence or DC): show the most recent samples
+	return last
+}
+
+// oscilloscope draws a trigger-stabilized waveform of both channels.
+type oscilloscope struct{ base }
+
+func (o *oscilloscope) Draw(screen *ebiten.Image) {
+	if o.frame == nil {
+		return
+	}
+	x, y, w, h := o.scopeArea()
+	px := float32(o.scale())
+
+	vector.DrawFilledRect(screen, float32(x), float32(y), float32(w), float32(h), color.RGBA{R: 5, G: 10, B: 15, A: 160}, false)
+	vector.StrokeRect(screen, float32(x), float32(y), float32(w), float32(h), px, color.RGBA{R: 60, G: 70, B: 90, A: 255}, false)
+
+	// Graticule
+	midY := y + h/2
+	for i := 1; i < 10; i++ {
+		gx := x + w*float64(i)/10
+		vector.StrokeLine(screen, float32(gx), float32(y), float32(gx), float32(y+h), px, color.RGBA{R: 40, G: 50, B: 60, A: 120}, false)
+	}
+	vector.StrokeLine(screen, float32(x), float32(midY), float32(x+w), float32(midY), px, color.RGBA{R: 80, G: 90, B: 110, A: 160}, false)
+
+	const window = 1024
+	if len(o.frame.Samples) < 2 {
+		return
+	}
+	samples := o.frame.Samples
+	start := triggerIndex(samples, window)
+	end := start + window
+	if end > len(samples) {
+		end = len(samples)
+	}
+	trace := samples[start:end]
+	if len(trace) < 2 {
+		return
+	}
+
+	// Draw each channel in its own color
+	for ch := 0; ch < 2; ch++ {
+		hue := (o.frame.ColorPhase + float64(ch)*0.33) * 360
+		r, g, b := o.hsv(hue, 0.7, 1.0)
+		traceColor := color.RGBA{R: r, G: g, B: b, A: 220}
+
+		step := w / float64(len(trace)-1)
+		prevX := x
+		prevY := midY - clampUnit(trace[0][ch])*h/2
+		for i := 1; i < len(trace); i++ {
+			curX := x + float64(i)*step
+			curY := midY - clampUnit(trace[i][ch])*h/2
+			vector.StrokeLine(screen, float32(prevX), float32(prevY), float32(curX), float32(curY), 1.5*px, traceColor, true)
+			prevX, prevY = curX, curY
+		}
+	}
+
+	PrintAt(screen, "L", int(x+6*o.scale()), int(y+4*o.scale()), o.scale())
+	PrintAt(screen, "R", int(x+18*o.scale()), int(y+4*o.scale()), o.scale())
+	o.drawCorrelationMeter(screen)
+}
+
+// vectorscope plots left against right with phosphor-style persistence. With
+// midSide set it works as a goniometer, rotated by 45° so mono is vertical.
+type vectorscope struct {
+	base
+	midSide  bool
+	phosphor *ebiten.Image
+}
+
+func (v *vectorscope) Resize(width, height int) {
+	v.base.Resize(width, height)
+	if v.phosphor != nil {
+		v.phosphor.Deallocate()
+		v.phosphor = nil
+	}
+}
+
+func (v *vectorscope) Init(width, height int) { v.Resize(width, height) }
+
+func (v *vectorscope) Draw(screen *ebiten.Image) {
+	if v.frame == nil || v.frame.Settings == nil {
+		return
+	}
+	x, y, w, h := v.scopeArea()
+	scale := v.scale()
+	px := float32(scale)
+	size := math.Min(w, h)
+	left := x + (w-size)/2
+	top := y + (h-size)/2
+	centerX := left + size/2
+	centerY := top + size/2
+
+	if v.phosphor == nil {
+		v.phosphor = ebiten.NewImage(v.width, v.height)
+	}
+
+	// Fade previous frames to simulate phosphor persistence
+	fade := &ebiten.DrawImageOptions{Blend: phosphorFade}
+	fade.GeoM.Scale(float64(v.width), float64(v.height))
+	decay := float32(math.Min(1, v.frame.Settings.PhosphorDecay*v.frame.Delta))
+	fade.ColorScale.Scale(decay, decay, decay, decay)
+	v.phosphor.DrawImage(whitePixel, fade)
+
+	// Plot the newest samples as dots on the phosphor
+	hue := v.frame.ColorPhase * 360
+	r, g, b := v.hsv(hue, 0.5, 1.0)
+	dotColor := color.RGBA{R: r, G: g, B: b, A: 255}
+	radius := size / 2
+	for _, s := range v.frame.Samples {
+		var sampleX, sampleY float64
+		if v.midSide {
+			// Goniometer: rotate by 45° so mono sits on the vertical axis
+			sampleX = (s[1] - s[0]) * math.Sqrt2 / 2
+			sampleY = (s[0] + s[1]) * math.Sqrt2 / 2
+		} else {
+			sampleX = s[0]
+			sampleY = s[1]
+		}
+		sx := centerX + clampUnit(sampleX)*radius
+		sy := centerY - clampUnit(sampleY)*radius
+		vector.DrawFilledRect(v.phosphor, float32(sx), float32(sy), 1.5*px, 1.5*px, dotColor, false)
+	}
+
+	// Frame and axes
+	vector.DrawFilledRect(screen, float32(left), float32(top), float32(size), float32(size), color.RGBA{R: 5, G: 10, B: 15, A: 160}, false)
+	screen.DrawImage(v.phosphor, &ebiten.DrawImageOptions{Blend: ebiten.BlendLighter})
+	vector.StrokeRect(screen, float32(left), float32(top), float32(size), float32(size), px, color.RGBA{R: 60, G: 70, B: 90, A: 255}, false)
+	axisColor := color.RGBA{R: 80, G: 90, B: 110, A: 140}
+	vector.StrokeLine(screen, float32(centerX), float32(top), float32(centerX), float32(top+size), px, axisColor, false)
+	vector.StrokeLine(screen, float32(left), float32(centerY), float32(left+size), float32(centerY), px, axisColor, false)
+	if v.midSide {
+		PrintAt(screen, "M", int(centerX+4*scale), int(top+4*scale), scale)
+		PrintAt(screen, "S", int(left+size-12*scale), int(centerY+4*scale), scale)
+	} else {
+		// Mono material lands on the rising diagonal
+		vector.StrokeLine(screen, float32(left), float32(top+size), float32(left+size), float32(top), px, axisColor, false)
+		PrintAt(screen, "L", int(left+size-12*scale), int(centerY+4*scale), scale)
+		PrintAt(screen, "R", int(centerX+4*scale), int(top+4*scale), scale)
+	}
+
+	v.drawCorrelationMeter(screen)
+}
+
+func (b *base) drawCorrelationMeter(screen *ebiten.Image) {
+	// The meter sits just above the top-right corner of the scope area
+	correlation := b.frame.Correlation
+	scale := b.scale()
+	px := float32(scale)
+	x, y, w, _ := b.scopeArea()
+	meterWidth := 200 * scale
+	meterHeight := 10 * scale
+	meterX := x + w - meterWidth
+	meterY := y - 50*scale
+	if meterY < 0 {
+		meterY = y
+	}
+
+	vector.DrawFilledRect(screen, float32(meterX), float32(meterY), float32(meterWidth), float32(meterHeight), color.RGBA{R: 20, G: 25, B: 35, A: 200}, false)
+
+	// Fill from the center towards the current correlation value
+	centerX := meterX + meterWidth/2
+	valueX := centerX + correlation*meterWidth/2
+	fillColor := color.RGBA{R: 80, G: 200, B: 120, A: 220}
+	if correlation < 0 {
+		fillColor = color.RGBA{R: 220, G: 70, B: 60, A: 220}
+	}
+	vector.DrawFilledRect(screen, float32(math.Min(centerX, valueX)), float32(meterY), float32(math.Abs(valueX-centerX)), float32(meterHeight), fillColor, false)
+	vector.StrokeLine(screen, float32(centerX), float32(meterY-2*scale), float32(centerX), float32(meterY+meterHeight+2*scale), px, color.RGBA{R: 150, G: 160, B: 180, A: 255}, false)
+	vector.StrokeRect(screen, float32(meterX), float32(meterY), float32(meterWidth), float32(meterHeight), px, color.RGBA{R: 60, G: 70, B: 90, A: 255}, false)
+
+	PrintAt(screen, "-1", int(meterX-16*scale), int(meterY-3*scale), scale)
+	PrintAt(screen, "+1", int(meterX+meterWidth+4*scale), int(meterY-3*scale), scale)
+	PrintAt(screen, "Correlation", int(meterX), int(meterY+meterHeight+2*scale), scale)
+}
+
+func clampUnit(v float64) float64 {
+	if v < -1 {
+		return -1
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
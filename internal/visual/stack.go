@@ -0,0 +1,204 @@
+package visual
+
+import (
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// BlendMode controls how a layer is composited onto the layers below it.
+type BlendMode int
+
+const (
+	BlendNormal BlendMode = iota
+	BlendAdd
+	BlendScreen
+	BlendMultiply
+	blendModeCount
+)
+
+func (m BlendMode) String() string {
+	switch m {
+	case BlendAdd:
+		return "add"
+	case BlendScreen:
+		return "screen"
+	case BlendMultiply:
+		return "multiply"
+	default:
+		return "normal"
+	}
+}
+
+func (m BlendMode) blend() ebiten.Blend {
+	switch m {
+	case BlendAdd:
+		return ebiten.BlendLighter
+	case BlendScreen:
+		// src + dst*(1-src)
+		return ebiten.Blend{
+			BlendFactorSourceRGB:        ebiten.BlendFactorOne,
+			BlendFactorSourceAlpha:      ebiten.BlendFactorOne,
+			BlendFactorDestinationRGB:   ebiten.BlendFactorOneMinusSourceColor,
+			BlendFactorDestinationAlpha: ebiten.BlendFactorOneMinusSourceAlpha,
+			BlendOperationRGB:           ebiten.BlendOperationAdd,
+			BlendOperationAlpha:         ebiten.BlendOperationAdd,
+		}
+	case BlendMultiply:
+		// src*dst + dst*(1-srcAlpha), so transparent areas leave dst untouched
+		return ebiten.Blend{
+			BlendFactorSourceRGB:        ebiten.BlendFactorDestinationColor,
+			BlendFactorSourceAlpha:      ebiten.BlendFactorZero,
+			BlendFactorDestinationRGB:   ebiten.BlendFactorOneMinusSourceAlpha,
+			BlendFactorDestinationAlpha: ebiten.BlendFactorOne,
+			BlendOperationRGB:           ebiten.BlendOperationAdd,
+			BlendOperationAlpha:         ebiten.BlendOperationAdd,
+		}
+	default:
+		return ebiten.BlendSourceOver
+	}
+}
+
+// Layer is one scene in a Stack together with its compositing settings.
+type Layer struct {
+	Name    string
+	Scene   Visualizer
+	Blend   BlendMode
+	Opacity float64
+
+	image *ebiten.Image
+}
+
+// Stack draws a list of scenes bottom to top, each into its own offscreen
+// image which is then composited with the layer's blend mode.
+type Stack struct {
+	layers []*Layer
+	width  int
+	height int
+}
+
+func NewStack(width, height int) *Stack {
+	return &Stack{width: width, height: height}
+}
+
+func (s *Stack) newLayer(name string) (*Layer, error) {
+	scene, err := New(name)
+	if err != nil {
+		return nil, err
+	}
+	scene.Init(s.width, s.height)
+	return &Layer{Name: name, Scene: scene, Opacity: 1}, nil
+}
+
+// Set replaces all layers with the named scenes, bottom first.
+func (s *Stack) Set(names ...string) error {
+	layers := make([]*Layer, 0, len(names))
+	for _, name := range names {
+		l, err := s.newLayer(name)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, l)
+	}
+	s.dispose()
+	s.layers = layers
+	return nil
+}
+
+// Toggle adds the named scene as a new top layer using the additive blend,
+// or removes it if it is already part of the stack.
+func (s *Stack) Toggle(name string) error {
+	for i, l := range s.layers {
+		if l.Name == name {
+			if l.image != nil {
+				l.image.Deallocate()
+			}
+			s.layers = append(s.layers[:i], s.layers[i+1:]...)
+			return nil
+		}
+	}
+	l, err := s.newLayer(name)
+	if err != nil {
+		return err
+	}
+	l.Blend = BlendAdd
+	s.layers = append(s.layers, l)
+	return nil
+}
+
+// CycleBlend switches the top layer to the next blend mode.
+func (s *Stack) CycleBlend() {
+	if len(s.layers) == 0 {
+		return
+	}
+	top := s.layers[len(s.layers)-1]
+	top.Blend = (top.Blend + 1) % blendModeCount
+}
+
+// Layers returns the current layers, bottom first.
+func (s *Stack) Layers() []*Layer {
+	return s.layers
+}
+
+// Names returns the names of the layers, bottom first.
+func (s *Stack) Names() []string {
+	names := make([]string, len(s.layers))
+	for i, l := range s.layers {
+		names[i] = l.Name
+	}
+	return names
+}
+
+// String describes the stack for display as "scene + scene(blend)".
+func (s *Stack) String() string {
+	parts := make([]string, len(s.layers))
+	for i, l := range s.layers {
+		parts[i] = l.Name
+		if i > 0 {
+			parts[i] += "(" + l.Blend.String() + ")"
+		}
+	}
+	return strings.Join(parts, " + ")
+}
+
+func (s *Stack) Update(frame *Frame) {
+	for _, l := range s.layers {
+		l.Scene.Update(frame)
+	}
+}
+
+func (s *Stack) Draw(dst *ebiten.Image) {
+	for _, l := range s.layers {
+		if l.image == nil {
+			l.image = ebiten.NewImage(s.width, s.height)
+		}
+		l.image.Clear()
+		l.Scene.Draw(l.image)
+
+		op := &ebiten.DrawImageOptions{Blend: l.Blend.blend()}
+		op.ColorScale.ScaleAlpha(float32(l.Opacity))
+		dst.DrawImage(l.image, op)
+	}
+}
+
+func (s *Stack) Resize(width, height int) {
+	if width == s.width && height == s.height {
+		return
+	}
+	s.width, s.height = width, height
+	for _, l := range s.layers {
+		if l.image != nil {
+			l.image.Deallocate()
+			l.image = nil
+		}
+		l.Scene.Resize(width, height)
+	}
+}
+
+func (s *Stack) dispose() {
+	for _, l := range s.layers {
+		if l.image != nil {
+			l.image.Deallocate()
+		}
+	}
+}
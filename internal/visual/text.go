@@ -0,0 +1,48 @@
+package visual
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+// Size of a glyph of the ebitenutil debug font.
+const (
+	glyphWidth  = 6
+	glyphHeight = 16
+)
+
+var textScratch *ebiten.Image
+
+// PrintAt draws debug text at (x, y) enlarged by scale, so labels stay
+// readable on HiDPI screens.
+func PrintAt(dst *ebiten.Image, text string, x, y int, scale float64) {
+	if scale <= 1 {
+		ebitenutil.DebugPrintAt(dst, text, x, y)
+		return
+	}
+
+	w := len(text)*glyphWidth + 2
+	if textScratch == nil || textScratch.Bounds().Dx() < w {
+		if textScratch != nil {
+			textScratch.Deallocate()
+		}
+		textScratch = ebiten.NewImage(w, glyphHeight)
+	}
+	textScratch.Clear()
+	ebitenutil.DebugPrintAt(textScratch, text, 0, 0)
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(scale, scale)
+	op.GeoM.Translate(float64(x), float64(y))
+	dst.DrawImage(textScratch.SubImage(image.Rect(0, 0, w, glyphHeight)).(*ebiten.Image), op)
+}
+
+// TextWidth returns the width of text drawn by PrintAt at the given scale.
+func TextWidth(text string, scale float64) int {
+	if scale < 1 {
+		scale = 1
+	}
+	return int(float64(len(text)*glyphWidth) * scale)
+}
@@ -0,0 +1,284 @@
+package visual
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// TransitionKind selects how the outgoing scene is replaced by the incoming one.
+type TransitionKind int
+
+const (
+	TransitionCrossfade TransitionKind = iota
+	TransitionWipe
+	TransitionZoom
+	TransitionCut // hard cut on the next detected beat
+	TransitionKindCount
+)
+
+func (k TransitionKind) String() string {
+	switch k {
+	case TransitionWipe:
+		return "wipe"
+	case TransitionZoom:
+		return "zoom"
+	case TransitionCut:
+		return "cut"
+	default:
+		return "crossfade"
+	}
+}
+
+// ParseTransition converts a name as returned by String back to a kind.
+func ParseTransition(name string) (TransitionKind, error) {
+	for k := TransitionKind(0); k < TransitionKindCount; k++ {
+		if k.String() == name {
+			return k, nil
+		}
+	}
+	return 0, fmt.Errorf("visual: unknown transition %q", name)
+}
+
+// maxCutWait is how long a beat cut waits for a beat before cutting anyway.
+const maxCutWait = 2.0
+
+// Director owns the visible scene stack and animates switches between stacks.
+// During a transition both stacks keep updating and are rendered to offscreen
+// images that are then blended together. It can also rotate through a
+// playlist of scene combinations every few bars of the detected tempo.
+type Director struct {
+	// Kind and Duration (in seconds) apply to every following switch.
+	Kind     TransitionKind
+	Duration float64
+
+	// Playlist entries are scene names joined with "+" for layered scenes.
+	Playlist     []string
+	BarsPerScene int
+	BeatsPerBar  int
+	AutoPlay     bool
+
+	current  *Stack
+	outgoing *Stack
+	pending  *Stack // waiting for a beat to cut to
+	waited   float64
+	progress float64
+
+	outImage *ebiten.Image
+	inImage  *ebiten.Image
+
+	playlistIndex int
+	beats         int
+	sinceSwitch   float64
+
+	width  int
+	height int
+}
+
+func NewDirector(width, height int) *Director {
+	return &Director{
+		Duration:     1,
+		BarsPerScene: 8,
+		BeatsPerBar:  4,
+		current:      NewStack(width, height),
+		width:        width,
+		height:       height,
+	}
+}
+
+// Current returns the stack that is (or is becoming) visible.
+func (d *Director) Current() *Stack {
+	if d.pending != nil {
+		return d.pending
+	}
+	return d.current
+}
+
+// Transitioning reports whether a switch is in progress or waiting for a beat.
+func (d *Director) Transitioning() bool {
+	return d.outgoing != nil || d.pending != nil
+}
+
+// SwitchTo builds a new stack from the named scenes and transitions to it.
+func (d *Director) SwitchTo(names ...string) error {
+	next := NewStack(d.width, d.height)
+	if err := next.Set(names...); err != nil {
+		return err
+	}
+	d.switchStack(next)
+	return nil
+}
+
+func (d *Director) switchStack(next *Stack) {
+	d.beats = 0
+	d.sinceSwitch = 0
+
+	// A switch during a running transition finishes the old one first
+	if d.outgoing != nil {
+		d.outgoing.dispose()
+		d.outgoing = nil
+	}
+	if d.pending != nil {
+		d.pending.dispose()
+		d.pending = nil
+	}
+
+	switch {
+	case d.Kind == TransitionCut:
+		d.pending = next
+		d.waited = 0
+	case d.Duration <= 0:
+		d.current.dispose()
+		d.current = next
+	default:
+		d.outgoing = d.current
+		d.current = next
+		d.progress = 0
+	}
+}
+
+// Next switches to the following playlist entry.
+func (d *Director) Next() error {
+	if len(d.Playlist) == 0 {
+		return nil
+	}
+	d.playlistIndex = (d.playlistIndex + 1) % len(d.Playlist)
+	return d.SwitchTo(strings.Split(d.Playlist[d.playlistIndex], "+")...)
+}
+
+func (d *Director) Update(frame *Frame) error {
+	dt := frame.Delta
+
+	// Cut on the beat, or after a while if no beats are detected
+	if d.pending != nil {
+		d.waited += dt
+	}
+	if d.pending != nil && (frame.Beat || d.waited >= maxCutWait) {
+		d.current.dispose()
+		d.current = d.pending
+		d.pending = nil
+	}
+
+	if d.outgoing != nil {
+		d.progress += dt / d.Duration
+		if d.progress >= 1 {
+			d.outgoing.dispose()
+			d.outgoing = nil
+		} else {
+			d.outgoing.Update(frame)
+		}
+	}
+	d.current.Update(frame)
+	if d.pending != nil {
+		d.pending.Update(frame)
+	}
+
+	return d.advancePlaylist(frame, dt)
+}
+
+// advancePlaylist moves to the next playlist entry once BarsPerScene bars have
+// passed. Bars are counted in detected beats; if the tempo is known but beats
+// stop being detected, elapsed time at that tempo is used instead.
+func (d *Director) advancePlaylist(frame *Frame, dt float64) error {
+	if !d.AutoPlay || len(d.Playlist) == 0 || d.Transitioning() {
+		return nil
+	}
+	d.sinceSwitch += dt
+	if frame.Beat {
+		d.beats++
+	}
+
+	beatsNeeded := d.BarsPerScene * d.BeatsPerBar
+	due := d.beats >= beatsNeeded
+	if !due && frame.BPM > 0 {
+		// Allow one extra beat of slack before falling back to the clock
+		due = d.sinceSwitch >= float64(beatsNeeded+1)*60/frame.BPM
+	}
+	if !due {
+		return nil
+	}
+	return d.Next()
+}
+
+func (d *Director) Draw(dst *ebiten.Image) {
+	if d.outgoing == nil {
+		d.current.Draw(dst)
+		return
+	}
+
+	if d.outImage == nil {
+		d.outImage = ebiten.NewImage(d.width, d.height)
+		d.inImage = ebiten.NewImage(d.width, d.height)
+	}
+	d.outImage.Clear()
+	d.outgoing.Draw(d.outImage)
+	d.inImage.Clear()
+	d.current.Draw(d.inImage)
+
+	p := smoothstep(d.progress)
+	switch d.Kind {
+	case TransitionWipe:
+		// Incoming scene is revealed from left to right
+		edge := int(p * float64(d.width))
+		if edge < d.width {
+			rest := d.outImage.SubImage(image.Rect(edge, 0, d.width, d.height)).(*ebiten.Image)
+			op := &ebiten.DrawImageOptions{}
+			op.GeoM.Translate(float64(edge), 0)
+			dst.DrawImage(rest, op)
+		}
+		if edge > 0 {
+			revealed := d.inImage.SubImage(image.Rect(0, 0, edge, d.height)).(*ebiten.Image)
+			dst.DrawImage(revealed, nil)
+		}
+	case TransitionZoom:
+		// Outgoing scene flies towards the viewer while the incoming one grows in
+		d.drawScaled(dst, d.outImage, 1+p, 1-p)
+		d.drawScaled(dst, d.inImage, 0.5+0.5*p, p)
+	default:
+		op := &ebiten.DrawImageOptions{}
+		op.ColorScale.ScaleAlpha(float32(1 - p))
+		dst.DrawImage(d.outImage, op)
+		op = &ebiten.DrawImageOptions{}
+		op.ColorScale.ScaleAlpha(float32(p))
+		dst.DrawImage(d.inImage, op)
+	}
+}
+
+func (d *Director) drawScaled(dst, src *ebiten.Image, scale, alpha float64) {
+	op := &ebiten.DrawImageOptions{Filter: ebiten.FilterLinear}
+	op.GeoM.Translate(-float64(d.width)/2, -float64(d.height)/2)
+	op.GeoM.Scale(scale, scale)
+	op.GeoM.Translate(float64(d.width)/2, float64(d.height)/2)
+	op.ColorScale.ScaleAlpha(float32(alpha))
+	dst.DrawImage(src, op)
+}
+
+func (d *Director) Resize(width, height int) {
+	if width == d.width && height == d.height {
+		return
+	}
+	d.width, d.height = width, height
+	for _, s := range []*Stack{d.current, d.outgoing, d.pending} {
+		if s != nil {
+			s.Resize(width, height)
+		}
+	}
+	if d.outImage != nil {
+		d.outImage.Deallocate()
+		d.inImage.Deallocate()
+		d.outImage, d.inImage = nil, nil
+	}
+}
+
+// smoothstep eases progress in and out so transitions do not start abruptly.
+func smoothstep(t float64) float64 {
+	if t < 0 {
+		return 0
+	}
+	if t > 1 {
+		return 1
+	}
+	return t * t * (3 - 2*t)
+}
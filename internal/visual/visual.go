@@ -0,0 +1,101 @@
+package visual
+
+import (
+	"image"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"github.com/iburimskiy/audio-visualization/internal/config"
+	"github.com/iburimskiy/audio-visualization/internal/palette"
+)
+
+// Frame is the analysis data handed to every visualizer once per update.
+type Frame struct {
+	Bands       []float64    // smoothed band levels (0-1)
+	Samples     [][2]float64 // recent stereo samples, most recent last
+	Correlation float64      // smoothed stereo correlation (-1 to +1)
+	Beat        bool         // a beat was detected in this frame
+	BPM         float64      // detected tempo, 0 if unknown
+	Time        float64      // seconds since start
+	Delta       float64      // seconds since the previous frame
+	Rotation    float64      // shared rotation angle in radians
+	ColorPhase  float64      // shared hue offset (1.0 = full turn)
+
+	// Area is the part of the screen not covered by UI controls. Scenes that
+	// need an unobstructed box, like the scopes, draw inside it.
+	Area image.Rectangle
+	// Scale is the device scale factor, for sizing labels and strokes.
+	Scale float64
+
+	// Settings are the current visualization parameters from the config file.
+	Settings *config.Visual
+}
+
+// Visualizer is a scene that reacts to audio. Scenes are created through the
+// registry, initialized once with the screen size and then updated and drawn
+// every frame.
+type Visualizer interface {
+	// Init is called once before the first Update with the drawing size.
+	Init(width, height int)
+	// Update receives the latest analysis frame.
+	Update(frame *Frame)
+	// Draw renders the scene onto dst, which is cleared beforehand.
+	Draw(dst *ebiten.Image)
+	// Resize is called whenever the drawing size changes.
+	Resize(width, height int)
+}
+
+// base holds the state shared by most built-in scenes.
+type base struct {
+	width  int
+	height int
+	frame  *Frame
+}
+
+func (b *base) Init(width, height int)   { b.Resize(width, height) }
+func (b *base) Resize(width, height int) { b.width, b.height = width, height }
+func (b *base) Update(frame *Frame)      { b.frame = frame }
+
+// center returns the middle of the drawing area.
+func (b *base) center() (float64, float64) {
+	return float64(b.width) / 2, float64(b.height) / 2
+}
+
+// The screen size the built-in scenes were designed for.
+const (
+	designWidth  = 1024
+	designHeight = 512
+)
+
+// unit returns how much larger the drawing area is than the window size the
+// scenes were designed for, so that effects grow with the screen.
+func (b *base) unit() float64 {
+	return math.Min(float64(b.width)/designWidth, float64(b.height)/designHeight)
+}
+
+// hsv converts a scene color to RGB with the configured color settings applied.
+func (b *base) hsv(h, s, v float64) (uint8, uint8, uint8) {
+	return HSV(b.frame.Settings, h, s, v)
+}
+
+// HSV converts HSV to RGB like palette.HSVToRGB, after applying the hue
+// offset, saturation and brightness from the visual settings.
+func HSV(settings *config.Visual, h, s, v float64) (uint8, uint8, uint8) {
+	if settings != nil {
+		h += settings.HueOffset * 360
+		s = math.Min(1, s*settings.Saturation)
+		v = math.Min(1, v*settings.Brightness)
+	}
+	return palette.HSVToRGB(h, s, v)
+}
+
+// ready reports whether there is audio data to draw.
+func (b *base) ready() bool {
+	return b.frame != nil && b.frame.Settings != nil && len(b.frame.Bands) > 0
+}
+
+// band returns the level of band i, wrapping around the available bands.
+func (b *base) band(i int) float64 {
+	return b.frame.Bands[i%len(b.frame.Bands)]
+}
@@ -2,39 +2,249 @@ package main
 
 import (
 	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
+
+	"github.com/iburimskiy/audio-visualization/internal/audio"
+	"github.com/iburimskiy/audio-visualization/internal/clock"
+	"github.com/iburimskiy/audio-visualization/internal/config"
 	"github.com/iburimskiy/audio-visualization/internal/game"
+	"github.com/iburimskiy/audio-visualization/internal/playlist"
+	"github.com/iburimskiy/audio-visualization/internal/visual"
 )
 
+// Exit codes
 const (
-	windowWidth  = 1024
-	windowHeight = 512
-
-	visualRingSize  = 8192
-	smoothingFactor = 0.6
-
-	// Button dimensions
-	buttonWidth  = 120
-	buttonHeight = 40
-	buttonX      = 20
-	buttonY      = 50
-
-	// Visualization parameters
-	circleCount     = 8
-	waveCount       = 12
-	particleCount   = 50
-	maxRadius       = 200
-	rotationSpeed   = 0.02
-	colorShiftSpeed = 0.01
+	exitOK    = 0
+	exitError = 1 // runtime, config or file errors
+	exitUsage = 2 // bad flags or arguments
 )
 
+const usage = `Usage: audio-visualization [flags] [file|folder|playlist ...]
+
+Plays the given audio files in order. Folders are searched recursively and
+M3U/PLS playlists are expanded. Without arguments, use the Open File button.
+With -input, raw PCM from a pipe or standard input is visualized instead.
+The analyze, thumbnail and batch commands are in ./cmd/audiotool.
+
+Flags:
+`
+
+type options struct {
+	configPath string
+	scene      string
+	fullscreen bool
+	volume     float64
+	start      time.Duration
+	loop       bool
+	noAudio    bool
+	input      string
+	inputFmt   string
+	remote     string
+	args       []string
+}
+
 func main() {
-	ebiten.SetWindowSize(windowWidth, windowHeight)
-	ebiten.SetWindowTitle("AI Audio Visualizer - Click button to open file, Space: Play/Pause, Esc/Q: Quit")
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
 
-	g := game.NewGame()
-	if err := ebiten.RunGame(g); err != nil && !errors.Is(err, ebiten.Termination) {
-		panic(err)
+func run(args []string, stderr io.Writer) int {
+	opts, err := parseArgs(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		// parseArgs has already printed the problem and the usage
+		return exitUsage
+	}
+
+	// An explicit config path must exist; the default one is optional
+	path := opts.configPath
+	optional := path == ""
+	if optional {
+		if path, err = config.DefaultPath(); err != nil {
+			fmt.Fprintln(stderr, "config:", err)
+			return exitError
+		}
+	}
+	cfg, err := config.Load(path, optional)
+	if err != nil {
+		fmt.Fprintln(stderr, "config:", err)
+		return exitError
+	}
+
+	files, err := playlist.Expand(opts.args)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if len(opts.args) > 0 && len(files) == 0 {
+		fmt.Fprintln(stderr, "no audio files found")
+		return exitError
+	}
+
+	var input audio.Source
+	if opts.input != "" {
+		// -input-format overrides the config; parseArgs has validated it
+		format := audio.PCMFormat{Encoding: cfg.Input.Encoding, SampleRate: cfg.Input.SampleRate, Channels: cfg.Input.Channels}
+		if opts.inputFmt != "" {
+			format, _ = audio.ParsePCMFormat(opts.inputFmt)
+		}
+		if input, err = audio.OpenPCM(opts.input, format); err != nil {
+			fmt.Fprintln(stderr, "input:", err)
+			return exitError
+		}
+	}
+
+	ebiten.SetWindowSize(cfg.Window.Width, cfg.Window.Height)
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	ebiten.SetWindowTitle("AI Audio Visualizer - Click button to open file, Space: Play/Pause, 1-9: Scenes, R: Record, V: Capture, Esc/Q: Quit")
+	ebiten.SetFullscreen(opts.fullscreen)
+
+	// Update runs once per displayed frame; animation speed comes from the clock
+	ebiten.SetTPS(ebiten.SyncWithFPS)
+
+	// Start the remote control, OSC, DMX and analysis feed endpoints
+	svc, err := startServices(cfg, opts.remote)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	defer svc.Close()
+
+	// Pick up edits to the config file while playing
+	watcher := config.Watch(path, 500*time.Millisecond)
+	defer watcher.Close()
+
+	g, err := game.NewGame(game.Options{
+		Config:        cfg,
+		Clock:         clock.NewReal(),
+		ConfigUpdates: watcher.Updates(),
+		Files:         files,
+		Scene:         opts.scene,
+		Volume:        opts.volume,
+		Start:         opts.start,
+		Loop:          opts.loop,
+		NoAudio:       opts.noAudio,
+		Input:         input,
+		Control:       svc.hub,
+		Feed:          svc.frames,
+		OSC:           svc.osc,
+		DMX:           svc.dmx,
+		Events:        svc.events,
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	runErr := ebiten.RunGame(g)
+	// Finish an active recording even if the game loop failed
+	if err := g.Close(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if runErr != nil && !errors.Is(runErr, ebiten.Termination) {
+		fmt.Fprintln(stderr, runErr)
+		return exitError
+	}
+	return exitOK
+}
+
+// parseArgs reads flags and positional arguments. Flags may appear before,
+// between or after the files.
+func parseArgs(args []string, stderr io.Writer) (*options, error) {
+	opts := &options{}
+	fs := flag.NewFlagSet("audio-visualization", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.configPath, "config", "", "read settings from `file` instead of the default location")
+	fs.StringVar(&opts.scene, "scene", "", "start with these `scenes`, joined with \"+\" (e.g. rings+particles)")
+	fs.BoolVar(&opts.fullscreen, "fullscreen", false, "start in fullscreen")
+	fs.Float64Var(&opts.volume, "volume", 100, "playback volume in `percent` (0 to 200)")
+	fs.DurationVar(&opts.start, "start", 0, "skip this far into the first track (e.g. 1m30s)")
+	fs.BoolVar(&opts.loop, "loop", false, "start over after the last track")
+	fs.BoolVar(&opts.noAudio, "no-audio", false, "visualize without playing sound")
+	fs.StringVar(&opts.input, "input", "", "visualize raw PCM read from `path` (a named pipe, or - for standard input)")
+	fs.StringVar(&opts.inputFmt, "input-format", "", "PCM `format` of -input as encoding:rate:channels (default from config, s16le:44100:2)")
+	fs.StringVar(&opts.remote, "remote", "", "serve the remote control API on `address` (e.g. localhost:8080), overriding remote.listen")
+
+	fail := func(format string, a ...any) error {
+		err := fmt.Errorf(format, a...)
+		fmt.Fprintln(stderr, err)
+		fs.Usage()
+		return err
+	}
+
+	// The tools that used to be subcommands would otherwise be taken for a
+	// track to play
+	if len(args) > 0 && slices.Contains([]string{"analyze", "thumbnail", "batch"}, args[0]) {
+		err := fmt.Errorf("%s is a command of audiotool now: go run ./cmd/audiotool %s", args[0], strings.Join(args, " "))
+		fmt.Fprintln(stderr, err)
+		return nil, err
+	}
+
+	for {
+		// The flag package reports its own errors
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		// Everything after "--" is a file, even if it starts with a dash
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			opts.args = append(opts.args, rest...)
+			break
+		}
+		opts.args = append(opts.args, rest[0])
+		args = rest[1:]
+	}
+
+	if opts.volume < 0 || opts.volume > 200 {
+		return nil, fail("-volume: %g is out of range (0 to 200)", opts.volume)
+	}
+	if opts.start < 0 {
+		return nil, fail("-start: %v is negative", opts.start)
+	}
+	if opts.input != "" {
+		if len(opts.args) > 0 {
+			return nil, fail("-input cannot be combined with files")
+		}
+		if opts.start > 0 {
+			return nil, fail("-start cannot be used with -input")
+		}
+	}
+	if opts.inputFmt != "" {
+		if opts.input == "" {
+			return nil, fail("-input-format requires -input")
+		}
+		if _, err := audio.ParsePCMFormat(opts.inputFmt); err != nil {
+			return nil, fail("-input-format: %v", err)
+		}
+	}
+	if opts.remote != "" {
+		if _, _, err := net.SplitHostPort(opts.remote); err != nil {
+			return nil, fail("-remote: %v", err)
+		}
+	}
+	if opts.scene != "" {
+		for _, name := range strings.Split(opts.scene, "+") {
+			if !slices.Contains(visual.Scenes(), name) {
+				return nil, fail("-scene: unknown scene %q (available: %s)", name, strings.Join(visual.Scenes(), ", "))
+			}
+		}
 	}
+	return opts, nil
 }
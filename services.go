@@ -0,0 +1,111 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/iburimskiy/audio-visualization/internal/config"
+	"github.com/iburimskiy/audio-visualization/internal/control"
+	"github.com/iburimskiy/audio-visualization/internal/dmx"
+	"github.com/iburimskiy/audio-visualization/internal/event"
+	"github.com/iburimskiy/audio-visualization/internal/feed"
+	"github.com/iburimskiy/audio-visualization/internal/mpris"
+	"github.com/iburimskiy/audio-visualization/internal/osc"
+)
+
+// services are the optional network endpoints that drive the player or
+// follow its analysis. Unused ones stay nil.
+type services struct {
+	hub     *control.Hub      // commands from the remote control and OSC
+	frames  *feed.Broadcaster // analysis for WebSocket clients
+	osc     *osc.Sender       // analysis for OSC destinations
+	dmx     *dmx.Output       // lights following the analysis
+	events  *event.Bus        // playback and analysis events
+	closers []io.Closer
+}
+
+// startServices starts what cfg enables. remoteAddr overrides
+// remote.listen.
+func startServices(cfg *config.Config, remoteAddr string) (*services, error) {
+	s := &services{events: event.NewBus()}
+	if addr := cmp.Or(remoteAddr, cfg.Remote.Listen); addr != "" {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("remote: %w", err)
+		}
+		s.frames = feed.NewBroadcaster()
+		mux := http.NewServeMux()
+		mux.Handle("/", control.NewHandler(s.control()))
+		mux.Handle("GET /ws", s.frames.Handler())
+		srv := &http.Server{Handler: control.RequireToken(cfg.Remote.Token, mux), ReadHeaderTimeout: 10 * time.Second}
+		go srv.Serve(ln)
+		s.closers = append(s.closers, srv)
+		fmt.Printf("Remote control listening on http://%v\n", ln.Addr())
+	}
+	if cfg.OSC.Listen != "" {
+		srv, err := osc.Listen(cfg.OSC.Listen, s.control())
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("osc: %w", err)
+		}
+		go srv.Serve()
+		s.closers = append(s.closers, srv)
+		fmt.Printf("OSC listening on udp://%v\n", srv.Addr())
+	}
+	if len(cfg.OSC.Send) > 0 {
+		sender, err := osc.NewSender(cfg.OSC.Prefix, cfg.OSC.Send)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("osc: %w", err)
+		}
+		s.osc = sender
+		s.closers = append(s.closers, sender)
+	}
+	if cfg.DMX.Mapping != "" {
+		m, err := dmx.LoadMapping(cfg.DMX.Mapping)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("dmx: %w", err)
+		}
+		out, err := dmx.Start(m)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("dmx: %w", err)
+		}
+		s.dmx = out
+		s.closers = append(s.closers, out)
+		fmt.Printf("DMX sending %s universe %d to %v\n", m.Protocol, m.Universe, out.Addr())
+	}
+	if cfg.MPRIS.Enabled {
+		// Media keys are a convenience, so a missing session bus only warns
+		srv, err := mpris.Start(s.control(), s.events)
+		switch {
+		case err == nil:
+			s.closers = append(s.closers, srv)
+		case !errors.Is(err, errors.ErrUnsupported):
+			fmt.Printf("MPRIS unavailable: %v\n", err)
+		}
+	}
+	return s, nil
+}
+
+// control returns the command hub, creating it for the first user.
+func (s *services) control() *control.Hub {
+	if s.hub == nil {
+		s.hub = control.NewHub()
+	}
+	return s.hub
+}
+
+func (s *services) Close() error {
+	var errs []error
+	for _, c := range s.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}